@@ -25,10 +25,52 @@ type AppointmentsServiceClient interface {
 	GetAppointment(ctx context.Context, in *GetAppointmentRequest, opts ...grpc.CallOption) (*GetAppointmentResponse, error)
 	CreateAppointment(ctx context.Context, in *CreateAppointmentRequest, opts ...grpc.CallOption) (*CreateAppointmentResponse, error)
 	GetAppointments(ctx context.Context, in *GetAppointmentsRequest, opts ...grpc.CallOption) (*GetAppointmentsResponse, error)
+	ListAppointments(ctx context.Context, in *ListAppointmentsRequest, opts ...grpc.CallOption) (*ListAppointmentsResponse, error)
+	WatchAppointments(ctx context.Context, in *WatchAppointmentsRequest, opts ...grpc.CallOption) (AppointmentsService_WatchAppointmentsClient, error)
 	AssignPatient(ctx context.Context, in *AssignPatientRequest, opts ...grpc.CallOption) (*AssignPatientResponse, error)
 	RemovePatient(ctx context.Context, in *RemovePatientRequest, opts ...grpc.CallOption) (*RemovePatientResponse, error)
 	DeleteAppointment(ctx context.Context, in *DeleteAppointmentRequest, opts ...grpc.CallOption) (*DeleteAppointmentResponse, error)
 	UpdateAppointment(ctx context.Context, in *UpdateAppointmentRequest, opts ...grpc.CallOption) (*UpdateAppointmentResponse, error)
+	GetSchedule(ctx context.Context, in *GetScheduleRequest, opts ...grpc.CallOption) (*GetScheduleResponse, error)
+	CreateSchedule(ctx context.Context, in *CreateScheduleRequest, opts ...grpc.CallOption) (*CreateScheduleResponse, error)
+	UpdateSchedule(ctx context.Context, in *UpdateScheduleRequest, opts ...grpc.CallOption) (*UpdateScheduleResponse, error)
+	DeleteSchedule(ctx context.Context, in *DeleteScheduleRequest, opts ...grpc.CallOption) (*DeleteScheduleResponse, error)
+	FindAvailableSlots(ctx context.Context, in *FindAvailableSlotsRequest, opts ...grpc.CallOption) (*FindAvailableSlotsResponse, error)
+	CreateAppointmentSeries(ctx context.Context, in *CreateAppointmentSeriesRequest, opts ...grpc.CallOption) (*CreateAppointmentSeriesResponse, error)
+	UpdateAppointmentSeries(ctx context.Context, in *UpdateAppointmentSeriesRequest, opts ...grpc.CallOption) (*UpdateAppointmentSeriesResponse, error)
+	CancelAppointmentSeries(ctx context.Context, in *CancelAppointmentSeriesRequest, opts ...grpc.CallOption) (*CancelAppointmentSeriesResponse, error)
+	ConfirmAppointment(ctx context.Context, in *ConfirmAppointmentRequest, opts ...grpc.CallOption) (*ConfirmAppointmentResponse, error)
+	CheckInAppointment(ctx context.Context, in *CheckInAppointmentRequest, opts ...grpc.CallOption) (*CheckInAppointmentResponse, error)
+	StartAppointment(ctx context.Context, in *StartAppointmentRequest, opts ...grpc.CallOption) (*StartAppointmentResponse, error)
+	CompleteAppointment(ctx context.Context, in *CompleteAppointmentRequest, opts ...grpc.CallOption) (*CompleteAppointmentResponse, error)
+	CancelAppointment(ctx context.Context, in *CancelAppointmentRequest, opts ...grpc.CallOption) (*CancelAppointmentResponse, error)
+	MarkNoShow(ctx context.Context, in *MarkNoShowRequest, opts ...grpc.CallOption) (*MarkNoShowResponse, error)
+	RestoreAppointment(ctx context.Context, in *RestoreAppointmentRequest, opts ...grpc.CallOption) (*RestoreAppointmentResponse, error)
+	GetAppointmentHistory(ctx context.Context, in *GetAppointmentHistoryRequest, opts ...grpc.CallOption) (*GetAppointmentHistoryResponse, error)
+	ListAuditEvents(ctx context.Context, in *ListAuditEventsRequest, opts ...grpc.CallOption) (*ListAuditEventsResponse, error)
+	GetAppointmentType(ctx context.Context, in *GetAppointmentTypeRequest, opts ...grpc.CallOption) (*GetAppointmentTypeResponse, error)
+	ListAppointmentTypes(ctx context.Context, in *ListAppointmentTypesRequest, opts ...grpc.CallOption) (*ListAppointmentTypesResponse, error)
+	CreateAppointmentType(ctx context.Context, in *CreateAppointmentTypeRequest, opts ...grpc.CallOption) (*CreateAppointmentTypeResponse, error)
+	UpdateAppointmentType(ctx context.Context, in *UpdateAppointmentTypeRequest, opts ...grpc.CallOption) (*UpdateAppointmentTypeResponse, error)
+	DeleteAppointmentType(ctx context.Context, in *DeleteAppointmentTypeRequest, opts ...grpc.CallOption) (*DeleteAppointmentTypeResponse, error)
+	GetResource(ctx context.Context, in *GetResourceRequest, opts ...grpc.CallOption) (*GetResourceResponse, error)
+	ListResources(ctx context.Context, in *ListResourcesRequest, opts ...grpc.CallOption) (*ListResourcesResponse, error)
+	CreateResource(ctx context.Context, in *CreateResourceRequest, opts ...grpc.CallOption) (*CreateResourceResponse, error)
+	UpdateResource(ctx context.Context, in *UpdateResourceRequest, opts ...grpc.CallOption) (*UpdateResourceResponse, error)
+	DeleteResource(ctx context.Context, in *DeleteResourceRequest, opts ...grpc.CallOption) (*DeleteResourceResponse, error)
+	GetLocation(ctx context.Context, in *GetLocationRequest, opts ...grpc.CallOption) (*GetLocationResponse, error)
+	ListLocations(ctx context.Context, in *ListLocationsRequest, opts ...grpc.CallOption) (*ListLocationsResponse, error)
+	CreateLocation(ctx context.Context, in *CreateLocationRequest, opts ...grpc.CallOption) (*CreateLocationResponse, error)
+	UpdateLocation(ctx context.Context, in *UpdateLocationRequest, opts ...grpc.CallOption) (*UpdateLocationResponse, error)
+	DeleteLocation(ctx context.Context, in *DeleteLocationRequest, opts ...grpc.CallOption) (*DeleteLocationResponse, error)
+	GetWaitlistEntry(ctx context.Context, in *GetWaitlistEntryRequest, opts ...grpc.CallOption) (*GetWaitlistEntryResponse, error)
+	ListWaitlistEntries(ctx context.Context, in *ListWaitlistEntriesRequest, opts ...grpc.CallOption) (*ListWaitlistEntriesResponse, error)
+	CreateWaitlistEntry(ctx context.Context, in *CreateWaitlistEntryRequest, opts ...grpc.CallOption) (*CreateWaitlistEntryResponse, error)
+	DeleteWaitlistEntry(ctx context.Context, in *DeleteWaitlistEntryRequest, opts ...grpc.CallOption) (*DeleteWaitlistEntryResponse, error)
+	AcceptWaitlistOffer(ctx context.Context, in *AcceptWaitlistOfferRequest, opts ...grpc.CallOption) (*AcceptWaitlistOfferResponse, error)
+	DeclineWaitlistOffer(ctx context.Context, in *DeclineWaitlistOfferRequest, opts ...grpc.CallOption) (*DeclineWaitlistOfferResponse, error)
+	HoldSlot(ctx context.Context, in *HoldSlotRequest, opts ...grpc.CallOption) (*HoldSlotResponse, error)
+	ConfirmHold(ctx context.Context, in *ConfirmHoldRequest, opts ...grpc.CallOption) (*ConfirmHoldResponse, error)
 }
 
 type appointmentsServiceClient struct {
@@ -66,216 +108,1544 @@ func (c *appointmentsServiceClient) GetAppointments(ctx context.Context, in *Get
 	return out, nil
 }
 
+func (c *appointmentsServiceClient) ListAppointments(ctx context.Context, in *ListAppointmentsRequest, opts ...grpc.CallOption) (*ListAppointmentsResponse, error) {
+	out := new(ListAppointmentsResponse)
+	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/ListAppointments", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appointmentsServiceClient) WatchAppointments(ctx context.Context, in *WatchAppointmentsRequest, opts ...grpc.CallOption) (AppointmentsService_WatchAppointmentsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &AppointmentsService_ServiceDesc.Streams[0], "/appointments.AppointmentsService/WatchAppointments", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &appointmentsServiceWatchAppointmentsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type AppointmentsService_WatchAppointmentsClient interface {
+	Recv() (*AppointmentEvent, error)
+	grpc.ClientStream
+}
+
+type appointmentsServiceWatchAppointmentsClient struct {
+	grpc.ClientStream
+}
+
+func (x *appointmentsServiceWatchAppointmentsClient) Recv() (*AppointmentEvent, error) {
+	m := new(AppointmentEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func (c *appointmentsServiceClient) AssignPatient(ctx context.Context, in *AssignPatientRequest, opts ...grpc.CallOption) (*AssignPatientResponse, error) {
 	out := new(AssignPatientResponse)
 	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/AssignPatient", in, out, opts...)
 	if err != nil {
 		return nil, err
 	}
-	return out, nil
+	return out, nil
+}
+
+func (c *appointmentsServiceClient) RemovePatient(ctx context.Context, in *RemovePatientRequest, opts ...grpc.CallOption) (*RemovePatientResponse, error) {
+	out := new(RemovePatientResponse)
+	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/RemovePatient", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appointmentsServiceClient) DeleteAppointment(ctx context.Context, in *DeleteAppointmentRequest, opts ...grpc.CallOption) (*DeleteAppointmentResponse, error) {
+	out := new(DeleteAppointmentResponse)
+	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/DeleteAppointment", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appointmentsServiceClient) UpdateAppointment(ctx context.Context, in *UpdateAppointmentRequest, opts ...grpc.CallOption) (*UpdateAppointmentResponse, error) {
+	out := new(UpdateAppointmentResponse)
+	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/UpdateAppointment", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appointmentsServiceClient) GetSchedule(ctx context.Context, in *GetScheduleRequest, opts ...grpc.CallOption) (*GetScheduleResponse, error) {
+	out := new(GetScheduleResponse)
+	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/GetSchedule", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appointmentsServiceClient) CreateSchedule(ctx context.Context, in *CreateScheduleRequest, opts ...grpc.CallOption) (*CreateScheduleResponse, error) {
+	out := new(CreateScheduleResponse)
+	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/CreateSchedule", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appointmentsServiceClient) UpdateSchedule(ctx context.Context, in *UpdateScheduleRequest, opts ...grpc.CallOption) (*UpdateScheduleResponse, error) {
+	out := new(UpdateScheduleResponse)
+	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/UpdateSchedule", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appointmentsServiceClient) DeleteSchedule(ctx context.Context, in *DeleteScheduleRequest, opts ...grpc.CallOption) (*DeleteScheduleResponse, error) {
+	out := new(DeleteScheduleResponse)
+	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/DeleteSchedule", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appointmentsServiceClient) FindAvailableSlots(ctx context.Context, in *FindAvailableSlotsRequest, opts ...grpc.CallOption) (*FindAvailableSlotsResponse, error) {
+	out := new(FindAvailableSlotsResponse)
+	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/FindAvailableSlots", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appointmentsServiceClient) CreateAppointmentSeries(ctx context.Context, in *CreateAppointmentSeriesRequest, opts ...grpc.CallOption) (*CreateAppointmentSeriesResponse, error) {
+	out := new(CreateAppointmentSeriesResponse)
+	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/CreateAppointmentSeries", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appointmentsServiceClient) UpdateAppointmentSeries(ctx context.Context, in *UpdateAppointmentSeriesRequest, opts ...grpc.CallOption) (*UpdateAppointmentSeriesResponse, error) {
+	out := new(UpdateAppointmentSeriesResponse)
+	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/UpdateAppointmentSeries", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appointmentsServiceClient) CancelAppointmentSeries(ctx context.Context, in *CancelAppointmentSeriesRequest, opts ...grpc.CallOption) (*CancelAppointmentSeriesResponse, error) {
+	out := new(CancelAppointmentSeriesResponse)
+	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/CancelAppointmentSeries", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appointmentsServiceClient) ConfirmAppointment(ctx context.Context, in *ConfirmAppointmentRequest, opts ...grpc.CallOption) (*ConfirmAppointmentResponse, error) {
+	out := new(ConfirmAppointmentResponse)
+	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/ConfirmAppointment", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appointmentsServiceClient) CheckInAppointment(ctx context.Context, in *CheckInAppointmentRequest, opts ...grpc.CallOption) (*CheckInAppointmentResponse, error) {
+	out := new(CheckInAppointmentResponse)
+	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/CheckInAppointment", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appointmentsServiceClient) StartAppointment(ctx context.Context, in *StartAppointmentRequest, opts ...grpc.CallOption) (*StartAppointmentResponse, error) {
+	out := new(StartAppointmentResponse)
+	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/StartAppointment", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appointmentsServiceClient) CompleteAppointment(ctx context.Context, in *CompleteAppointmentRequest, opts ...grpc.CallOption) (*CompleteAppointmentResponse, error) {
+	out := new(CompleteAppointmentResponse)
+	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/CompleteAppointment", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appointmentsServiceClient) CancelAppointment(ctx context.Context, in *CancelAppointmentRequest, opts ...grpc.CallOption) (*CancelAppointmentResponse, error) {
+	out := new(CancelAppointmentResponse)
+	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/CancelAppointment", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appointmentsServiceClient) MarkNoShow(ctx context.Context, in *MarkNoShowRequest, opts ...grpc.CallOption) (*MarkNoShowResponse, error) {
+	out := new(MarkNoShowResponse)
+	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/MarkNoShow", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appointmentsServiceClient) RestoreAppointment(ctx context.Context, in *RestoreAppointmentRequest, opts ...grpc.CallOption) (*RestoreAppointmentResponse, error) {
+	out := new(RestoreAppointmentResponse)
+	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/RestoreAppointment", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appointmentsServiceClient) GetAppointmentHistory(ctx context.Context, in *GetAppointmentHistoryRequest, opts ...grpc.CallOption) (*GetAppointmentHistoryResponse, error) {
+	out := new(GetAppointmentHistoryResponse)
+	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/GetAppointmentHistory", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appointmentsServiceClient) ListAuditEvents(ctx context.Context, in *ListAuditEventsRequest, opts ...grpc.CallOption) (*ListAuditEventsResponse, error) {
+	out := new(ListAuditEventsResponse)
+	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/ListAuditEvents", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appointmentsServiceClient) GetAppointmentType(ctx context.Context, in *GetAppointmentTypeRequest, opts ...grpc.CallOption) (*GetAppointmentTypeResponse, error) {
+	out := new(GetAppointmentTypeResponse)
+	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/GetAppointmentType", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appointmentsServiceClient) ListAppointmentTypes(ctx context.Context, in *ListAppointmentTypesRequest, opts ...grpc.CallOption) (*ListAppointmentTypesResponse, error) {
+	out := new(ListAppointmentTypesResponse)
+	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/ListAppointmentTypes", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appointmentsServiceClient) CreateAppointmentType(ctx context.Context, in *CreateAppointmentTypeRequest, opts ...grpc.CallOption) (*CreateAppointmentTypeResponse, error) {
+	out := new(CreateAppointmentTypeResponse)
+	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/CreateAppointmentType", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appointmentsServiceClient) UpdateAppointmentType(ctx context.Context, in *UpdateAppointmentTypeRequest, opts ...grpc.CallOption) (*UpdateAppointmentTypeResponse, error) {
+	out := new(UpdateAppointmentTypeResponse)
+	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/UpdateAppointmentType", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appointmentsServiceClient) DeleteAppointmentType(ctx context.Context, in *DeleteAppointmentTypeRequest, opts ...grpc.CallOption) (*DeleteAppointmentTypeResponse, error) {
+	out := new(DeleteAppointmentTypeResponse)
+	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/DeleteAppointmentType", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appointmentsServiceClient) GetResource(ctx context.Context, in *GetResourceRequest, opts ...grpc.CallOption) (*GetResourceResponse, error) {
+	out := new(GetResourceResponse)
+	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/GetResource", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appointmentsServiceClient) ListResources(ctx context.Context, in *ListResourcesRequest, opts ...grpc.CallOption) (*ListResourcesResponse, error) {
+	out := new(ListResourcesResponse)
+	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/ListResources", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appointmentsServiceClient) CreateResource(ctx context.Context, in *CreateResourceRequest, opts ...grpc.CallOption) (*CreateResourceResponse, error) {
+	out := new(CreateResourceResponse)
+	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/CreateResource", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appointmentsServiceClient) UpdateResource(ctx context.Context, in *UpdateResourceRequest, opts ...grpc.CallOption) (*UpdateResourceResponse, error) {
+	out := new(UpdateResourceResponse)
+	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/UpdateResource", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appointmentsServiceClient) DeleteResource(ctx context.Context, in *DeleteResourceRequest, opts ...grpc.CallOption) (*DeleteResourceResponse, error) {
+	out := new(DeleteResourceResponse)
+	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/DeleteResource", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appointmentsServiceClient) GetLocation(ctx context.Context, in *GetLocationRequest, opts ...grpc.CallOption) (*GetLocationResponse, error) {
+	out := new(GetLocationResponse)
+	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/GetLocation", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appointmentsServiceClient) ListLocations(ctx context.Context, in *ListLocationsRequest, opts ...grpc.CallOption) (*ListLocationsResponse, error) {
+	out := new(ListLocationsResponse)
+	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/ListLocations", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appointmentsServiceClient) CreateLocation(ctx context.Context, in *CreateLocationRequest, opts ...grpc.CallOption) (*CreateLocationResponse, error) {
+	out := new(CreateLocationResponse)
+	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/CreateLocation", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appointmentsServiceClient) UpdateLocation(ctx context.Context, in *UpdateLocationRequest, opts ...grpc.CallOption) (*UpdateLocationResponse, error) {
+	out := new(UpdateLocationResponse)
+	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/UpdateLocation", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appointmentsServiceClient) DeleteLocation(ctx context.Context, in *DeleteLocationRequest, opts ...grpc.CallOption) (*DeleteLocationResponse, error) {
+	out := new(DeleteLocationResponse)
+	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/DeleteLocation", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appointmentsServiceClient) GetWaitlistEntry(ctx context.Context, in *GetWaitlistEntryRequest, opts ...grpc.CallOption) (*GetWaitlistEntryResponse, error) {
+	out := new(GetWaitlistEntryResponse)
+	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/GetWaitlistEntry", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appointmentsServiceClient) ListWaitlistEntries(ctx context.Context, in *ListWaitlistEntriesRequest, opts ...grpc.CallOption) (*ListWaitlistEntriesResponse, error) {
+	out := new(ListWaitlistEntriesResponse)
+	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/ListWaitlistEntries", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appointmentsServiceClient) CreateWaitlistEntry(ctx context.Context, in *CreateWaitlistEntryRequest, opts ...grpc.CallOption) (*CreateWaitlistEntryResponse, error) {
+	out := new(CreateWaitlistEntryResponse)
+	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/CreateWaitlistEntry", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appointmentsServiceClient) DeleteWaitlistEntry(ctx context.Context, in *DeleteWaitlistEntryRequest, opts ...grpc.CallOption) (*DeleteWaitlistEntryResponse, error) {
+	out := new(DeleteWaitlistEntryResponse)
+	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/DeleteWaitlistEntry", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appointmentsServiceClient) AcceptWaitlistOffer(ctx context.Context, in *AcceptWaitlistOfferRequest, opts ...grpc.CallOption) (*AcceptWaitlistOfferResponse, error) {
+	out := new(AcceptWaitlistOfferResponse)
+	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/AcceptWaitlistOffer", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appointmentsServiceClient) DeclineWaitlistOffer(ctx context.Context, in *DeclineWaitlistOfferRequest, opts ...grpc.CallOption) (*DeclineWaitlistOfferResponse, error) {
+	out := new(DeclineWaitlistOfferResponse)
+	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/DeclineWaitlistOffer", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appointmentsServiceClient) HoldSlot(ctx context.Context, in *HoldSlotRequest, opts ...grpc.CallOption) (*HoldSlotResponse, error) {
+	out := new(HoldSlotResponse)
+	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/HoldSlot", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appointmentsServiceClient) ConfirmHold(ctx context.Context, in *ConfirmHoldRequest, opts ...grpc.CallOption) (*ConfirmHoldResponse, error) {
+	out := new(ConfirmHoldResponse)
+	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/ConfirmHold", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AppointmentsServiceServer is the server API for AppointmentsService service.
+// All implementations must embed UnimplementedAppointmentsServiceServer
+// for forward compatibility
+type AppointmentsServiceServer interface {
+	GetAppointment(context.Context, *GetAppointmentRequest) (*GetAppointmentResponse, error)
+	CreateAppointment(context.Context, *CreateAppointmentRequest) (*CreateAppointmentResponse, error)
+	GetAppointments(context.Context, *GetAppointmentsRequest) (*GetAppointmentsResponse, error)
+	ListAppointments(context.Context, *ListAppointmentsRequest) (*ListAppointmentsResponse, error)
+	WatchAppointments(*WatchAppointmentsRequest, AppointmentsService_WatchAppointmentsServer) error
+	AssignPatient(context.Context, *AssignPatientRequest) (*AssignPatientResponse, error)
+	RemovePatient(context.Context, *RemovePatientRequest) (*RemovePatientResponse, error)
+	DeleteAppointment(context.Context, *DeleteAppointmentRequest) (*DeleteAppointmentResponse, error)
+	UpdateAppointment(context.Context, *UpdateAppointmentRequest) (*UpdateAppointmentResponse, error)
+	GetSchedule(context.Context, *GetScheduleRequest) (*GetScheduleResponse, error)
+	CreateSchedule(context.Context, *CreateScheduleRequest) (*CreateScheduleResponse, error)
+	UpdateSchedule(context.Context, *UpdateScheduleRequest) (*UpdateScheduleResponse, error)
+	DeleteSchedule(context.Context, *DeleteScheduleRequest) (*DeleteScheduleResponse, error)
+	FindAvailableSlots(context.Context, *FindAvailableSlotsRequest) (*FindAvailableSlotsResponse, error)
+	CreateAppointmentSeries(context.Context, *CreateAppointmentSeriesRequest) (*CreateAppointmentSeriesResponse, error)
+	UpdateAppointmentSeries(context.Context, *UpdateAppointmentSeriesRequest) (*UpdateAppointmentSeriesResponse, error)
+	CancelAppointmentSeries(context.Context, *CancelAppointmentSeriesRequest) (*CancelAppointmentSeriesResponse, error)
+	ConfirmAppointment(context.Context, *ConfirmAppointmentRequest) (*ConfirmAppointmentResponse, error)
+	CheckInAppointment(context.Context, *CheckInAppointmentRequest) (*CheckInAppointmentResponse, error)
+	StartAppointment(context.Context, *StartAppointmentRequest) (*StartAppointmentResponse, error)
+	CompleteAppointment(context.Context, *CompleteAppointmentRequest) (*CompleteAppointmentResponse, error)
+	CancelAppointment(context.Context, *CancelAppointmentRequest) (*CancelAppointmentResponse, error)
+	MarkNoShow(context.Context, *MarkNoShowRequest) (*MarkNoShowResponse, error)
+	RestoreAppointment(context.Context, *RestoreAppointmentRequest) (*RestoreAppointmentResponse, error)
+	GetAppointmentHistory(context.Context, *GetAppointmentHistoryRequest) (*GetAppointmentHistoryResponse, error)
+	ListAuditEvents(context.Context, *ListAuditEventsRequest) (*ListAuditEventsResponse, error)
+	GetAppointmentType(context.Context, *GetAppointmentTypeRequest) (*GetAppointmentTypeResponse, error)
+	ListAppointmentTypes(context.Context, *ListAppointmentTypesRequest) (*ListAppointmentTypesResponse, error)
+	CreateAppointmentType(context.Context, *CreateAppointmentTypeRequest) (*CreateAppointmentTypeResponse, error)
+	UpdateAppointmentType(context.Context, *UpdateAppointmentTypeRequest) (*UpdateAppointmentTypeResponse, error)
+	DeleteAppointmentType(context.Context, *DeleteAppointmentTypeRequest) (*DeleteAppointmentTypeResponse, error)
+	GetResource(context.Context, *GetResourceRequest) (*GetResourceResponse, error)
+	ListResources(context.Context, *ListResourcesRequest) (*ListResourcesResponse, error)
+	CreateResource(context.Context, *CreateResourceRequest) (*CreateResourceResponse, error)
+	UpdateResource(context.Context, *UpdateResourceRequest) (*UpdateResourceResponse, error)
+	DeleteResource(context.Context, *DeleteResourceRequest) (*DeleteResourceResponse, error)
+	GetLocation(context.Context, *GetLocationRequest) (*GetLocationResponse, error)
+	ListLocations(context.Context, *ListLocationsRequest) (*ListLocationsResponse, error)
+	CreateLocation(context.Context, *CreateLocationRequest) (*CreateLocationResponse, error)
+	UpdateLocation(context.Context, *UpdateLocationRequest) (*UpdateLocationResponse, error)
+	DeleteLocation(context.Context, *DeleteLocationRequest) (*DeleteLocationResponse, error)
+	GetWaitlistEntry(context.Context, *GetWaitlistEntryRequest) (*GetWaitlistEntryResponse, error)
+	ListWaitlistEntries(context.Context, *ListWaitlistEntriesRequest) (*ListWaitlistEntriesResponse, error)
+	CreateWaitlistEntry(context.Context, *CreateWaitlistEntryRequest) (*CreateWaitlistEntryResponse, error)
+	DeleteWaitlistEntry(context.Context, *DeleteWaitlistEntryRequest) (*DeleteWaitlistEntryResponse, error)
+	AcceptWaitlistOffer(context.Context, *AcceptWaitlistOfferRequest) (*AcceptWaitlistOfferResponse, error)
+	DeclineWaitlistOffer(context.Context, *DeclineWaitlistOfferRequest) (*DeclineWaitlistOfferResponse, error)
+	HoldSlot(context.Context, *HoldSlotRequest) (*HoldSlotResponse, error)
+	ConfirmHold(context.Context, *ConfirmHoldRequest) (*ConfirmHoldResponse, error)
+	mustEmbedUnimplementedAppointmentsServiceServer()
+}
+
+// UnimplementedAppointmentsServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedAppointmentsServiceServer struct {
+}
+
+func (UnimplementedAppointmentsServiceServer) GetAppointment(context.Context, *GetAppointmentRequest) (*GetAppointmentResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAppointment not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) CreateAppointment(context.Context, *CreateAppointmentRequest) (*CreateAppointmentResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateAppointment not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) GetAppointments(context.Context, *GetAppointmentsRequest) (*GetAppointmentsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAppointments not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) ListAppointments(context.Context, *ListAppointmentsRequest) (*ListAppointmentsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListAppointments not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) WatchAppointments(*WatchAppointmentsRequest, AppointmentsService_WatchAppointmentsServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchAppointments not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) AssignPatient(context.Context, *AssignPatientRequest) (*AssignPatientResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AssignPatient not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) RemovePatient(context.Context, *RemovePatientRequest) (*RemovePatientResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemovePatient not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) DeleteAppointment(context.Context, *DeleteAppointmentRequest) (*DeleteAppointmentResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteAppointment not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) UpdateAppointment(context.Context, *UpdateAppointmentRequest) (*UpdateAppointmentResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateAppointment not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) GetSchedule(context.Context, *GetScheduleRequest) (*GetScheduleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSchedule not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) CreateSchedule(context.Context, *CreateScheduleRequest) (*CreateScheduleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateSchedule not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) UpdateSchedule(context.Context, *UpdateScheduleRequest) (*UpdateScheduleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateSchedule not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) DeleteSchedule(context.Context, *DeleteScheduleRequest) (*DeleteScheduleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteSchedule not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) FindAvailableSlots(context.Context, *FindAvailableSlotsRequest) (*FindAvailableSlotsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FindAvailableSlots not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) CreateAppointmentSeries(context.Context, *CreateAppointmentSeriesRequest) (*CreateAppointmentSeriesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateAppointmentSeries not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) UpdateAppointmentSeries(context.Context, *UpdateAppointmentSeriesRequest) (*UpdateAppointmentSeriesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateAppointmentSeries not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) CancelAppointmentSeries(context.Context, *CancelAppointmentSeriesRequest) (*CancelAppointmentSeriesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelAppointmentSeries not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) ConfirmAppointment(context.Context, *ConfirmAppointmentRequest) (*ConfirmAppointmentResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ConfirmAppointment not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) CheckInAppointment(context.Context, *CheckInAppointmentRequest) (*CheckInAppointmentResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CheckInAppointment not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) StartAppointment(context.Context, *StartAppointmentRequest) (*StartAppointmentResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartAppointment not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) CompleteAppointment(context.Context, *CompleteAppointmentRequest) (*CompleteAppointmentResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CompleteAppointment not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) CancelAppointment(context.Context, *CancelAppointmentRequest) (*CancelAppointmentResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelAppointment not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) MarkNoShow(context.Context, *MarkNoShowRequest) (*MarkNoShowResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MarkNoShow not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) RestoreAppointment(context.Context, *RestoreAppointmentRequest) (*RestoreAppointmentResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RestoreAppointment not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) GetAppointmentHistory(context.Context, *GetAppointmentHistoryRequest) (*GetAppointmentHistoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAppointmentHistory not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) ListAuditEvents(context.Context, *ListAuditEventsRequest) (*ListAuditEventsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListAuditEvents not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) GetAppointmentType(context.Context, *GetAppointmentTypeRequest) (*GetAppointmentTypeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAppointmentType not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) ListAppointmentTypes(context.Context, *ListAppointmentTypesRequest) (*ListAppointmentTypesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListAppointmentTypes not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) CreateAppointmentType(context.Context, *CreateAppointmentTypeRequest) (*CreateAppointmentTypeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateAppointmentType not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) UpdateAppointmentType(context.Context, *UpdateAppointmentTypeRequest) (*UpdateAppointmentTypeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateAppointmentType not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) DeleteAppointmentType(context.Context, *DeleteAppointmentTypeRequest) (*DeleteAppointmentTypeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteAppointmentType not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) GetResource(context.Context, *GetResourceRequest) (*GetResourceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetResource not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) ListResources(context.Context, *ListResourcesRequest) (*ListResourcesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListResources not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) CreateResource(context.Context, *CreateResourceRequest) (*CreateResourceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateResource not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) UpdateResource(context.Context, *UpdateResourceRequest) (*UpdateResourceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateResource not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) DeleteResource(context.Context, *DeleteResourceRequest) (*DeleteResourceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteResource not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) GetLocation(context.Context, *GetLocationRequest) (*GetLocationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLocation not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) ListLocations(context.Context, *ListLocationsRequest) (*ListLocationsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListLocations not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) CreateLocation(context.Context, *CreateLocationRequest) (*CreateLocationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateLocation not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) UpdateLocation(context.Context, *UpdateLocationRequest) (*UpdateLocationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateLocation not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) DeleteLocation(context.Context, *DeleteLocationRequest) (*DeleteLocationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteLocation not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) GetWaitlistEntry(context.Context, *GetWaitlistEntryRequest) (*GetWaitlistEntryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetWaitlistEntry not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) ListWaitlistEntries(context.Context, *ListWaitlistEntriesRequest) (*ListWaitlistEntriesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListWaitlistEntries not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) CreateWaitlistEntry(context.Context, *CreateWaitlistEntryRequest) (*CreateWaitlistEntryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateWaitlistEntry not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) DeleteWaitlistEntry(context.Context, *DeleteWaitlistEntryRequest) (*DeleteWaitlistEntryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteWaitlistEntry not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) AcceptWaitlistOffer(context.Context, *AcceptWaitlistOfferRequest) (*AcceptWaitlistOfferResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AcceptWaitlistOffer not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) DeclineWaitlistOffer(context.Context, *DeclineWaitlistOfferRequest) (*DeclineWaitlistOfferResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeclineWaitlistOffer not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) HoldSlot(context.Context, *HoldSlotRequest) (*HoldSlotResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method HoldSlot not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) ConfirmHold(context.Context, *ConfirmHoldRequest) (*ConfirmHoldResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ConfirmHold not implemented")
+}
+func (UnimplementedAppointmentsServiceServer) mustEmbedUnimplementedAppointmentsServiceServer() {}
+
+// UnsafeAppointmentsServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AppointmentsServiceServer will
+// result in compilation errors.
+type UnsafeAppointmentsServiceServer interface {
+	mustEmbedUnimplementedAppointmentsServiceServer()
+}
+
+func RegisterAppointmentsServiceServer(s grpc.ServiceRegistrar, srv AppointmentsServiceServer) {
+	s.RegisterService(&AppointmentsService_ServiceDesc, srv)
+}
+
+func _AppointmentsService_GetAppointment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetAppointmentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppointmentsServiceServer).GetAppointment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/appointments.AppointmentsService/GetAppointment",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppointmentsServiceServer).GetAppointment(ctx, req.(*GetAppointmentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AppointmentsService_CreateAppointment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateAppointmentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppointmentsServiceServer).CreateAppointment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/appointments.AppointmentsService/CreateAppointment",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppointmentsServiceServer).CreateAppointment(ctx, req.(*CreateAppointmentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AppointmentsService_GetAppointments_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetAppointmentsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppointmentsServiceServer).GetAppointments(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/appointments.AppointmentsService/GetAppointments",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppointmentsServiceServer).GetAppointments(ctx, req.(*GetAppointmentsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AppointmentsService_ListAppointments_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAppointmentsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppointmentsServiceServer).ListAppointments(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/appointments.AppointmentsService/ListAppointments",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppointmentsServiceServer).ListAppointments(ctx, req.(*ListAppointmentsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AppointmentsService_WatchAppointments_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchAppointmentsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AppointmentsServiceServer).WatchAppointments(m, &appointmentsServiceWatchAppointmentsServer{stream})
+}
+
+type AppointmentsService_WatchAppointmentsServer interface {
+	Send(*AppointmentEvent) error
+	grpc.ServerStream
+}
+
+type appointmentsServiceWatchAppointmentsServer struct {
+	grpc.ServerStream
+}
+
+func (x *appointmentsServiceWatchAppointmentsServer) Send(m *AppointmentEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _AppointmentsService_AssignPatient_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AssignPatientRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppointmentsServiceServer).AssignPatient(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/appointments.AppointmentsService/AssignPatient",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppointmentsServiceServer).AssignPatient(ctx, req.(*AssignPatientRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AppointmentsService_RemovePatient_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemovePatientRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppointmentsServiceServer).RemovePatient(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/appointments.AppointmentsService/RemovePatient",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppointmentsServiceServer).RemovePatient(ctx, req.(*RemovePatientRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AppointmentsService_DeleteAppointment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteAppointmentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppointmentsServiceServer).DeleteAppointment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/appointments.AppointmentsService/DeleteAppointment",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppointmentsServiceServer).DeleteAppointment(ctx, req.(*DeleteAppointmentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AppointmentsService_UpdateAppointment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateAppointmentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppointmentsServiceServer).UpdateAppointment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/appointments.AppointmentsService/UpdateAppointment",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppointmentsServiceServer).UpdateAppointment(ctx, req.(*UpdateAppointmentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AppointmentsService_GetSchedule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetScheduleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppointmentsServiceServer).GetSchedule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/appointments.AppointmentsService/GetSchedule",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppointmentsServiceServer).GetSchedule(ctx, req.(*GetScheduleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AppointmentsService_CreateSchedule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateScheduleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppointmentsServiceServer).CreateSchedule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/appointments.AppointmentsService/CreateSchedule",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppointmentsServiceServer).CreateSchedule(ctx, req.(*CreateScheduleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AppointmentsService_UpdateSchedule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateScheduleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppointmentsServiceServer).UpdateSchedule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/appointments.AppointmentsService/UpdateSchedule",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppointmentsServiceServer).UpdateSchedule(ctx, req.(*UpdateScheduleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AppointmentsService_DeleteSchedule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteScheduleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppointmentsServiceServer).DeleteSchedule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/appointments.AppointmentsService/DeleteSchedule",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppointmentsServiceServer).DeleteSchedule(ctx, req.(*DeleteScheduleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AppointmentsService_FindAvailableSlots_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FindAvailableSlotsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppointmentsServiceServer).FindAvailableSlots(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/appointments.AppointmentsService/FindAvailableSlots",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppointmentsServiceServer).FindAvailableSlots(ctx, req.(*FindAvailableSlotsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AppointmentsService_CreateAppointmentSeries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateAppointmentSeriesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppointmentsServiceServer).CreateAppointmentSeries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/appointments.AppointmentsService/CreateAppointmentSeries",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppointmentsServiceServer).CreateAppointmentSeries(ctx, req.(*CreateAppointmentSeriesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AppointmentsService_UpdateAppointmentSeries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateAppointmentSeriesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppointmentsServiceServer).UpdateAppointmentSeries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/appointments.AppointmentsService/UpdateAppointmentSeries",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppointmentsServiceServer).UpdateAppointmentSeries(ctx, req.(*UpdateAppointmentSeriesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AppointmentsService_CancelAppointmentSeries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelAppointmentSeriesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppointmentsServiceServer).CancelAppointmentSeries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/appointments.AppointmentsService/CancelAppointmentSeries",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppointmentsServiceServer).CancelAppointmentSeries(ctx, req.(*CancelAppointmentSeriesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AppointmentsService_ConfirmAppointment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConfirmAppointmentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppointmentsServiceServer).ConfirmAppointment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/appointments.AppointmentsService/ConfirmAppointment",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppointmentsServiceServer).ConfirmAppointment(ctx, req.(*ConfirmAppointmentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AppointmentsService_CheckInAppointment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CheckInAppointmentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppointmentsServiceServer).CheckInAppointment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/appointments.AppointmentsService/CheckInAppointment",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppointmentsServiceServer).CheckInAppointment(ctx, req.(*CheckInAppointmentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AppointmentsService_StartAppointment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartAppointmentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppointmentsServiceServer).StartAppointment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/appointments.AppointmentsService/StartAppointment",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppointmentsServiceServer).StartAppointment(ctx, req.(*StartAppointmentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AppointmentsService_CompleteAppointment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CompleteAppointmentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppointmentsServiceServer).CompleteAppointment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/appointments.AppointmentsService/CompleteAppointment",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppointmentsServiceServer).CompleteAppointment(ctx, req.(*CompleteAppointmentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AppointmentsService_CancelAppointment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelAppointmentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppointmentsServiceServer).CancelAppointment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/appointments.AppointmentsService/CancelAppointment",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppointmentsServiceServer).CancelAppointment(ctx, req.(*CancelAppointmentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AppointmentsService_MarkNoShow_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MarkNoShowRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppointmentsServiceServer).MarkNoShow(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/appointments.AppointmentsService/MarkNoShow",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppointmentsServiceServer).MarkNoShow(ctx, req.(*MarkNoShowRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AppointmentsService_RestoreAppointment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestoreAppointmentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppointmentsServiceServer).RestoreAppointment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/appointments.AppointmentsService/RestoreAppointment",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppointmentsServiceServer).RestoreAppointment(ctx, req.(*RestoreAppointmentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AppointmentsService_GetAppointmentHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetAppointmentHistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppointmentsServiceServer).GetAppointmentHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/appointments.AppointmentsService/GetAppointmentHistory",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppointmentsServiceServer).GetAppointmentHistory(ctx, req.(*GetAppointmentHistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AppointmentsService_ListAuditEvents_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAuditEventsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppointmentsServiceServer).ListAuditEvents(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/appointments.AppointmentsService/ListAuditEvents",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppointmentsServiceServer).ListAuditEvents(ctx, req.(*ListAuditEventsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AppointmentsService_GetAppointmentType_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetAppointmentTypeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppointmentsServiceServer).GetAppointmentType(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/appointments.AppointmentsService/GetAppointmentType",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppointmentsServiceServer).GetAppointmentType(ctx, req.(*GetAppointmentTypeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AppointmentsService_ListAppointmentTypes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAppointmentTypesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppointmentsServiceServer).ListAppointmentTypes(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/appointments.AppointmentsService/ListAppointmentTypes",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppointmentsServiceServer).ListAppointmentTypes(ctx, req.(*ListAppointmentTypesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AppointmentsService_CreateAppointmentType_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateAppointmentTypeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppointmentsServiceServer).CreateAppointmentType(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/appointments.AppointmentsService/CreateAppointmentType",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppointmentsServiceServer).CreateAppointmentType(ctx, req.(*CreateAppointmentTypeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AppointmentsService_UpdateAppointmentType_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateAppointmentTypeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppointmentsServiceServer).UpdateAppointmentType(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/appointments.AppointmentsService/UpdateAppointmentType",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppointmentsServiceServer).UpdateAppointmentType(ctx, req.(*UpdateAppointmentTypeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AppointmentsService_DeleteAppointmentType_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteAppointmentTypeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppointmentsServiceServer).DeleteAppointmentType(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/appointments.AppointmentsService/DeleteAppointmentType",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppointmentsServiceServer).DeleteAppointmentType(ctx, req.(*DeleteAppointmentTypeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AppointmentsService_GetResource_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetResourceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppointmentsServiceServer).GetResource(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/appointments.AppointmentsService/GetResource",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppointmentsServiceServer).GetResource(ctx, req.(*GetResourceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AppointmentsService_ListResources_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListResourcesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppointmentsServiceServer).ListResources(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/appointments.AppointmentsService/ListResources",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppointmentsServiceServer).ListResources(ctx, req.(*ListResourcesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AppointmentsService_CreateResource_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateResourceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppointmentsServiceServer).CreateResource(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/appointments.AppointmentsService/CreateResource",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppointmentsServiceServer).CreateResource(ctx, req.(*CreateResourceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AppointmentsService_UpdateResource_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateResourceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppointmentsServiceServer).UpdateResource(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/appointments.AppointmentsService/UpdateResource",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppointmentsServiceServer).UpdateResource(ctx, req.(*UpdateResourceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func (c *appointmentsServiceClient) RemovePatient(ctx context.Context, in *RemovePatientRequest, opts ...grpc.CallOption) (*RemovePatientResponse, error) {
-	out := new(RemovePatientResponse)
-	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/RemovePatient", in, out, opts...)
-	if err != nil {
+func _AppointmentsService_DeleteResource_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteResourceRequest)
+	if err := dec(in); err != nil {
 		return nil, err
 	}
-	return out, nil
+	if interceptor == nil {
+		return srv.(AppointmentsServiceServer).DeleteResource(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/appointments.AppointmentsService/DeleteResource",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppointmentsServiceServer).DeleteResource(ctx, req.(*DeleteResourceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func (c *appointmentsServiceClient) DeleteAppointment(ctx context.Context, in *DeleteAppointmentRequest, opts ...grpc.CallOption) (*DeleteAppointmentResponse, error) {
-	out := new(DeleteAppointmentResponse)
-	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/DeleteAppointment", in, out, opts...)
-	if err != nil {
+func _AppointmentsService_GetLocation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLocationRequest)
+	if err := dec(in); err != nil {
 		return nil, err
 	}
-	return out, nil
+	if interceptor == nil {
+		return srv.(AppointmentsServiceServer).GetLocation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/appointments.AppointmentsService/GetLocation",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppointmentsServiceServer).GetLocation(ctx, req.(*GetLocationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func (c *appointmentsServiceClient) UpdateAppointment(ctx context.Context, in *UpdateAppointmentRequest, opts ...grpc.CallOption) (*UpdateAppointmentResponse, error) {
-	out := new(UpdateAppointmentResponse)
-	err := c.cc.Invoke(ctx, "/appointments.AppointmentsService/UpdateAppointment", in, out, opts...)
-	if err != nil {
+func _AppointmentsService_ListLocations_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListLocationsRequest)
+	if err := dec(in); err != nil {
 		return nil, err
 	}
-	return out, nil
-}
-
-// AppointmentsServiceServer is the server API for AppointmentsService service.
-// All implementations must embed UnimplementedAppointmentsServiceServer
-// for forward compatibility
-type AppointmentsServiceServer interface {
-	GetAppointment(context.Context, *GetAppointmentRequest) (*GetAppointmentResponse, error)
-	CreateAppointment(context.Context, *CreateAppointmentRequest) (*CreateAppointmentResponse, error)
-	GetAppointments(context.Context, *GetAppointmentsRequest) (*GetAppointmentsResponse, error)
-	AssignPatient(context.Context, *AssignPatientRequest) (*AssignPatientResponse, error)
-	RemovePatient(context.Context, *RemovePatientRequest) (*RemovePatientResponse, error)
-	DeleteAppointment(context.Context, *DeleteAppointmentRequest) (*DeleteAppointmentResponse, error)
-	UpdateAppointment(context.Context, *UpdateAppointmentRequest) (*UpdateAppointmentResponse, error)
-	mustEmbedUnimplementedAppointmentsServiceServer()
+	if interceptor == nil {
+		return srv.(AppointmentsServiceServer).ListLocations(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/appointments.AppointmentsService/ListLocations",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppointmentsServiceServer).ListLocations(ctx, req.(*ListLocationsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-// UnimplementedAppointmentsServiceServer must be embedded to have forward compatible implementations.
-type UnimplementedAppointmentsServiceServer struct {
+func _AppointmentsService_CreateLocation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateLocationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppointmentsServiceServer).CreateLocation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/appointments.AppointmentsService/CreateLocation",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppointmentsServiceServer).CreateLocation(ctx, req.(*CreateLocationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func (UnimplementedAppointmentsServiceServer) GetAppointment(context.Context, *GetAppointmentRequest) (*GetAppointmentResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method GetAppointment not implemented")
-}
-func (UnimplementedAppointmentsServiceServer) CreateAppointment(context.Context, *CreateAppointmentRequest) (*CreateAppointmentResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method CreateAppointment not implemented")
-}
-func (UnimplementedAppointmentsServiceServer) GetAppointments(context.Context, *GetAppointmentsRequest) (*GetAppointmentsResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method GetAppointments not implemented")
-}
-func (UnimplementedAppointmentsServiceServer) AssignPatient(context.Context, *AssignPatientRequest) (*AssignPatientResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method AssignPatient not implemented")
-}
-func (UnimplementedAppointmentsServiceServer) RemovePatient(context.Context, *RemovePatientRequest) (*RemovePatientResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method RemovePatient not implemented")
-}
-func (UnimplementedAppointmentsServiceServer) DeleteAppointment(context.Context, *DeleteAppointmentRequest) (*DeleteAppointmentResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method DeleteAppointment not implemented")
-}
-func (UnimplementedAppointmentsServiceServer) UpdateAppointment(context.Context, *UpdateAppointmentRequest) (*UpdateAppointmentResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method UpdateAppointment not implemented")
+func _AppointmentsService_UpdateLocation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateLocationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppointmentsServiceServer).UpdateLocation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/appointments.AppointmentsService/UpdateLocation",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppointmentsServiceServer).UpdateLocation(ctx, req.(*UpdateLocationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
-func (UnimplementedAppointmentsServiceServer) mustEmbedUnimplementedAppointmentsServiceServer() {}
 
-// UnsafeAppointmentsServiceServer may be embedded to opt out of forward compatibility for this service.
-// Use of this interface is not recommended, as added methods to AppointmentsServiceServer will
-// result in compilation errors.
-type UnsafeAppointmentsServiceServer interface {
-	mustEmbedUnimplementedAppointmentsServiceServer()
+func _AppointmentsService_DeleteLocation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteLocationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppointmentsServiceServer).DeleteLocation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/appointments.AppointmentsService/DeleteLocation",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppointmentsServiceServer).DeleteLocation(ctx, req.(*DeleteLocationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func RegisterAppointmentsServiceServer(s grpc.ServiceRegistrar, srv AppointmentsServiceServer) {
-	s.RegisterService(&AppointmentsService_ServiceDesc, srv)
+func _AppointmentsService_GetWaitlistEntry_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetWaitlistEntryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppointmentsServiceServer).GetWaitlistEntry(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/appointments.AppointmentsService/GetWaitlistEntry",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppointmentsServiceServer).GetWaitlistEntry(ctx, req.(*GetWaitlistEntryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func _AppointmentsService_GetAppointment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(GetAppointmentRequest)
+func _AppointmentsService_ListWaitlistEntries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListWaitlistEntriesRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(AppointmentsServiceServer).GetAppointment(ctx, in)
+		return srv.(AppointmentsServiceServer).ListWaitlistEntries(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/appointments.AppointmentsService/GetAppointment",
+		FullMethod: "/appointments.AppointmentsService/ListWaitlistEntries",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(AppointmentsServiceServer).GetAppointment(ctx, req.(*GetAppointmentRequest))
+		return srv.(AppointmentsServiceServer).ListWaitlistEntries(ctx, req.(*ListWaitlistEntriesRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _AppointmentsService_CreateAppointment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(CreateAppointmentRequest)
+func _AppointmentsService_CreateWaitlistEntry_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateWaitlistEntryRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(AppointmentsServiceServer).CreateAppointment(ctx, in)
+		return srv.(AppointmentsServiceServer).CreateWaitlistEntry(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/appointments.AppointmentsService/CreateAppointment",
+		FullMethod: "/appointments.AppointmentsService/CreateWaitlistEntry",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(AppointmentsServiceServer).CreateAppointment(ctx, req.(*CreateAppointmentRequest))
+		return srv.(AppointmentsServiceServer).CreateWaitlistEntry(ctx, req.(*CreateWaitlistEntryRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _AppointmentsService_GetAppointments_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(GetAppointmentsRequest)
+func _AppointmentsService_DeleteWaitlistEntry_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteWaitlistEntryRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(AppointmentsServiceServer).GetAppointments(ctx, in)
+		return srv.(AppointmentsServiceServer).DeleteWaitlistEntry(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/appointments.AppointmentsService/GetAppointments",
+		FullMethod: "/appointments.AppointmentsService/DeleteWaitlistEntry",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(AppointmentsServiceServer).GetAppointments(ctx, req.(*GetAppointmentsRequest))
+		return srv.(AppointmentsServiceServer).DeleteWaitlistEntry(ctx, req.(*DeleteWaitlistEntryRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _AppointmentsService_AssignPatient_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(AssignPatientRequest)
+func _AppointmentsService_AcceptWaitlistOffer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AcceptWaitlistOfferRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(AppointmentsServiceServer).AssignPatient(ctx, in)
+		return srv.(AppointmentsServiceServer).AcceptWaitlistOffer(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/appointments.AppointmentsService/AssignPatient",
+		FullMethod: "/appointments.AppointmentsService/AcceptWaitlistOffer",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(AppointmentsServiceServer).AssignPatient(ctx, req.(*AssignPatientRequest))
+		return srv.(AppointmentsServiceServer).AcceptWaitlistOffer(ctx, req.(*AcceptWaitlistOfferRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _AppointmentsService_RemovePatient_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(RemovePatientRequest)
+func _AppointmentsService_DeclineWaitlistOffer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeclineWaitlistOfferRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(AppointmentsServiceServer).RemovePatient(ctx, in)
+		return srv.(AppointmentsServiceServer).DeclineWaitlistOffer(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/appointments.AppointmentsService/RemovePatient",
+		FullMethod: "/appointments.AppointmentsService/DeclineWaitlistOffer",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(AppointmentsServiceServer).RemovePatient(ctx, req.(*RemovePatientRequest))
+		return srv.(AppointmentsServiceServer).DeclineWaitlistOffer(ctx, req.(*DeclineWaitlistOfferRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _AppointmentsService_DeleteAppointment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(DeleteAppointmentRequest)
+func _AppointmentsService_HoldSlot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HoldSlotRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(AppointmentsServiceServer).DeleteAppointment(ctx, in)
+		return srv.(AppointmentsServiceServer).HoldSlot(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/appointments.AppointmentsService/DeleteAppointment",
+		FullMethod: "/appointments.AppointmentsService/HoldSlot",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(AppointmentsServiceServer).DeleteAppointment(ctx, req.(*DeleteAppointmentRequest))
+		return srv.(AppointmentsServiceServer).HoldSlot(ctx, req.(*HoldSlotRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _AppointmentsService_UpdateAppointment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(UpdateAppointmentRequest)
+func _AppointmentsService_ConfirmHold_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConfirmHoldRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(AppointmentsServiceServer).UpdateAppointment(ctx, in)
+		return srv.(AppointmentsServiceServer).ConfirmHold(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/appointments.AppointmentsService/UpdateAppointment",
+		FullMethod: "/appointments.AppointmentsService/ConfirmHold",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(AppointmentsServiceServer).UpdateAppointment(ctx, req.(*UpdateAppointmentRequest))
+		return srv.(AppointmentsServiceServer).ConfirmHold(ctx, req.(*ConfirmHoldRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
@@ -299,6 +1669,10 @@ var AppointmentsService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetAppointments",
 			Handler:    _AppointmentsService_GetAppointments_Handler,
 		},
+		{
+			MethodName: "ListAppointments",
+			Handler:    _AppointmentsService_ListAppointments_Handler,
+		},
 		{
 			MethodName: "AssignPatient",
 			Handler:    _AppointmentsService_AssignPatient_Handler,
@@ -315,7 +1689,173 @@ var AppointmentsService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "UpdateAppointment",
 			Handler:    _AppointmentsService_UpdateAppointment_Handler,
 		},
+		{
+			MethodName: "GetSchedule",
+			Handler:    _AppointmentsService_GetSchedule_Handler,
+		},
+		{
+			MethodName: "CreateSchedule",
+			Handler:    _AppointmentsService_CreateSchedule_Handler,
+		},
+		{
+			MethodName: "UpdateSchedule",
+			Handler:    _AppointmentsService_UpdateSchedule_Handler,
+		},
+		{
+			MethodName: "DeleteSchedule",
+			Handler:    _AppointmentsService_DeleteSchedule_Handler,
+		},
+		{
+			MethodName: "FindAvailableSlots",
+			Handler:    _AppointmentsService_FindAvailableSlots_Handler,
+		},
+		{
+			MethodName: "CreateAppointmentSeries",
+			Handler:    _AppointmentsService_CreateAppointmentSeries_Handler,
+		},
+		{
+			MethodName: "UpdateAppointmentSeries",
+			Handler:    _AppointmentsService_UpdateAppointmentSeries_Handler,
+		},
+		{
+			MethodName: "CancelAppointmentSeries",
+			Handler:    _AppointmentsService_CancelAppointmentSeries_Handler,
+		},
+		{
+			MethodName: "ConfirmAppointment",
+			Handler:    _AppointmentsService_ConfirmAppointment_Handler,
+		},
+		{
+			MethodName: "CheckInAppointment",
+			Handler:    _AppointmentsService_CheckInAppointment_Handler,
+		},
+		{
+			MethodName: "StartAppointment",
+			Handler:    _AppointmentsService_StartAppointment_Handler,
+		},
+		{
+			MethodName: "CompleteAppointment",
+			Handler:    _AppointmentsService_CompleteAppointment_Handler,
+		},
+		{
+			MethodName: "CancelAppointment",
+			Handler:    _AppointmentsService_CancelAppointment_Handler,
+		},
+		{
+			MethodName: "MarkNoShow",
+			Handler:    _AppointmentsService_MarkNoShow_Handler,
+		},
+		{
+			MethodName: "RestoreAppointment",
+			Handler:    _AppointmentsService_RestoreAppointment_Handler,
+		},
+		{
+			MethodName: "GetAppointmentHistory",
+			Handler:    _AppointmentsService_GetAppointmentHistory_Handler,
+		},
+		{
+			MethodName: "ListAuditEvents",
+			Handler:    _AppointmentsService_ListAuditEvents_Handler,
+		},
+		{
+			MethodName: "GetAppointmentType",
+			Handler:    _AppointmentsService_GetAppointmentType_Handler,
+		},
+		{
+			MethodName: "ListAppointmentTypes",
+			Handler:    _AppointmentsService_ListAppointmentTypes_Handler,
+		},
+		{
+			MethodName: "CreateAppointmentType",
+			Handler:    _AppointmentsService_CreateAppointmentType_Handler,
+		},
+		{
+			MethodName: "UpdateAppointmentType",
+			Handler:    _AppointmentsService_UpdateAppointmentType_Handler,
+		},
+		{
+			MethodName: "DeleteAppointmentType",
+			Handler:    _AppointmentsService_DeleteAppointmentType_Handler,
+		},
+		{
+			MethodName: "GetResource",
+			Handler:    _AppointmentsService_GetResource_Handler,
+		},
+		{
+			MethodName: "ListResources",
+			Handler:    _AppointmentsService_ListResources_Handler,
+		},
+		{
+			MethodName: "CreateResource",
+			Handler:    _AppointmentsService_CreateResource_Handler,
+		},
+		{
+			MethodName: "UpdateResource",
+			Handler:    _AppointmentsService_UpdateResource_Handler,
+		},
+		{
+			MethodName: "DeleteResource",
+			Handler:    _AppointmentsService_DeleteResource_Handler,
+		},
+		{
+			MethodName: "GetLocation",
+			Handler:    _AppointmentsService_GetLocation_Handler,
+		},
+		{
+			MethodName: "ListLocations",
+			Handler:    _AppointmentsService_ListLocations_Handler,
+		},
+		{
+			MethodName: "CreateLocation",
+			Handler:    _AppointmentsService_CreateLocation_Handler,
+		},
+		{
+			MethodName: "UpdateLocation",
+			Handler:    _AppointmentsService_UpdateLocation_Handler,
+		},
+		{
+			MethodName: "DeleteLocation",
+			Handler:    _AppointmentsService_DeleteLocation_Handler,
+		},
+		{
+			MethodName: "GetWaitlistEntry",
+			Handler:    _AppointmentsService_GetWaitlistEntry_Handler,
+		},
+		{
+			MethodName: "ListWaitlistEntries",
+			Handler:    _AppointmentsService_ListWaitlistEntries_Handler,
+		},
+		{
+			MethodName: "CreateWaitlistEntry",
+			Handler:    _AppointmentsService_CreateWaitlistEntry_Handler,
+		},
+		{
+			MethodName: "DeleteWaitlistEntry",
+			Handler:    _AppointmentsService_DeleteWaitlistEntry_Handler,
+		},
+		{
+			MethodName: "AcceptWaitlistOffer",
+			Handler:    _AppointmentsService_AcceptWaitlistOffer_Handler,
+		},
+		{
+			MethodName: "DeclineWaitlistOffer",
+			Handler:    _AppointmentsService_DeclineWaitlistOffer_Handler,
+		},
+		{
+			MethodName: "HoldSlot",
+			Handler:    _AppointmentsService_HoldSlot_Handler,
+		},
+		{
+			MethodName: "ConfirmHold",
+			Handler:    _AppointmentsService_ConfirmHold_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchAppointments",
+			Handler:       _AppointmentsService_WatchAppointments_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "appointments_service.proto",
 }
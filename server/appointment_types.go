@@ -0,0 +1,425 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	ppb "github.com/TekClinic/Appointments-MicroService/appointments_protobuf"
+	"github.com/uptrace/bun"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// appointmentTypeNameConstraint is the name of the unique index on the names of appointment types.
+	appointmentTypeNameConstraint = "appointment_types_name_key"
+
+	maxAppointmentTypeNameLength = 100
+	maxAppointmentTypeMinutes    = hoursPerDay * minutesPerHour
+	colorPattern                 = "^#[0-9A-Fa-f]{6}$"
+)
+
+// appointmentTypeRequest is implemented by requests that define an appointment type.
+type appointmentTypeRequest interface {
+	GetName() string
+	GetDurationMinutes() int32
+	GetBufferBeforeMinutes() int32
+	GetBufferAfterMinutes() int32
+	GetDoctorIds() []int32
+	GetSpecialties() []string
+	GetColor() string
+	GetMinLeadTimeMinutes() int32
+	GetMaxHorizonDays() int32
+}
+
+// appointmentTypeFromGRPC validates the type definition of the request and converts it to AppointmentType.
+// Returns codes.InvalidArgument if the definition is invalid.
+func appointmentTypeFromGRPC(req appointmentTypeRequest) (*AppointmentType, error) {
+	name := strings.TrimSpace(req.GetName())
+	if name == "" {
+		return nil, invalidArgumentError("name", "name is required")
+	}
+	if len(name) > maxAppointmentTypeNameLength {
+		return nil, invalidArgumentError("name",
+			fmt.Sprintf("name has to be at most %d characters long", maxAppointmentTypeNameLength))
+	}
+	if req.GetDurationMinutes() <= 0 || req.GetDurationMinutes() > maxAppointmentTypeMinutes {
+		return nil, invalidArgumentError("duration_minutes",
+			fmt.Sprintf("duration has to be between 1 and %d minutes", maxAppointmentTypeMinutes))
+	}
+	for _, limit := range []struct {
+		field string
+		value int32
+	}{
+		{"buffer_before_minutes", req.GetBufferBeforeMinutes()},
+		{"buffer_after_minutes", req.GetBufferAfterMinutes()},
+		{"min_lead_time_minutes", req.GetMinLeadTimeMinutes()},
+		{"max_horizon_days", req.GetMaxHorizonDays()},
+	} {
+		if limit.value < 0 {
+			return nil, invalidArgumentError(limit.field, limit.field+" has to be a non-negative integer")
+		}
+	}
+	if max(req.GetBufferBeforeMinutes(), req.GetBufferAfterMinutes()) > maxAppointmentTypeMinutes {
+		return nil, invalidArgumentError("buffer_before_minutes",
+			fmt.Sprintf("buffers can be at most %d minutes long", maxAppointmentTypeMinutes))
+	}
+	if req.GetColor() != "" {
+		if matched, _ := regexp.MatchString(colorPattern, req.GetColor()); !matched {
+			return nil, invalidArgumentError("color", "color has to be a hex color like #1A2B3C")
+		}
+	}
+
+	doctorIDs := slices.Clone(req.GetDoctorIds())
+	slices.Sort(doctorIDs)
+	doctorIDs = slices.Compact(doctorIDs)
+	if len(doctorIDs) > 0 && doctorIDs[0] <= 0 {
+		return nil, invalidArgumentError("doctor_ids", "DoctorIDs have to be positive values")
+	}
+	specialties := make([]string, 0, len(req.GetSpecialties()))
+	for _, specialty := range req.GetSpecialties() {
+		if specialty = strings.TrimSpace(specialty); specialty == "" {
+			return nil, invalidArgumentError("specialties", "specialties can't be empty")
+		}
+		specialties = append(specialties, specialty)
+	}
+
+	return &AppointmentType{
+		Name:               name,
+		DurationMinutes:    req.GetDurationMinutes(),
+		BufferBefore:       req.GetBufferBeforeMinutes(),
+		BufferAfter:        req.GetBufferAfterMinutes(),
+		DoctorIDs:          doctorIDs,
+		Specialties:        specialties,
+		Color:              strings.ToUpper(req.GetColor()),
+		MinLeadTimeMinutes: req.GetMinLeadTimeMinutes(),
+		MaxHorizonDays:     req.GetMaxHorizonDays(),
+	}, nil
+}
+
+// toGRPC returns a GRPC version of AppointmentType.
+func (appointmentType AppointmentType) toGRPC() *ppb.AppointmentType {
+	return &ppb.AppointmentType{
+		Id:                  appointmentType.ID,
+		Name:                appointmentType.Name,
+		DurationMinutes:     appointmentType.DurationMinutes,
+		BufferBeforeMinutes: appointmentType.BufferBefore,
+		BufferAfterMinutes:  appointmentType.BufferAfter,
+		DoctorIds:           appointmentType.DoctorIDs,
+		Specialties:         appointmentType.Specialties,
+		Color:               appointmentType.Color,
+		MinLeadTimeMinutes:  appointmentType.MinLeadTimeMinutes,
+		MaxHorizonDays:      appointmentType.MaxHorizonDays,
+		Deleted:             !appointmentType.DeletedAt.IsZero(),
+	}
+}
+
+// duration returns the default duration of appointments of the type.
+func (appointmentType AppointmentType) duration() time.Duration {
+	return time.Duration(appointmentType.DurationMinutes) * time.Minute
+}
+
+// buffers returns the time kept free before and after appointments of the type.
+func (appointmentType AppointmentType) buffers() (time.Duration, time.Duration) {
+	return time.Duration(appointmentType.BufferBefore) * time.Minute,
+		time.Duration(appointmentType.BufferAfter) * time.Minute
+}
+
+// allows reports whether the doctor can take appointments of the type.
+func (appointmentType AppointmentType) allows(doctorID int32) bool {
+	return len(appointmentType.DoctorIDs) == 0 || slices.Contains(appointmentType.DoctorIDs, doctorID)
+}
+
+// forType returns the booking rules of appointments of the type,
+// which are the rules of the clinic with the lead time and the horizon of the type if set.
+func (rules bookingRules) forType(appointmentType *AppointmentType) bookingRules {
+	if appointmentType == nil {
+		return rules
+	}
+	if appointmentType.MinLeadTimeMinutes > 0 {
+		rules.minLeadTime = time.Duration(appointmentType.MinLeadTimeMinutes) * time.Minute
+	}
+	if appointmentType.MaxHorizonDays > 0 {
+		rules.maxHorizon = time.Duration(appointmentType.MaxHorizonDays) * hoursPerDay * time.Hour
+	}
+	return rules
+}
+
+// fetchAppointmentType returns the appointment type of the tenant with the given ID that is not deleted.
+// Returns codes.NotFound if there is no such type.
+func fetchAppointmentType(ctx context.Context, db bun.IDB, tenantID string, id int32) (*AppointmentType, error) {
+	appointmentType := new(AppointmentType)
+	err := whereTenant(db.NewSelect().Model(appointmentType), tenantID).
+		Where("? = ?", bun.Ident("id"), id).
+		Scan(ctx)
+	if err != nil {
+		return nil, dbError(err, "failed to fetch an appointment type by id")
+	}
+	return appointmentType, nil
+}
+
+// fetchBookableType returns the appointment type of the tenant with the given ID and checks that the doctor
+// can take it. Returns codes.NotFound if the type doesn't exist and codes.FailedPrecondition
+// if the doctor can't take it.
+func fetchBookableType(ctx context.Context, db bun.IDB,
+	tenantID string, id int32, doctorID int32) (*AppointmentType, error) {
+	if id < 0 {
+		return nil, invalidArgumentError("type_id", "TypeID has to be a non-negative value")
+	}
+	appointmentType, err := fetchAppointmentType(ctx, db, tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+	if !appointmentType.allows(doctorID) {
+		return nil, typeNotAllowedError(appointmentType)
+	}
+	return appointmentType, nil
+}
+
+// typeNotAllowedError returns codes.FailedPrecondition for a doctor that can't take appointments of the type.
+func typeNotAllowedError(appointmentType *AppointmentType) error {
+	return status.Error(codes.FailedPrecondition,
+		fmt.Sprintf("the doctor can't take appointments of type %q", appointmentType.Name))
+}
+
+// fetchTypeOf returns the type of an existing appointment, including a deleted one,
+// since the rules of the type still apply to the appointments booked before it was deleted.
+// Returns nil if the appointment has no type.
+func fetchTypeOf(ctx context.Context, db bun.IDB, appointment *Appointment) (*AppointmentType, error) {
+	types, err := fetchTypesByID(ctx, db, appointment.TenantID, []Appointment{*appointment})
+	if err != nil {
+		return nil, dbError(err, "failed to fetch the appointment type")
+	}
+	appointmentType, ok := types[appointment.TypeID]
+	if !ok {
+		return nil, nil
+	}
+	return &appointmentType, nil
+}
+
+// appointmentEnd returns the end time of a new appointment: the given end time if set,
+// otherwise the start time plus the duration of the appointment type.
+// Returns codes.InvalidArgument if the end time is malformed, or missing without a type.
+func appointmentEnd(endTime string, startTime time.Time, appointmentType *AppointmentType) (time.Time, error) {
+	if endTime == "" && appointmentType != nil {
+		return startTime.Add(appointmentType.duration()), nil
+	}
+	parsed, err := time.Parse(time.RFC3339, endTime)
+	if err != nil {
+		return time.Time{}, invalidArgumentError("end_time", fmt.Errorf("failed to parse end time: %w", err).Error())
+	}
+	return parsed, nil
+}
+
+// fetchTypesByID returns the appointment types of the tenant's appointments by their ID, including deleted types.
+func fetchTypesByID(ctx context.Context, db bun.IDB, tenantID string,
+	appointments []Appointment) (map[int32]AppointmentType, error) {
+	var ids []int32
+	for _, appointment := range appointments {
+		if appointment.TypeID != 0 {
+			ids = append(ids, appointment.TypeID)
+		}
+	}
+	types := make(map[int32]AppointmentType)
+	if len(ids) == 0 {
+		return types, nil
+	}
+
+	var fetched []AppointmentType
+	err := whereTenant(db.NewSelect().Model(&fetched), tenantID).
+		Where("? IN (?)", bun.Ident("id"), bun.In(ids)).
+		WhereAllWithDeleted().
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, appointmentType := range fetched {
+		types[appointmentType.ID] = appointmentType
+	}
+	return types, nil
+}
+
+// GetAppointmentType returns the appointment type with the given ID. Deleted types are returned too,
+// since existing appointments may still refer to them.
+// Requires authentication. If authentication is not valid, codes.Unauthenticated is returned.
+// Requires the read_appointment_types permission, see accessPolicy. If roles are not sufficient,
+// codes.PermissionDenied is returned.
+// If the appointment type with the given ID doesn't exist, codes.NotFound is returned.
+func (server appointmentsServer) GetAppointmentType(ctx context.Context,
+	req *ppb.GetAppointmentTypeRequest) (*ppb.GetAppointmentTypeResponse, error) {
+	caller, err := server.authorize(ctx, req.GetToken(), permReadAppointmentTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	appointmentType := new(AppointmentType)
+	err = runInTenantTx(ctx, server.db, caller.tenantID, func(ctx context.Context, tx bun.Tx) error {
+		return whereTenant(tx.NewSelect().Model(appointmentType), caller.tenantID).
+			Where("? = ?", bun.Ident("id"), req.GetId()).
+			WhereAllWithDeleted().
+			Scan(ctx)
+	})
+	if err != nil {
+		return nil, dbError(err, "failed to fetch an appointment type by id")
+	}
+
+	return &ppb.GetAppointmentTypeResponse{AppointmentType: appointmentType.toGRPC()}, nil
+}
+
+// ListAppointmentTypes returns the appointment types sorted by name.
+// If doctor_id is set, only the types the doctor can take are returned.
+// Requires authentication. If authentication is not valid, codes.Unauthenticated is returned.
+// Requires the read_appointment_types permission, see accessPolicy. If roles are not sufficient,
+// codes.PermissionDenied is returned.
+// If doctor_id, skip or limit are invalid, codes.InvalidArgument is returned.
+func (server appointmentsServer) ListAppointmentTypes(ctx context.Context,
+	req *ppb.ListAppointmentTypesRequest) (*ppb.ListAppointmentTypesResponse, error) {
+	caller, err := server.authorize(ctx, req.GetToken(), permReadAppointmentTypes)
+	if err != nil {
+		return nil, err
+	}
+	if err = validatePagination(req.GetSkip(), req.GetLimit()); err != nil {
+		return nil, err
+	}
+	if req.GetDoctorId() < 0 {
+		return nil, invalidArgumentError("doctor_id", "DoctorID has to be a non-negative value")
+	}
+
+	var appointmentTypes []AppointmentType
+	query := whereTenant(server.db.NewSelect().Model(&appointmentTypes), caller.tenantID)
+	if req.GetDoctorId() != 0 {
+		query = query.Where("cardinality(?) = 0 OR ? = ANY(?)",
+			bun.Ident("doctor_ids"), req.GetDoctorId(), bun.Ident("doctor_ids"))
+	}
+	if req.GetIncludeDeleted() {
+		query = query.WhereAllWithDeleted()
+	}
+	var count int
+	err = runInTenantTx(ctx, server.db, caller.tenantID, func(ctx context.Context, tx bun.Tx) error {
+		var txErr error
+		count, txErr = query.Conn(tx).
+			OrderExpr("lower(?), ?", bun.Ident("name"), bun.Ident("id")).
+			Offset(int(req.GetSkip())).
+			Limit(int(req.GetLimit())).
+			ScanAndCount(ctx)
+		return txErr
+	})
+	if err != nil {
+		return nil, dbError(err, "failed to fetch appointment types")
+	}
+
+	results := make([]*ppb.AppointmentType, len(appointmentTypes))
+	for i, appointmentType := range appointmentTypes {
+		results[i] = appointmentType.toGRPC()
+	}
+	return &ppb.ListAppointmentTypesResponse{Count: int32(count), Results: results}, nil
+}
+
+// CreateAppointmentType adds a type to the catalogue of appointment types.
+// Requires authentication. If authentication is not valid, codes.Unauthenticated is returned.
+// Requires the write_appointment_types permission, see accessPolicy. If roles are not sufficient,
+// codes.PermissionDenied is returned.
+// If the type definition is invalid, codes.InvalidArgument is returned.
+// If another type has the same name, codes.AlreadyExists is returned.
+func (server appointmentsServer) CreateAppointmentType(ctx context.Context,
+	req *ppb.CreateAppointmentTypeRequest) (*ppb.CreateAppointmentTypeResponse, error) {
+	caller, err := server.authorize(ctx, req.GetToken(), permWriteAppointmentTypes)
+	if err != nil {
+		return nil, err
+	}
+	appointmentType, err := appointmentTypeFromGRPC(req)
+	if err != nil {
+		return nil, err
+	}
+	appointmentType.TenantID = caller.tenantID
+
+	err = runInTenantTx(ctx, server.db, caller.tenantID, func(ctx context.Context, tx bun.Tx) error {
+		if _, txErr := tx.NewInsert().Model(appointmentType).Exec(ctx); txErr != nil {
+			return txErr
+		}
+		return recordAudit(ctx, tx, caller.tenantID, auditEntityAppointmentType, appointmentType.ID,
+			tokenActor(req.GetToken()), nil, appointmentType.toGRPC())
+	})
+	if err != nil {
+		return nil, dbError(err, "failed to create an appointment type")
+	}
+
+	return &ppb.CreateAppointmentTypeResponse{Id: appointmentType.ID}, nil
+}
+
+// UpdateAppointmentType replaces the definition of an appointment type.
+// Existing appointments of the type keep their time, the new definition applies to later bookings and slot searches.
+// Requires authentication. If authentication is not valid, codes.Unauthenticated is returned.
+// Requires the write_appointment_types permission, see accessPolicy. If roles are not sufficient,
+// codes.PermissionDenied is returned.
+// If the type definition is invalid, codes.InvalidArgument is returned.
+// If the appointment type with the given ID doesn't exist, codes.NotFound is returned.
+// If another type has the same name, codes.AlreadyExists is returned.
+func (server appointmentsServer) UpdateAppointmentType(ctx context.Context,
+	req *ppb.UpdateAppointmentTypeRequest) (*ppb.UpdateAppointmentTypeResponse, error) {
+	caller, err := server.authorize(ctx, req.GetToken(), permWriteAppointmentTypes)
+	if err != nil {
+		return nil, err
+	}
+	appointmentType, err := appointmentTypeFromGRPC(req)
+	if err != nil {
+		return nil, err
+	}
+	err = runInTenantTx(ctx, server.db, caller.tenantID, func(ctx context.Context, tx bun.Tx) error {
+		existing, txErr := fetchAppointmentType(ctx, tx, caller.tenantID, req.GetId())
+		if txErr != nil {
+			return txErr
+		}
+		appointmentType.ID, appointmentType.TenantID = existing.ID, existing.TenantID
+
+		_, txErr = tx.NewUpdate().
+			Model(appointmentType).
+			WherePK().
+			ExcludeColumn("created_at", "deleted_at").
+			Exec(ctx)
+		if txErr != nil {
+			return txErr
+		}
+		return recordAudit(ctx, tx, caller.tenantID, auditEntityAppointmentType, appointmentType.ID,
+			tokenActor(req.GetToken()), existing.toGRPC(), appointmentType.toGRPC())
+	})
+	if err != nil {
+		return nil, dbError(err, "failed to update an appointment type")
+	}
+
+	return &ppb.UpdateAppointmentTypeResponse{Id: appointmentType.ID}, nil
+}
+
+// DeleteAppointmentType removes a type from the catalogue, after which it can't be booked.
+// Existing appointments of the type are not affected.
+// Requires authentication. If authentication is not valid, codes.Unauthenticated is returned.
+// Requires the write_appointment_types permission, see accessPolicy. If roles are not sufficient,
+// codes.PermissionDenied is returned.
+// If the appointment type with the given ID doesn't exist, codes.NotFound is returned.
+func (server appointmentsServer) DeleteAppointmentType(ctx context.Context,
+	req *ppb.DeleteAppointmentTypeRequest) (*ppb.DeleteAppointmentTypeResponse, error) {
+	caller, err := server.authorize(ctx, req.GetToken(), permWriteAppointmentTypes)
+	if err != nil {
+		return nil, err
+	}
+	err = runInTenantTx(ctx, server.db, caller.tenantID, func(ctx context.Context, tx bun.Tx) error {
+		appointmentType, txErr := fetchAppointmentType(ctx, tx, caller.tenantID, req.GetId())
+		if txErr != nil {
+			return txErr
+		}
+		if _, txErr = tx.NewDelete().Model(appointmentType).WherePK().Exec(ctx); txErr != nil {
+			return txErr
+		}
+		return recordAudit(ctx, tx, caller.tenantID, auditEntityAppointmentType, appointmentType.ID,
+			tokenActor(req.GetToken()), appointmentType.toGRPC(), nil)
+	})
+	if err != nil {
+		return nil, dbError(err, "failed to delete an appointment type")
+	}
+
+	return &ppb.DeleteAppointmentTypeResponse{Message: "Appointment type deleted successfully"}, nil
+}
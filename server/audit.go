@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"slices"
+	"time"
+
+	ppb "github.com/TekClinic/Appointments-MicroService/appointments_protobuf"
+	"github.com/uptrace/bun"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// Entities recorded in the audit trail.
+const (
+	auditEntityAppointment = "appointment"
+	auditEntitySchedule    = "schedule"
+	// auditEntityAppointmentType is an entry of the catalogue of appointment types.
+	auditEntityAppointmentType = "appointment_type"
+	// auditEntityResource is a room or a piece of equipment.
+	auditEntityResource = "resource"
+	// auditEntityLocation is a branch of the clinic.
+	auditEntityLocation = "location"
+	// auditEntityWaitlistEntry is a patient waiting for an earlier appointment.
+	auditEntityWaitlistEntry = "waitlist_entry"
+	// auditEntityWaitlistOffer is a released slot offered to a patient on the waitlist.
+	auditEntityWaitlistOffer = "waitlist_offer"
+	// auditEntitySlotHold is time held for a booking whose patient is not known yet.
+	auditEntitySlotHold = "slot_hold"
+)
+
+// auditActionKey is the context key of the action recorded by the background jobs, see withAuditAction.
+type auditActionKey struct{}
+
+// withAuditAction returns a context whose changes are recorded in the audit trail with the given action.
+// It names the changes made outside of an RPC, e.g. by the background jobs.
+func withAuditAction(ctx context.Context, action string) context.Context {
+	return context.WithValue(ctx, auditActionKey{}, action)
+}
+
+// AuditChange is a field that differs between the snapshots of an audit event.
+// Before and After are JSON-encoded values, null if the field was not set.
+type AuditChange struct {
+	Field  string          `json:"field"`
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+}
+
+// toGRPC returns a GRPC version of AuditChange.
+func (change AuditChange) toGRPC() *ppb.AuditChange {
+	return &ppb.AuditChange{Field: change.Field, Before: string(change.Before), After: string(change.After)}
+}
+
+// toGRPC returns a GRPC version of AuditEvent.
+func (event AuditEvent) toGRPC() *ppb.AuditEvent {
+	changes := make([]*ppb.AuditChange, len(event.Changes))
+	for i, change := range event.Changes {
+		changes[i] = change.toGRPC()
+	}
+	return &ppb.AuditEvent{
+		Id:         event.ID,
+		EntityType: event.EntityType,
+		EntityId:   event.EntityID,
+		Action:     event.Action,
+		Actor:      event.Actor,
+		OccurredAt: event.OccurredAt.Format(time.RFC3339),
+		Changes:    changes,
+		Before:     string(event.Before),
+		After:      string(event.After),
+	}
+}
+
+// withoutField returns the event with the field removed from the snapshots and the changes.
+func (event AuditEvent) withoutField(field string) (AuditEvent, error) {
+	var err error
+	if event.Before, err = deleteSnapshotField(event.Before, field); err != nil {
+		return AuditEvent{}, err
+	}
+	if event.After, err = deleteSnapshotField(event.After, field); err != nil {
+		return AuditEvent{}, err
+	}
+	event.Changes = slices.DeleteFunc(slices.Clone(event.Changes), func(change AuditChange) bool {
+		return change.Field == field
+	})
+	return event, nil
+}
+
+// deleteSnapshotField removes the field from the JSON object. A nil snapshot is returned as nil.
+func deleteSnapshotField(snapshot json.RawMessage, field string) (json.RawMessage, error) {
+	if snapshot == nil {
+		return nil, nil
+	}
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(snapshot, &fields); err != nil {
+		return nil, fmt.Errorf("failed to decode a snapshot: %w", err)
+	}
+	if _, ok := fields[field]; !ok {
+		return snapshot, nil
+	}
+	delete(fields, field)
+	return json.Marshal(fields)
+}
+
+// auditSnapshot returns the fields of the appointment that are recorded in the audit trail.
+// The status history and the deprecated fields derived from the status are left out.
+func (appointment Appointment) auditSnapshot() proto.Message {
+	snapshot := appointment.toGRPC()
+	applyReadMask(snapshot, &fieldmaskpb.FieldMask{Paths: []string{
+		"id", "patient_id", "doctor_id", "start_time", "end_time", "series_id", "type_id", "resource_ids",
+		"location_id", "status", "deleted", "deleted_at", "cancellation_reason", "cancellation_note",
+	}})
+	return snapshot
+}
+
+// encodeSnapshot encodes the snapshot in the protobuf JSON mapping with the field names of the proto file.
+// A nil snapshot is encoded as nil.
+func encodeSnapshot(snapshot proto.Message) (json.RawMessage, error) {
+	if snapshot == nil {
+		return nil, nil
+	}
+	data, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode a snapshot: %w", err)
+	}
+	// protojson output is not stable, so it is compacted for comparison and storage.
+	var compacted bytes.Buffer
+	if err = json.Compact(&compacted, data); err != nil {
+		return nil, fmt.Errorf("failed to encode a snapshot: %w", err)
+	}
+	return compacted.Bytes(), nil
+}
+
+// diffSnapshots returns the fields that differ between two JSON objects, sorted by name.
+// Fields missing from one of the objects are reported with a null value.
+func diffSnapshots(before json.RawMessage, after json.RawMessage) ([]AuditChange, error) {
+	beforeFields := make(map[string]json.RawMessage)
+	afterFields := make(map[string]json.RawMessage)
+	if before != nil {
+		if err := json.Unmarshal(before, &beforeFields); err != nil {
+			return nil, fmt.Errorf("failed to decode a snapshot: %w", err)
+		}
+	}
+	if after != nil {
+		if err := json.Unmarshal(after, &afterFields); err != nil {
+			return nil, fmt.Errorf("failed to decode a snapshot: %w", err)
+		}
+	}
+
+	fields := make([]string, 0, len(beforeFields)+len(afterFields))
+	for field := range beforeFields {
+		fields = append(fields, field)
+	}
+	for field := range afterFields {
+		fields = append(fields, field)
+	}
+	slices.Sort(fields)
+
+	var changes []AuditChange
+	for _, field := range slices.Compact(fields) {
+		beforeValue, afterValue := beforeFields[field], afterFields[field]
+		if bytes.Equal(beforeValue, afterValue) {
+			continue
+		}
+		if beforeValue == nil {
+			beforeValue = json.RawMessage("null")
+		}
+		if afterValue == nil {
+			afterValue = json.RawMessage("null")
+		}
+		changes = append(changes, AuditChange{Field: field, Before: beforeValue, After: afterValue})
+	}
+	return changes, nil
+}
+
+// recordAudit records a mutation of an entity of the tenant in the audit trail, in the transaction
+// that makes the mutation. The action is the name of the RPC being served, or the action of the context
+// outside of an RPC, see withAuditAction. before is nil for created entities, after is nil for entities
+// that no longer exist.
+func recordAudit(ctx context.Context, db bun.IDB, tenantID string, entityType string, entityID int32, actor string,
+	before proto.Message, after proto.Message) error {
+	event := AuditEvent{TenantID: tenantID, EntityType: entityType, EntityID: entityID, Actor: actor}
+	if method, ok := grpc.Method(ctx); ok {
+		event.Action = path.Base(method)
+	} else if action, ok := ctx.Value(auditActionKey{}).(string); ok {
+		event.Action = action
+	}
+
+	var err error
+	if event.Before, err = encodeSnapshot(before); err != nil {
+		return err
+	}
+	if event.After, err = encodeSnapshot(after); err != nil {
+		return err
+	}
+	if event.Changes, err = diffSnapshots(event.Before, event.After); err != nil {
+		return err
+	}
+
+	_, err = db.NewInsert().Model(&event).Exec(ctx)
+	return err
+}
+
+// GetAppointmentHistory returns the audit events of an appointment, oldest first.
+// The history of deleted appointments is available too. The patient_id field is left out of the events
+// for callers who can't access all appointments, since the appointment may have had other patients before.
+// Requires authentication. If authentication is not valid, codes.Unauthenticated is returned.
+// Requires the read_appointments permission, see accessPolicy. If roles are not sufficient
+// or the appointment is not accessible, codes.PermissionDenied is returned.
+// If skip or limit are invalid, codes.InvalidArgument is returned.
+// If the appointment with the given ID doesn't exist, codes.NotFound is returned.
+func (server appointmentsServer) GetAppointmentHistory(ctx context.Context,
+	req *ppb.GetAppointmentHistoryRequest) (*ppb.GetAppointmentHistoryResponse, error) {
+	caller, err := server.authorize(ctx, req.GetToken(), permReadAppointments)
+	if err != nil {
+		return nil, err
+	}
+	if err = validatePagination(req.GetSkip(), req.GetLimit()); err != nil {
+		return nil, err
+	}
+
+	var events []AuditEvent
+	var count int
+	err = runInTenantTx(ctx, server.db, caller.tenantID, func(ctx context.Context, tx bun.Tx) error {
+		appointment := new(Appointment)
+		txErr := whereTenant(tx.NewSelect().Model(appointment), caller.tenantID).
+			Where("? = ?", bun.Ident("id"), req.GetId()).
+			WhereAllWithDeleted().
+			Scan(ctx)
+		if txErr != nil {
+			return dbError(txErr, "failed to fetch an appointment by id")
+		}
+		if txErr = caller.check(appointment); txErr != nil {
+			return txErr
+		}
+
+		count, txErr = whereTenant(tx.NewSelect().Model(&events), caller.tenantID).
+			Where("? = ?", bun.Ident("entity_type"), auditEntityAppointment).
+			Where("? = ?", bun.Ident("entity_id"), appointment.ID).
+			Order("id").
+			Offset(int(req.GetSkip())).
+			Limit(int(req.GetLimit())).
+			ScanAndCount(ctx)
+		return txErr
+	})
+	if err != nil {
+		return nil, dbError(err, "failed to fetch the history of the appointment")
+	}
+
+	response := &ppb.GetAppointmentHistoryResponse{Count: int32(count), Events: make([]*ppb.AuditEvent, len(events))}
+	for i, event := range events {
+		if !caller.all {
+			if event, err = event.withoutField("patient_id"); err != nil {
+				return nil, dbError(err, "failed to redact the history of the appointment")
+			}
+		}
+		response.Events[i] = event.toGRPC()
+	}
+	return response, nil
+}
+
+// ListAuditEvents returns the audit events of all entities that match the filters, newest first.
+// Requires authentication. If authentication is not valid, codes.Unauthenticated is returned.
+// Requires the read_audit permission, see accessPolicy. If roles are not sufficient,
+// codes.PermissionDenied is returned.
+// If one of the filters, skip or limit are invalid, codes.InvalidArgument is returned.
+func (server appointmentsServer) ListAuditEvents(ctx context.Context,
+	req *ppb.ListAuditEventsRequest) (*ppb.ListAuditEventsResponse, error) {
+	caller, err := server.authorize(ctx, req.GetToken(), permReadAudit)
+	if err != nil {
+		return nil, err
+	}
+	if err = validatePagination(req.GetSkip(), req.GetLimit()); err != nil {
+		return nil, err
+	}
+
+	var events []AuditEvent
+	query := whereTenant(server.db.NewSelect().Model(&events), caller.tenantID)
+	switch req.GetEntityType() {
+	case "":
+	case auditEntityAppointment, auditEntitySchedule, auditEntityAppointmentType, auditEntityResource,
+		auditEntityLocation, auditEntityWaitlistEntry, auditEntityWaitlistOffer, auditEntitySlotHold:
+		query = query.Where("? = ?", bun.Ident("entity_type"), req.GetEntityType())
+	default:
+		return nil, invalidArgumentError("entity_type", fmt.Sprintf(
+			"entity type has to be %s, %s, %s, %s, %s, %s, %s or %s",
+			auditEntityAppointment, auditEntitySchedule, auditEntityAppointmentType, auditEntityResource,
+			auditEntityLocation, auditEntityWaitlistEntry, auditEntityWaitlistOffer, auditEntitySlotHold))
+	}
+	if req.GetEntityId() < 0 {
+		return nil, invalidArgumentError("entity_id", "EntityID has to be a non-negative value")
+	}
+	if req.GetEntityId() != 0 {
+		query = query.Where("? = ?", bun.Ident("entity_id"), req.GetEntityId())
+	}
+	if req.GetActor() != "" {
+		query = query.Where("? = ?", bun.Ident("actor"), req.GetActor())
+	}
+	if req.GetAction() != "" {
+		query = query.Where("? = ?", bun.Ident("action"), req.GetAction())
+	}
+	if req.GetFrom() != "" {
+		from, err := time.Parse(time.RFC3339, req.GetFrom())
+		if err != nil {
+			return nil, invalidArgumentError("from", fmt.Errorf("failed to parse from: %w", err).Error())
+		}
+		query = query.Where("? >= ?", bun.Ident("occurred_at"), from)
+	}
+	if req.GetTo() != "" {
+		to, err := time.Parse(time.RFC3339, req.GetTo())
+		if err != nil {
+			return nil, invalidArgumentError("to", fmt.Errorf("failed to parse to: %w", err).Error())
+		}
+		query = query.Where("? < ?", bun.Ident("occurred_at"), to)
+	}
+
+	var count int
+	err = runInTenantTx(ctx, server.db, caller.tenantID, func(ctx context.Context, tx bun.Tx) error {
+		var txErr error
+		count, txErr = query.Conn(tx).
+			OrderExpr("? DESC", bun.Ident("id")).
+			Offset(int(req.GetSkip())).
+			Limit(int(req.GetLimit())).
+			ScanAndCount(ctx)
+		return txErr
+	})
+	if err != nil {
+		return nil, dbError(err, "failed to fetch audit events")
+	}
+
+	response := &ppb.ListAuditEventsResponse{Count: int32(count), Events: make([]*ppb.AuditEvent, len(events))}
+	for i, event := range events {
+		response.Events[i] = event.toGRPC()
+	}
+	return response, nil
+}
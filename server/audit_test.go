@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+// equalChanges reports whether two lists of audit changes are equal.
+func equalChanges(a []AuditChange, b []AuditChange) bool {
+	return slices.EqualFunc(a, b, func(a, b AuditChange) bool {
+		return a.Field == b.Field && bytes.Equal(a.Before, b.Before) && bytes.Equal(a.After, b.After)
+	})
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	tests := []struct {
+		name    string
+		before  json.RawMessage
+		after   json.RawMessage
+		want    []AuditChange
+		wantErr bool
+	}{
+		{
+			name:   "changed",
+			before: json.RawMessage(`{"id":1,"doctor_id":2,"status":"SCHEDULED"}`),
+			after:  json.RawMessage(`{"id":1,"doctor_id":3,"status":"CONFIRMED"}`),
+			want: []AuditChange{
+				{Field: "doctor_id", Before: json.RawMessage(`2`), After: json.RawMessage(`3`)},
+				{Field: "status", Before: json.RawMessage(`"SCHEDULED"`), After: json.RawMessage(`"CONFIRMED"`)},
+			},
+		},
+		{
+			name:   "set and cleared",
+			before: json.RawMessage(`{"id":1,"patient_id":4}`),
+			after:  json.RawMessage(`{"id":1,"deleted":true}`),
+			want: []AuditChange{
+				{Field: "deleted", Before: json.RawMessage(`null`), After: json.RawMessage(`true`)},
+				{Field: "patient_id", Before: json.RawMessage(`4`), After: json.RawMessage(`null`)},
+			},
+		},
+		{
+			name:  "created",
+			after: json.RawMessage(`{"id":1}`),
+			want:  []AuditChange{{Field: "id", Before: json.RawMessage(`null`), After: json.RawMessage(`1`)}},
+		},
+		{
+			name:   "deleted",
+			before: json.RawMessage(`{"id":1}`),
+			want:   []AuditChange{{Field: "id", Before: json.RawMessage(`1`), After: json.RawMessage(`null`)}},
+		},
+		{name: "unchanged", before: json.RawMessage(`{"id":1}`), after: json.RawMessage(`{"id":1}`)},
+		{name: "malformed", before: json.RawMessage(`{"id":`), after: json.RawMessage(`{"id":1}`), wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			changes, err := diffSnapshots(test.before, test.after)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("diffSnapshots() error = %v, want error %v", err, test.wantErr)
+			}
+			if !equalChanges(changes, test.want) {
+				t.Errorf("diffSnapshots() = %v, want %v", changes, test.want)
+			}
+		})
+	}
+}
+
+func TestWithoutField(t *testing.T) {
+	changes := []AuditChange{
+		{Field: "doctor_id", Before: json.RawMessage(`2`), After: json.RawMessage(`3`)},
+		{Field: "patient_id", Before: json.RawMessage(`4`), After: json.RawMessage(`5`)},
+	}
+	tests := []struct {
+		name    string
+		event   AuditEvent
+		want    AuditEvent
+		wantErr bool
+	}{
+		{
+			name: "updated",
+			event: AuditEvent{
+				Before:  json.RawMessage(`{"doctor_id":2,"patient_id":4}`),
+				After:   json.RawMessage(`{"doctor_id":3,"patient_id":5}`),
+				Changes: changes,
+			},
+			want: AuditEvent{
+				Before:  json.RawMessage(`{"doctor_id":2}`),
+				After:   json.RawMessage(`{"doctor_id":3}`),
+				Changes: changes[:1],
+			},
+		},
+		{
+			name:  "created",
+			event: AuditEvent{After: json.RawMessage(`{"id":1,"patient_id":4}`)},
+			want:  AuditEvent{After: json.RawMessage(`{"id":1}`)},
+		},
+		{
+			name:  "field not set",
+			event: AuditEvent{Before: json.RawMessage(`{"id":1}`), After: json.RawMessage(`{"id":1}`)},
+			want:  AuditEvent{Before: json.RawMessage(`{"id":1}`), After: json.RawMessage(`{"id":1}`)},
+		},
+		{name: "malformed", event: AuditEvent{Before: json.RawMessage(`[]`)}, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			event, err := test.event.withoutField("patient_id")
+			if (err != nil) != test.wantErr {
+				t.Fatalf("withoutField() error = %v, want error %v", err, test.wantErr)
+			}
+			if !bytes.Equal(event.Before, test.want.Before) || !bytes.Equal(event.After, test.want.After) {
+				t.Errorf("withoutField() snapshots = %s and %s, want %s and %s",
+					event.Before, event.After, test.want.Before, test.want.After)
+			}
+			if !equalChanges(event.Changes, test.want.Changes) {
+				t.Errorf("withoutField() changes = %v, want %v", event.Changes, test.want.Changes)
+			}
+		})
+	}
+
+	if len(changes) != 2 || changes[1].Field != "patient_id" {
+		t.Errorf("withoutField() modified the changes of the original event: %v", changes)
+	}
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	ppb "github.com/TekClinic/Appointments-MicroService/appointments_protobuf"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+)
+
+const (
+	maxSlotSearchRange   = 31 * 24 * time.Hour
+	maxSlotSearchDoctors = 20
+)
+
+// doctorSlot is a free time range of a doctor.
+type doctorSlot struct {
+	DoctorID int32
+	timeRange
+}
+
+// toGRPC returns a GRPC version of doctorSlot.
+func (slot doctorSlot) toGRPC() *ppb.AvailableSlot {
+	return &ppb.AvailableSlot{
+		DoctorId:  slot.DoctorID,
+		StartTime: slot.Start.Format(time.RFC3339),
+		EndTime:   slot.End.Format(time.RFC3339),
+	}
+}
+
+// splitIntoSlots splits the free ranges into slots of the given duration.
+// Slots start at multiples of granularity counted from midnight UTC, like the start times accepted by
+// bookingRules, so that a 15-minute granularity yields slots starting at :00, :15, :30 and :45.
+// Returns the first limit slots and the number of all slots, which are counted without being built.
+func splitIntoSlots(free []timeRange, duration time.Duration, granularity time.Duration,
+	limit int) ([]timeRange, int) {
+	var slots []timeRange
+	count := 0
+	for _, r := range free {
+		first := alignUp(r.Start, granularity)
+		if first.Add(duration).After(r.End) {
+			continue
+		}
+		count += int(r.End.Sub(first.Add(duration))/granularity) + 1
+		for start := first; len(slots) < limit && !start.Add(duration).After(r.End); start = start.Add(granularity) {
+			slots = append(slots, timeRange{Start: start, End: start.Add(duration)})
+		}
+	}
+	return slots, count
+}
+
+// busyRange returns the time around an existing appointment in which a slot can't start or end.
+// It is the appointment extended by its own buffers and by the buffers of the slot,
+// so that the buffers of neither of them overlap the other one.
+func busyRange(appointment Appointment, types map[int32]AppointmentType, slotType *AppointmentType) timeRange {
+	before, after := types[appointment.TypeID].buffers()
+	if slotType != nil {
+		slotBefore, slotAfter := slotType.buffers()
+		before += slotAfter
+		after += slotBefore
+	}
+	return timeRange{Start: appointment.StartTime.Add(-before), End: appointment.EndTime.Add(after)}
+}
+
+// slotSearch describes the slots searched by findDoctorSlots.
+// Only the schedules and the appointments of the tenant are taken into account.
+// slotType is the type of the searched slots, if any. All resources are required by every slot.
+// Only the first limit slots are built.
+type slotSearch struct {
+	tenantID    string
+	doctorIDs   []int32
+	resourceIDs []int32
+	search      timeRange
+	duration    time.Duration
+	granularity time.Duration
+	slotType    *AppointmentType
+	limit       int
+}
+
+// findDoctorSlots returns the first free slots of the doctors within the search range, sorted by start time
+// and doctor ID, and the number of all free slots.
+// Free time is the working time of a doctor's schedule minus their appointments that are not cancelled
+// and the appointments that use one of the resources, including the buffers of the appointment types.
+// Holds that haven't expired are subtracted like appointments.
+// Doctors without a schedule work all the time, since checkWorkingHours lets them be booked at any time.
+func findDoctorSlots(ctx context.Context, db bun.IDB, params slotSearch) ([]doctorSlot, int, error) {
+	var schedules []Schedule
+	err := whereTenant(db.NewSelect().Model(&schedules), params.tenantID).
+		Where("? IN (?)", bun.Ident("doctor_id"), bun.In(params.doctorIDs)).
+		Scan(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch schedules: %w", err)
+	}
+
+	// Appointments outside of the search range may still reach into it with their buffers.
+	margin := 2 * maxAppointmentTypeMinutes * time.Minute
+	var appointments []Appointment
+	query := db.NewSelect().Model(&appointments).WhereGroup(" AND ", func(query *bun.SelectQuery) *bun.SelectQuery {
+		query = query.Where("? IN (?)", bun.Ident("doctor_id"), bun.In(params.doctorIDs))
+		if len(params.resourceIDs) > 0 {
+			query = query.WhereOr("? && ?", bun.Ident("resource_ids"), pgdialect.Array(params.resourceIDs))
+		}
+		return query
+	})
+	search := params.search
+	around := timeRange{Start: search.Start.Add(-margin), End: search.End.Add(margin)}
+	query = whereOverlaps(whereTenant(query, params.tenantID), around)
+	if err = whereActive(query).Scan(ctx); err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch appointments: %w", err)
+	}
+	// Holds block the time like appointments until they expire.
+	var holds []SlotHold
+	holdQuery := whereHeld(whereTenant(db.NewSelect().Model(&holds), params.tenantID),
+		params.doctorIDs, params.resourceIDs)
+	if err = whereOverlaps(holdQuery, around).Scan(ctx); err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch holds: %w", err)
+	}
+	for _, hold := range holds {
+		appointments = append(appointments, hold.appointment())
+	}
+	types, err := fetchTypesByID(ctx, db, params.tenantID, appointments)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch appointment types: %w", err)
+	}
+	busy := make(map[int32][]timeRange, len(params.doctorIDs))
+	var resourcesBusy []timeRange
+	for _, appointment := range appointments {
+		r := busyRange(appointment, types, params.slotType)
+		busy[appointment.DoctorID] = append(busy[appointment.DoctorID], r)
+		if slices.ContainsFunc(appointment.ResourceIDs, func(id int32) bool {
+			return slices.Contains(params.resourceIDs, id)
+		}) {
+			resourcesBusy = append(resourcesBusy, r)
+		}
+	}
+
+	working := make(map[int32][]timeRange, len(params.doctorIDs))
+	for _, schedule := range schedules {
+		ranges, workingErr := schedule.workingRanges(search)
+		if workingErr != nil {
+			return nil, 0, fmt.Errorf("failed to compute working hours of doctor %d: %w",
+				schedule.DoctorID, workingErr)
+		}
+		working[schedule.DoctorID] = ranges
+	}
+
+	// The first slots overall are among the first slots of every doctor.
+	var slots []doctorSlot
+	count := 0
+	for _, doctorID := range params.doctorIDs {
+		ranges, ok := working[doctorID]
+		if !ok {
+			ranges = []timeRange{search}
+		}
+		free := subtractRanges(ranges, append(busy[doctorID], resourcesBusy...))
+		doctorSlots, doctorCount := splitIntoSlots(free, params.duration, params.granularity, params.limit)
+		for _, slot := range doctorSlots {
+			slots = append(slots, doctorSlot{DoctorID: doctorID, timeRange: slot})
+		}
+		count += doctorCount
+	}
+
+	slices.SortFunc(slots, func(a, b doctorSlot) int {
+		if c := a.Start.Compare(b.Start); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.DoctorID, b.DoctorID)
+	})
+	return slots[:min(params.limit, len(slots))], count, nil
+}
+
+// FindAvailableSlots returns free slots of the given duration of one or several doctors within a time range.
+// Free time is computed from the doctors' schedules minus their existing appointments and the buffers of their types.
+// Slots that start before the minimal lead time or beyond the horizon of the booking rules are left out.
+// Doctors without a schedule are free whenever they have no appointments.
+// If type_id is set, the duration defaults to the duration of the type, the slots keep the buffers of the type free
+// and doctors who can't take the type get no slots. If the type doesn't exist, codes.NotFound is returned.
+// If resource_ids are set, the slots are also free for all of the resources.
+// If one of the resources doesn't exist, codes.NotFound is returned.
+// Requires authentication. If authentication is not valid, codes.Unauthenticated is returned.
+// Requires the find_slots permission, see accessPolicy.
+// If roles are not sufficient, codes.PermissionDenied is returned.
+// If one of the search parameters is invalid or the duration or the granularity of the slots breaks
+// the booking rules, codes.InvalidArgument is returned.
+func (server appointmentsServer) FindAvailableSlots(ctx context.Context,
+	req *ppb.FindAvailableSlotsRequest) (*ppb.FindAvailableSlotsResponse, error) {
+	caller, err := server.authorize(ctx, req.GetToken(), permFindSlots)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = validatePagination(req.GetSkip(), req.GetLimit()); err != nil {
+		return nil, err
+	}
+
+	doctorIDs := slices.Clone(req.GetDoctorIds())
+	slices.Sort(doctorIDs)
+	doctorIDs = slices.Compact(doctorIDs)
+	if len(doctorIDs) == 0 {
+		return nil, invalidArgumentError("doctor_ids", "at least one DoctorID is required")
+	}
+	if len(doctorIDs) > maxSlotSearchDoctors {
+		return nil, invalidArgumentError("doctor_ids",
+			fmt.Sprintf("at most %d doctors can be searched at once", maxSlotSearchDoctors))
+	}
+	if doctorIDs[0] <= 0 {
+		return nil, invalidArgumentError("doctor_ids", "DoctorIDs have to be positive values")
+	}
+
+	from, err := time.Parse(time.RFC3339, req.GetFrom())
+	if err != nil {
+		return nil, invalidArgumentError("from", fmt.Errorf("failed to parse from: %w", err).Error())
+	}
+	to, err := time.Parse(time.RFC3339, req.GetTo())
+	if err != nil {
+		return nil, invalidArgumentError("to", fmt.Errorf("failed to parse to: %w", err).Error())
+	}
+	if !to.After(from) {
+		return nil, invalidArgumentError("to", "to has to be after from")
+	}
+	if to.Sub(from) > maxSlotSearchRange {
+		return nil, invalidArgumentError("to",
+			fmt.Sprintf("the search range can't be longer than %s", maxSlotSearchRange))
+	}
+
+	if req.GetGranularityMinutes() < 0 {
+		return nil, invalidArgumentError("granularity_minutes", "granularity has to be a non-negative integer")
+	}
+
+	var slots []doctorSlot
+	count := 0
+	err = runInTenantTx(ctx, server.db, caller.tenantID, func(ctx context.Context, tx bun.Tx) error {
+		var slotType *AppointmentType
+		var txErr error
+		if req.GetTypeId() != 0 {
+			if slotType, txErr = fetchAppointmentType(ctx, tx, caller.tenantID, req.GetTypeId()); txErr != nil {
+				return txErr
+			}
+			doctorIDs = slices.DeleteFunc(doctorIDs, func(id int32) bool { return !slotType.allows(id) })
+		}
+
+		duration := time.Duration(req.GetDurationMinutes()) * time.Minute
+		if duration == 0 && slotType != nil {
+			duration = slotType.duration()
+		}
+		if duration <= 0 {
+			return invalidArgumentError("duration_minutes", "duration has to be a positive integer")
+		}
+		granularity := time.Duration(req.GetGranularityMinutes()) * time.Minute
+		if granularity == 0 {
+			granularity = duration
+		}
+		rules := server.bookingRules.forType(slotType)
+		if txErr = rules.checkSlotSearch(duration, granularity); txErr != nil {
+			return txErr
+		}
+
+		resourceIDs, txErr := fetchBookableResources(ctx, tx, caller.tenantID, req.GetResourceIds())
+		if txErr != nil {
+			return txErr
+		}
+
+		search := rules.bookableRange(timeRange{Start: from, End: to}, duration, time.Now())
+		if len(doctorIDs) == 0 || !search.End.After(search.Start) {
+			return nil
+		}
+		slots, count, txErr = findDoctorSlots(ctx, tx, slotSearch{
+			tenantID:    caller.tenantID,
+			doctorIDs:   doctorIDs,
+			resourceIDs: resourceIDs,
+			search:      search,
+			duration:    duration,
+			granularity: granularity,
+			slotType:    slotType,
+			limit:       int(req.GetSkip()) + int(req.GetLimit()),
+		})
+		return txErr
+	})
+	if err != nil {
+		return nil, dbError(err, "failed to find available slots")
+	}
+
+	skip := min(int(req.GetSkip()), len(slots))
+	results := make([]*ppb.AvailableSlot, 0, len(slots)-skip)
+	for _, slot := range slots[skip:] {
+		results = append(results, slot.toGRPC())
+	}
+
+	return &ppb.FindAvailableSlotsResponse{
+		Count:   int32(count),
+		Results: results,
+	}, nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSplitIntoSlots(t *testing.T) {
+	at := func(hour int, minute int) time.Time {
+		return time.Date(2030, 1, 7, hour, minute, 0, 0, time.UTC)
+	}
+	slot := func(hour int, minute int, duration time.Duration) timeRange {
+		return timeRange{Start: at(hour, minute), End: at(hour, minute).Add(duration)}
+	}
+	india := time.FixedZone("IST", 5*60*60+30*60)
+
+	tests := []struct {
+		name        string
+		free        []timeRange
+		duration    time.Duration
+		granularity time.Duration
+		limit       int
+		want        []timeRange
+		count       int
+	}{
+		{
+			name:        "aligned to granularity",
+			free:        []timeRange{{Start: at(9, 7), End: at(10, 0)}},
+			duration:    15 * time.Minute,
+			granularity: 15 * time.Minute,
+			limit:       10,
+			want: []timeRange{slot(9, 15, 15*time.Minute), slot(9, 30, 15*time.Minute),
+				slot(9, 45, 15*time.Minute)},
+			count: 3,
+		},
+		{
+			name:        "overlapping slots",
+			free:        []timeRange{{Start: at(9, 0), End: at(10, 0)}},
+			duration:    30 * time.Minute,
+			granularity: 15 * time.Minute,
+			limit:       10,
+			want: []timeRange{slot(9, 0, 30*time.Minute), slot(9, 15, 30*time.Minute),
+				slot(9, 30, 30*time.Minute)},
+			count: 3,
+		},
+		{
+			name:        "limit",
+			free:        []timeRange{{Start: at(9, 0), End: at(10, 0)}},
+			duration:    15 * time.Minute,
+			granularity: 15 * time.Minute,
+			limit:       2,
+			want:        []timeRange{slot(9, 0, 15*time.Minute), slot(9, 15, 15*time.Minute)},
+			count:       4,
+		},
+		{
+			name:        "several ranges",
+			free:        []timeRange{{Start: at(9, 0), End: at(9, 30)}, {Start: at(11, 0), End: at(11, 30)}},
+			duration:    30 * time.Minute,
+			granularity: 30 * time.Minute,
+			limit:       10,
+			want:        []timeRange{slot(9, 0, 30*time.Minute), slot(11, 0, 30*time.Minute)},
+			count:       2,
+		},
+		{
+			name:        "range too short",
+			free:        []timeRange{{Start: at(9, 50), End: at(10, 0)}},
+			duration:    15 * time.Minute,
+			granularity: 5 * time.Minute,
+			limit:       10,
+			count:       0,
+		},
+		{
+			name:        "aligned in UTC",
+			free:        []timeRange{{Start: at(9, 30).In(india), End: at(11, 0).In(india)}},
+			duration:    time.Hour,
+			granularity: time.Hour,
+			limit:       10,
+			want:        []timeRange{slot(10, 0, time.Hour)},
+			count:       1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			slots, count := splitIntoSlots(test.free, test.duration, test.granularity, test.limit)
+			if !slices.EqualFunc(slots, test.want, func(a, b timeRange) bool {
+				return a.Start.Equal(b.Start) && a.End.Equal(b.End)
+			}) {
+				t.Errorf("splitIntoSlots() = %v, want %v", slots, test.want)
+			}
+			if count != test.count {
+				t.Errorf("splitIntoSlots() count = %d, want %d", count, test.count)
+			}
+		})
+	}
+}
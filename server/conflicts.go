@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	ppb "github.com/TekClinic/Appointments-MicroService/appointments_protobuf"
+	"github.com/uptrace/bun"
+	"google.golang.org/grpc/status"
+)
+
+// doctorOverlapConstraint is the name of the exclusion constraint
+// that forbids overlapping appointments of the same doctor.
+const doctorOverlapConstraint = "appointments_doctor_no_overlap"
+
+// overlapPolicy defines how overlapping appointments of the same patient are handled.
+type overlapPolicy string
+
+const (
+	// overlapPolicyReject refuses to book a patient for overlapping appointments.
+	overlapPolicyReject overlapPolicy = "reject"
+	// overlapPolicyWarn books the patient and reports the overlapping appointments as warnings.
+	overlapPolicyWarn overlapPolicy = "warn"
+	// overlapPolicyAllow books the patient without checking other appointments.
+	overlapPolicyAllow overlapPolicy = "allow"
+
+	// warningPatientDoubleBooked is the code of the warning returned for overlapping appointments of a patient.
+	warningPatientDoubleBooked = "PATIENT_DOUBLE_BOOKED"
+)
+
+// parseOverlapPolicy converts a textual policy to overlapPolicy.
+func parseOverlapPolicy(value string) (overlapPolicy, error) {
+	switch policy := overlapPolicy(strings.ToLower(value)); policy {
+	case overlapPolicyReject, overlapPolicyWarn, overlapPolicyAllow:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown overlap policy %q, expected one of %s, %s, %s",
+			value, overlapPolicyReject, overlapPolicyWarn, overlapPolicyAllow)
+	}
+}
+
+// whereOverlaps restricts the query to appointments whose time range overlaps r.
+func whereOverlaps(query *bun.SelectQuery, r timeRange) *bun.SelectQuery {
+	return query.Where("? < ?", bun.Ident("start_time"), r.End).Where("? > ?", bun.Ident("end_time"), r.Start)
+}
+
+// findConflicts returns IDs of the appointments of the tenant whose column equals id and that overlap r.
+// An appointment with ID excludeID is not considered a conflict, which allows checking an updated appointment
+// against the rest of the agenda. Soft-deleted and cancelled appointments are ignored.
+func findConflicts(ctx context.Context, db bun.IDB, tenantID string,
+	column string, id int32, r timeRange, excludeID int32) ([]int32, error) {
+	query := db.NewSelect().
+		Model((*Appointment)(nil)).
+		Column("id").
+		Where("? = ?", bun.Ident(column), id).
+		Where("? != ?", bun.Ident("id"), excludeID).
+		Order("start_time")
+	query = whereActive(whereOverlaps(whereTenant(query, tenantID), r))
+
+	var ids []int32
+	if err := query.Scan(ctx, &ids); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// checkDoctorOverlap returns codes.AlreadyExists if the appointment overlaps another appointment of its doctor.
+func checkDoctorOverlap(ctx context.Context, db bun.IDB, appointment *Appointment) error {
+	ids, err := findConflicts(ctx, db, appointment.TenantID,
+		"doctor_id", appointment.DoctorID, appointment.timeRange(), appointment.ID)
+	if err != nil {
+		return dbError(err, "failed to check doctor availability")
+	}
+	if len(ids) > 0 {
+		return doctorConflictError(ids)
+	}
+	return nil
+}
+
+// checkPatientOverlap applies the policy to the appointments of the assigned patient
+// that overlap the given appointment, regardless of their doctor.
+// If the policy is overlapPolicyReject, codes.AlreadyExists is returned.
+// If the policy is overlapPolicyWarn, the conflicts are returned as warnings to be passed to the client.
+func checkPatientOverlap(ctx context.Context, db bun.IDB,
+	policy overlapPolicy, appointment *Appointment) ([]*ppb.SchedulingWarning, error) {
+	if policy == overlapPolicyAllow || appointment.PatientID == 0 {
+		return nil, nil
+	}
+
+	ids, err := findConflicts(ctx, db, appointment.TenantID,
+		"patient_id", appointment.PatientID, appointment.timeRange(), appointment.ID)
+	if err != nil {
+		return nil, dbError(err, "failed to check patient availability")
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	message := "the patient already has overlapping appointments: " + formatIDs(ids)
+	if policy == overlapPolicyReject {
+		return nil, conflictError(reasonPatientConflict, message, ids)
+	}
+	return []*ppb.SchedulingWarning{{
+		Code:                      warningPatientDoubleBooked,
+		Message:                   message,
+		ConflictingAppointmentIds: ids,
+	}}, nil
+}
+
+// checkAppointment runs all scheduling checks of an appointment that is about to be saved:
+// the doctor's working hours unless ignoreSchedule is set, overlaps with the doctor's other appointments,
+// overlaps with other appointments that book the same resources, overlaps with holds of the doctor
+// or of the resources and the patient overlap policy.
+// Returns the warnings to be passed to the client, or the first failed check.
+func (server appointmentsServer) checkAppointment(ctx context.Context, db bun.IDB,
+	appointment *Appointment, ignoreSchedule bool) ([]*ppb.SchedulingWarning, error) {
+	if err := lockDoctorHolds(ctx, db, appointment.TenantID, appointment.DoctorID); err != nil {
+		return nil, err
+	}
+	if !ignoreSchedule {
+		if err := checkWorkingHours(ctx, db, appointment); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkDoctorOverlap(ctx, db, appointment); err != nil {
+		return nil, err
+	}
+	if err := checkResourceOverlap(ctx, db, appointment); err != nil {
+		return nil, err
+	}
+	if err := checkHoldOverlap(ctx, db, appointment); err != nil {
+		return nil, err
+	}
+	return checkPatientOverlap(ctx, db, server.patientOverlapPolicy, appointment)
+}
+
+// doctorConflictError builds the error returned when an appointment overlaps the given appointments of its doctor.
+func doctorConflictError(ids []int32) error {
+	return conflictError(reasonDoctorConflict, "the doctor already has overlapping appointments: "+formatIDs(ids), ids)
+}
+
+// isOverlapViolation reports whether err was caused by the doctorOverlapConstraint.
+func isOverlapViolation(err error) bool {
+	var pgErr sqlStateError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return pgErr.Field(pgFieldCode) == pgExclusionViolation && pgErr.Field(pgFieldConstraint) == doctorOverlapConstraint
+}
+
+// writeError converts an error returned while saving the appointment to a GRPC error.
+// Errors that are already GRPC errors are returned as is. A violation of the doctorOverlapConstraint,
+// which happens when a concurrent request booked the same time, is reported like a failed overlap check.
+// The conflicts are looked up in a new transaction of the tenant of the appointment, since the failed one is aborted.
+// Any other error is converted by dbError with the given message.
+func writeError(ctx context.Context, db *bun.DB, appointment *Appointment, err error, message string) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if isOverlapViolation(err) {
+		checkErr := runInTenantTx(ctx, db, appointment.TenantID, func(ctx context.Context, tx bun.Tx) error {
+			return checkDoctorOverlap(ctx, tx, appointment)
+		})
+		if checkErr != nil {
+			return dbError(checkErr, "failed to check doctor availability")
+		}
+		return conflictError(reasonDoctorConflict, "the doctor already has an overlapping appointment", nil)
+	}
+	return dbError(err, message)
+}
+
+// formatIDs returns a comma separated list of IDs.
+func formatIDs(ids []int32) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.Itoa(int(id))
+	}
+	return strings.Join(parts, ", ")
+}
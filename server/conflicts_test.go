@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	ppb "github.com/TekClinic/Appointments-MicroService/appointments_protobuf"
+	"github.com/uptrace/bun"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseOverlapPolicy(t *testing.T) {
+	tests := []struct {
+		value   string
+		policy  overlapPolicy
+		wantErr bool
+	}{
+		{"reject", overlapPolicyReject, false},
+		{"warn", overlapPolicyWarn, false},
+		{"allow", overlapPolicyAllow, false},
+		{"WARN", overlapPolicyWarn, false},
+		{"", "", true},
+		{"ignore", "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			policy, err := parseOverlapPolicy(test.value)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("parseOverlapPolicy(%q) error = %v, want error %v", test.value, err, test.wantErr)
+			}
+			if policy != test.policy {
+				t.Errorf("parseOverlapPolicy(%q) = %q, want %q", test.value, policy, test.policy)
+			}
+		})
+	}
+}
+
+func TestCheckPatientOverlapWithoutQuery(t *testing.T) {
+	start := time.Date(2030, 1, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		policy      overlapPolicy
+		appointment *Appointment
+	}{
+		{"allow", overlapPolicyAllow, &Appointment{PatientID: 1, StartTime: start, EndTime: start.Add(time.Hour)}},
+		{"no patient", overlapPolicyReject, &Appointment{StartTime: start, EndTime: start.Add(time.Hour)}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// No database is given, so the check must not look up other appointments.
+			warnings, err := checkPatientOverlap(context.Background(), nil, test.policy, test.appointment)
+			if err != nil || warnings != nil {
+				t.Errorf("checkPatientOverlap() = %v, %v, want no warnings and no error", warnings, err)
+			}
+		})
+	}
+}
+
+// createTestAppointment books the patient with the doctor outside of the doctor's schedule and returns its ID.
+func createTestAppointment(t *testing.T, server appointmentsServer, token string,
+	patientID int32, doctorID int32, start time.Time, end time.Time) int32 {
+	t.Helper()
+	resp, err := server.CreateAppointment(context.Background(), &ppb.CreateAppointmentRequest{
+		Token:          token,
+		PatientId:      patientID,
+		DoctorId:       doctorID,
+		StartTime:      start.Format(time.RFC3339),
+		EndTime:        end.Format(time.RFC3339),
+		IgnoreSchedule: true,
+	})
+	if err != nil {
+		t.Fatalf("failed to create an appointment: %v", err)
+	}
+	return resp.GetId()
+}
+
+func TestCheckPatientOverlapWithDatabase(t *testing.T) {
+	server := newTestServer(t, testDB(t))
+	server.patientOverlapPolicy = overlapPolicyAllow
+	ctx := context.Background()
+	tenantID := testTenant(t)
+	admin := adminToken(t, tenantID)
+
+	const patientID = 1
+	start := time.Now().UTC().Truncate(time.Hour).Add(7 * 24 * time.Hour)
+	booked := createTestAppointment(t, server, admin, patientID, 1, start, start.Add(time.Hour))
+
+	cancelledStart := start.Add(3 * time.Hour)
+	cancelled := createTestAppointment(t, server, admin, patientID, 1, cancelledStart, cancelledStart.Add(time.Hour))
+	_, err := server.CancelAppointment(ctx, &ppb.CancelAppointmentRequest{
+		Token: admin, Id: cancelled, Reason: ppb.CancellationReason_CANCELLATION_REASON_PATIENT_REQUEST})
+	if err != nil {
+		t.Fatalf("failed to cancel an appointment: %v", err)
+	}
+
+	deletedStart := start.Add(6 * time.Hour)
+	deleted := createTestAppointment(t, server, admin, patientID, 1, deletedStart, deletedStart.Add(time.Hour))
+	if _, err = server.DeleteAppointment(ctx, &ppb.DeleteAppointmentRequest{
+		Token: admin, Id: deleted, ExpectedVersion: 1}); err != nil {
+		t.Fatalf("failed to delete an appointment: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		policy    overlapPolicy
+		patientID int32
+		start     time.Time
+		code      codes.Code
+		conflicts []int32
+	}{
+		{"reject overlap", overlapPolicyReject, patientID, start.Add(30 * time.Minute), codes.AlreadyExists, nil},
+		{"warn overlap", overlapPolicyWarn, patientID, start.Add(30 * time.Minute), codes.OK, []int32{booked}},
+		{"allow overlap", overlapPolicyAllow, patientID, start.Add(30 * time.Minute), codes.OK, nil},
+		{"same time", overlapPolicyReject, patientID, start, codes.AlreadyExists, nil},
+		{"touching end", overlapPolicyReject, patientID, start.Add(time.Hour), codes.OK, nil},
+		{"touching start", overlapPolicyReject, patientID, start.Add(-time.Hour), codes.OK, nil},
+		{"another patient", overlapPolicyReject, patientID + 1, start, codes.OK, nil},
+		{"cancelled", overlapPolicyReject, patientID, cancelledStart, codes.OK, nil},
+		{"deleted", overlapPolicyReject, patientID, deletedStart, codes.OK, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			appointment := &Appointment{
+				TenantID:  tenantID,
+				PatientID: test.patientID,
+				DoctorID:  2,
+				StartTime: test.start,
+				EndTime:   test.start.Add(time.Hour),
+			}
+			var warnings []*ppb.SchedulingWarning
+			err := runInTenantTx(ctx, server.db, tenantID, func(ctx context.Context, tx bun.Tx) error {
+				var txErr error
+				warnings, txErr = checkPatientOverlap(ctx, tx, test.policy, appointment)
+				return txErr
+			})
+			if code := status.Code(err); code != test.code {
+				t.Fatalf("code = %v, want %v (%v)", code, test.code, err)
+			}
+			var conflicts []int32
+			for _, warning := range warnings {
+				if warning.GetCode() != warningPatientDoubleBooked {
+					t.Errorf("warning code = %q, want %q", warning.GetCode(), warningPatientDoubleBooked)
+				}
+				conflicts = append(conflicts, warning.GetConflictingAppointmentIds()...)
+			}
+			if !slices.Equal(conflicts, test.conflicts) {
+				t.Errorf("conflicts = %v, want %v", conflicts, test.conflicts)
+			}
+		})
+	}
+
+	// Checking the booked appointment itself, as UpdateAppointment does, is not a conflict.
+	self := &Appointment{ID: booked, TenantID: tenantID, PatientID: patientID, DoctorID: 1,
+		StartTime: start, EndTime: start.Add(time.Hour)}
+	err = runInTenantTx(ctx, server.db, tenantID, func(ctx context.Context, tx bun.Tx) error {
+		_, txErr := checkPatientOverlap(ctx, tx, overlapPolicyReject, self)
+		return txErr
+	})
+	if err != nil {
+		t.Errorf("checkPatientOverlap() of the appointment itself = %v, want nil", err)
+	}
+}
+
+func TestAssignPatientOverlap(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	start := time.Now().UTC().Truncate(time.Hour).Add(7 * 24 * time.Hour)
+
+	tests := []struct {
+		policy   overlapPolicy
+		code     codes.Code
+		warnings int
+	}{
+		{overlapPolicyReject, codes.AlreadyExists, 0},
+		{overlapPolicyWarn, codes.OK, 1},
+		{overlapPolicyAllow, codes.OK, 0},
+	}
+	for _, test := range tests {
+		t.Run(string(test.policy), func(t *testing.T) {
+			server := newTestServer(t, db)
+			server.patientOverlapPolicy = test.policy
+			admin := adminToken(t, testTenant(t))
+
+			createTestAppointment(t, server, admin, 1, 1, start, start.Add(time.Hour))
+			free := createTestAppointment(t, server, admin, 0, 2, start.Add(30*time.Minute), start.Add(90*time.Minute))
+
+			resp, err := server.AssignPatient(ctx, &ppb.AssignPatientRequest{
+				Token: admin, Id: free, PatientId: 1, ExpectedVersion: 1})
+			if code := status.Code(err); code != test.code {
+				t.Fatalf("code = %v, want %v (%v)", code, test.code, err)
+			}
+			if len(resp.GetWarnings()) != test.warnings {
+				t.Errorf("warnings = %v, want %d", resp.GetWarnings(), test.warnings)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"time"
 
 	ppb "github.com/TekClinic/Appointments-MicroService/appointments_protobuf"
@@ -9,35 +10,367 @@ import (
 )
 
 // Appointment defines a schema of appointments.
+// TenantID is the clinic organisation the appointment belongs to, see tenantTables.
+// CancellationReason and CancellationNote are set only while the appointment is cancelled.
+// StatusChanges is populated only when the relation is selected explicitly.
+// Version is incremented by the database on every update, see the appointments_bump_version trigger.
 type Appointment struct {
-	ID                int32 `bun:",pk,autoincrement"`
-	PatientID         int32
-	DoctorID          int32
-	StartTime         time.Time
-	EndTime           time.Time
-	ApprovedByPatient bool
-	Visited           bool
-	CreatedAt         time.Time `bun:",nullzero,notnull,default:current_timestamp"`
-	DeletedAt         time.Time `bun:",soft_delete,nullzero"`
+	ID                 int32  `bun:",pk,autoincrement"`
+	TenantID           string `bun:",notnull"`
+	PatientID          int32
+	DoctorID           int32
+	StartTime          time.Time
+	EndTime            time.Time
+	Status             AppointmentStatus  `bun:",nullzero,notnull,default:'scheduled'"`
+	CancellationReason CancellationReason `bun:",nullzero"`
+	CancellationNote   string             `bun:",nullzero"`
+	SeriesID           int32              `bun:",nullzero"`
+	CreatedAt          time.Time          `bun:",nullzero,notnull,default:current_timestamp"`
+	DeletedAt          time.Time          `bun:",soft_delete,nullzero"`
+	Version            int32              `bun:",nullzero,notnull,default:1"`
+	TypeID             int32              `bun:",nullzero"`
+	ResourceIDs        []int32            `bun:"resource_ids,array"`
+	LocationID         int32              `bun:",nullzero"`
+	StatusChanges      []StatusChange     `bun:"rel:has-many,join:tenant_id=tenant_id,join:id=appointment_id"`
 }
 
 // toGRPC returns a GRPC version of Appointment.
 func (appointment Appointment) toGRPC() *ppb.GetAppointmentResponse {
+	statusChanges := make([]*ppb.StatusChange, len(appointment.StatusChanges))
+	for i, change := range appointment.StatusChanges {
+		statusChanges[i] = change.toGRPC()
+	}
+
 	return &ppb.GetAppointmentResponse{
-		Id:                appointment.ID,
-		PatientId:         appointment.PatientID,
-		DoctorId:          appointment.DoctorID,
-		StartTime:         appointment.StartTime.Format(time.RFC3339),
-		EndTime:           appointment.EndTime.Format(time.RFC3339),
-		ApprovedByPatient: appointment.ApprovedByPatient,
-		Visited:           appointment.Visited,
+		Id:        appointment.ID,
+		PatientId: appointment.PatientID,
+		DoctorId:  appointment.DoctorID,
+		StartTime: appointment.StartTime.Format(time.RFC3339),
+		EndTime:   appointment.EndTime.Format(time.RFC3339),
+		//nolint:staticcheck // deprecated fields are still populated for older clients
+		ApprovedByPatient: appointment.Status.approvedByPatient(),
+		//nolint:staticcheck // deprecated fields are still populated for older clients
+		Visited:            appointment.Status.visited(),
+		SeriesId:           appointment.SeriesID,
+		Status:             appointment.Status.toGRPC(),
+		StatusChanges:      statusChanges,
+		Deleted:            !appointment.DeletedAt.IsZero(),
+		DeletedAt:          formatOptionalTime(appointment.DeletedAt),
+		CancellationReason: appointment.CancellationReason.toGRPC(),
+		CancellationNote:   appointment.CancellationNote,
+		Version:            appointment.Version,
+		Etag:               appointmentETag(appointment.Version),
+		TypeId:             appointment.TypeID,
+		ResourceIds:        appointment.ResourceIDs,
+		LocationId:         appointment.LocationID,
+	}
+}
+
+// formatOptionalTime formats t in RFC 3339, or returns an empty string if t is not set.
+func formatOptionalTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// timeRange returns the time range occupied by the appointment.
+func (appointment Appointment) timeRange() timeRange {
+	return timeRange{Start: appointment.StartTime, End: appointment.EndTime}
+}
+
+// StatusChange defines a schema of appointment status transitions.
+// TenantID is the tenant of the appointment. ChangedBy identifies the user that made the transition.
+type StatusChange struct {
+	ID            int32  `bun:",pk,autoincrement"`
+	TenantID      string `bun:",notnull"`
+	AppointmentID int32  `bun:",notnull"`
+	FromStatus    AppointmentStatus
+	ToStatus      AppointmentStatus
+	ChangedBy     string
+	ChangedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+// toGRPC returns a GRPC version of StatusChange.
+func (change StatusChange) toGRPC() *ppb.StatusChange {
+	return &ppb.StatusChange{
+		FromStatus: change.FromStatus.toGRPC(),
+		ToStatus:   change.ToStatus.toGRPC(),
+		ChangedAt:  change.ChangedAt.Format(time.RFC3339),
+		ChangedBy:  change.ChangedBy,
+	}
+}
+
+// AppointmentEvent defines a schema of appointment changes streamed to watchers, see WatchAppointments.
+// DoctorID, PatientID, StartTime and EndTime are the values of the appointment right after the change
+// and are used to filter the events. FormerDoctorID and FormerPatientID are the values before the change,
+// so that an appointment moved away from a doctor or a patient is reported to them as well.
+// Appointment is a snapshot of the appointment right after the change, without its status history.
+// TransactionID is the ID of the transaction that recorded the event, which orders the events for watchers.
+type AppointmentEvent struct {
+	ID              int64                `bun:",pk,autoincrement"`
+	TransactionID   int64                `bun:",notnull"`
+	TenantID        string               `bun:",notnull"`
+	AppointmentID   int32                `bun:",notnull"`
+	Type            AppointmentEventType `bun:",notnull"`
+	DoctorID        int32
+	PatientID       int32
+	FormerDoctorID  int32
+	FormerPatientID int32
+	StartTime       time.Time
+	EndTime         time.Time
+	Appointment     Appointment `bun:",type:jsonb"`
+	OccurredAt      time.Time   `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+// OutboxMessage defines a schema of domain events waiting to be delivered to other services, see runOutboxRelay.
+// TenantID is the tenant of the appointment. Topic is the kind of the event, Key is the ID of the appointment,
+// Payload is a JSON-encoded domainEvent. A message is pending until PublishedAt is set.
+// Failed deliveries are counted in Attempts and retried after NextAttemptAt.
+type OutboxMessage struct {
+	ID            int64           `bun:",pk,autoincrement"`
+	TenantID      string          `bun:",notnull"`
+	Topic         string          `bun:",notnull"`
+	Key           string          `bun:",notnull"`
+	Payload       json.RawMessage `bun:",type:jsonb,notnull"`
+	Attempts      int32           `bun:",notnull"`
+	LastError     string          `bun:",nullzero"`
+	NextAttemptAt time.Time       `bun:",nullzero,notnull,default:current_timestamp"`
+	PublishedAt   time.Time       `bun:",nullzero"`
+	CreatedAt     time.Time       `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+// AuditEvent defines a schema of the audit trail. Each event is a mutation of an entity made by Actor
+// through the Action RPC. Before and After are JSON snapshots of the entity, Changes lists the fields that differ.
+type AuditEvent struct {
+	ID         int64           `bun:",pk,autoincrement"`
+	TenantID   string          `bun:",notnull"`
+	EntityType string          `bun:",notnull"`
+	EntityID   int32           `bun:",notnull"`
+	Action     string          `bun:",notnull"`
+	Actor      string          `bun:",notnull"`
+	Before     json.RawMessage `bun:",type:jsonb,nullzero"`
+	After      json.RawMessage `bun:",type:jsonb,nullzero"`
+	Changes    []AuditChange   `bun:",type:jsonb"`
+	OccurredAt time.Time       `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+// IdempotencyKey defines a schema of the responses stored for idempotency keys, see idempotencyInterceptor.
+// A key is scoped to the tenant and the Subject of the token and to the Method it was used with.
+// RequestHash identifies the payload of the first request with the key.
+// ResponseType is empty while the first request is in progress.
+type IdempotencyKey struct {
+	TenantID     string    `bun:",pk"`
+	Subject      string    `bun:",pk"`
+	Method       string    `bun:",pk"`
+	Key          string    `bun:",pk"`
+	RequestHash  []byte    `bun:",notnull"`
+	ResponseType string    `bun:",nullzero"`
+	Response     []byte    `bun:",nullzero"`
+	CreatedAt    time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	ExpiresAt    time.Time `bun:",notnull"`
+}
+
+// AppointmentSeries defines a schema of recurring appointments.
+// RRule is expanded in Timezone starting from the first occurrence at StartTime, ExDates are the skipped occurrences.
+// Each occurrence is stored as an Appointment that references the series by SeriesID.
+type AppointmentSeries struct {
+	ID        int32  `bun:",pk,autoincrement"`
+	TenantID  string `bun:",notnull"`
+	PatientID int32
+	DoctorID  int32
+	RRule     string
+	ExDates   []time.Time `bun:",array"`
+	Timezone  string
+	StartTime time.Time `bun:",nullzero"`
+	EndTime   time.Time `bun:",nullzero"`
+	CreatedAt time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	DeletedAt time.Time `bun:",soft_delete,nullzero"`
+}
+
+// AppointmentType defines a schema of the catalogue of appointment kinds, e.g. a 45-minute consult.
+// Durations are in minutes. BufferBefore and BufferAfter are kept free around appointments of the type
+// when searching for slots. DoctorIDs are the doctors that can take the type, all doctors if empty.
+// Specialties match the waitlist entries of the doctors who can take the type.
+// MinLeadTime and MaxHorizon override the booking rules of the clinic if set.
+type AppointmentType struct {
+	ID                 int32     `bun:",pk,autoincrement"`
+	TenantID           string    `bun:",notnull"`
+	Name               string    `bun:",notnull"`
+	DurationMinutes    int32     `bun:",notnull"`
+	BufferBefore       int32     `bun:",notnull"`
+	BufferAfter        int32     `bun:",notnull"`
+	DoctorIDs          []int32   `bun:"doctor_ids,array"`
+	Specialties        []string  `bun:",array"`
+	Color              string    `bun:",nullzero"`
+	MinLeadTimeMinutes int32     `bun:",notnull"`
+	MaxHorizonDays     int32     `bun:",notnull"`
+	CreatedAt          time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	DeletedAt          time.Time `bun:",soft_delete,nullzero"`
+}
+
+// Resource defines a schema of rooms and equipment that are booked together with a doctor.
+type Resource struct {
+	ID        int32        `bun:",pk,autoincrement"`
+	TenantID  string       `bun:",notnull"`
+	Name      string       `bun:",notnull"`
+	Kind      ResourceKind `bun:",notnull"`
+	CreatedAt time.Time    `bun:",nullzero,notnull,default:current_timestamp"`
+	DeletedAt time.Time    `bun:",soft_delete,nullzero"`
+}
+
+// Location defines a schema of the branches of the clinic. Timezone is the IANA name of the local time
+// of the branch, which defines the local day of its appointments.
+type Location struct {
+	ID        int32     `bun:",pk,autoincrement"`
+	TenantID  string    `bun:",notnull"`
+	Name      string    `bun:",notnull"`
+	Timezone  string    `bun:",notnull"`
+	CreatedAt time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	DeletedAt time.Time `bun:",soft_delete,nullzero"`
+}
+
+// WaitlistEntry defines a schema of patients waiting for an earlier appointment.
+// The patient waits for DoctorID, for any doctor who can take an appointment type of Specialty, or for a doctor
+// who matches both if both are set. Windows are the acceptable times, Priority orders the patients, highest first.
+// AppointmentID is set once the patient is booked from an offer, which closes the entry.
+type WaitlistEntry struct {
+	ID            int32            `bun:",pk,autoincrement"`
+	TenantID      string           `bun:",notnull"`
+	PatientID     int32            `bun:",notnull"`
+	DoctorID      int32            `bun:",nullzero"`
+	Specialty     string           `bun:",nullzero"`
+	Windows       []WaitlistWindow `bun:",type:jsonb"`
+	Priority      int32            `bun:",notnull"`
+	AppointmentID int32            `bun:",nullzero"`
+	CreatedAt     time.Time        `bun:",nullzero,notnull,default:current_timestamp"`
+	DeletedAt     time.Time        `bun:",soft_delete,nullzero"`
+}
+
+// WaitlistWindow is a time range in which a patient on the waitlist can come.
+type WaitlistWindow struct {
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+}
+
+// WaitlistOffer defines a schema of the slots offered to patients on the waitlist.
+// AppointmentID is set if the slot is an appointment without a patient, otherwise accepting the offer
+// creates an appointment. A pending offer is valid until ExpiresAt.
+type WaitlistOffer struct {
+	ID            int32               `bun:",pk,autoincrement"`
+	TenantID      string              `bun:",notnull"`
+	EntryID       int32               `bun:",notnull"`
+	DoctorID      int32               `bun:",notnull"`
+	StartTime     time.Time           `bun:",notnull"`
+	EndTime       time.Time           `bun:",notnull"`
+	AppointmentID int32               `bun:",nullzero"`
+	Status        WaitlistOfferStatus `bun:",notnull"`
+	ExpiresAt     time.Time           `bun:",notnull"`
+	CreatedAt     time.Time           `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+// SlotHold defines a schema of the tentative reservations of a doctor's time made before the patient is known,
+// e.g. while a patient fills in the online booking form. A hold blocks the time like an appointment
+// until ExpiresAt, and HeldBy is the actor who can book a patient for it.
+type SlotHold struct {
+	ID          int32     `bun:",pk,autoincrement"`
+	TenantID    string    `bun:",notnull"`
+	DoctorID    int32     `bun:",notnull"`
+	StartTime   time.Time `bun:",notnull"`
+	EndTime     time.Time `bun:",notnull"`
+	TypeID      int32     `bun:",nullzero"`
+	ResourceIDs []int32   `bun:"resource_ids,array"`
+	LocationID  int32     `bun:",nullzero"`
+	HeldBy      string    `bun:",notnull"`
+	ExpiresAt   time.Time `bun:",notnull"`
+	CreatedAt   time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+// Schedule defines a schema of doctor working hours.
+// WeeklyHours repeat every week in the schedule's Timezone, Breaks are subtracted from the weekly hours
+// of the same weekday. Overrides replace both on specific dates, an override without hours marks a day off.
+type Schedule struct {
+	ID          int32              `bun:",pk,autoincrement"`
+	TenantID    string             `bun:",notnull"`
+	DoctorID    int32              `bun:",notnull"`
+	Timezone    string             `bun:",notnull"`
+	WeeklyHours []WeeklyHours      `bun:",type:jsonb"`
+	Breaks      []WeeklyHours      `bun:",type:jsonb"`
+	Overrides   []ScheduleOverride `bun:",type:jsonb"`
+	CreatedAt   time.Time          `bun:",nullzero,notnull,default:current_timestamp"`
+	DeletedAt   time.Time          `bun:",soft_delete,nullzero"`
+}
+
+// WeeklyHours is an interval of a weekday. StartTime and EndTime use the clockFormat, EndTime may be 24:00.
+type WeeklyHours struct {
+	Weekday   time.Weekday `json:"weekday"`
+	StartTime string       `json:"start_time"`
+	EndTime   string       `json:"end_time"`
+}
+
+// DailyHours is an interval of a single day. StartTime and EndTime use the clockFormat, EndTime may be 24:00.
+type DailyHours struct {
+	StartTime string `json:"start_time"`
+	EndTime   string `json:"end_time"`
+}
+
+// ScheduleOverride replaces the weekly hours of a schedule on the given Date.
+type ScheduleOverride struct {
+	Date  string       `json:"date"`
+	Hours []DailyHours `json:"hours"`
+}
+
+// toGRPC returns a GRPC version of Schedule.
+func (schedule Schedule) toGRPC() *ppb.GetScheduleResponse {
+	overrides := make([]*ppb.ScheduleOverride, len(schedule.Overrides))
+	for i, override := range schedule.Overrides {
+		hours := make([]*ppb.DailyHours, len(override.Hours))
+		for j, interval := range override.Hours {
+			hours[j] = &ppb.DailyHours{StartTime: interval.StartTime, EndTime: interval.EndTime}
+		}
+		overrides[i] = &ppb.ScheduleOverride{Date: override.Date, Hours: hours}
+	}
+
+	return &ppb.GetScheduleResponse{
+		Id:          schedule.ID,
+		DoctorId:    schedule.DoctorID,
+		Timezone:    schedule.Timezone,
+		WeeklyHours: weeklyHoursToGRPC(schedule.WeeklyHours),
+		Breaks:      weeklyHoursToGRPC(schedule.Breaks),
+		Overrides:   overrides,
+	}
+}
+
+// weeklyHoursToGRPC returns a GRPC version of WeeklyHours.
+func weeklyHoursToGRPC(intervals []WeeklyHours) []*ppb.WeeklyHours {
+	result := make([]*ppb.WeeklyHours, len(intervals))
+	for i, interval := range intervals {
+		result[i] = &ppb.WeeklyHours{
+			Weekday:   int32(interval.Weekday),
+			StartTime: interval.StartTime,
+			EndTime:   interval.EndTime,
+		}
 	}
+	return result
 }
 
 // createSchemaIfNotExists creates all required schemas for appointment microservice.
 func createSchemaIfNotExists(ctx context.Context, db *bun.DB) error {
 	models := []interface{}{
 		(*Appointment)(nil),
+		(*Schedule)(nil),
+		(*AppointmentSeries)(nil),
+		(*StatusChange)(nil),
+		(*AppointmentEvent)(nil),
+		(*OutboxMessage)(nil),
+		(*AuditEvent)(nil),
+		(*IdempotencyKey)(nil),
+		(*AppointmentType)(nil),
+		(*Resource)(nil),
+		(*Location)(nil),
+		(*WaitlistEntry)(nil),
+		(*WaitlistOffer)(nil),
+		(*SlotHold)(nil),
 	}
 
 	for _, model := range models {
@@ -46,13 +379,181 @@ func createSchemaIfNotExists(ctx context.Context, db *bun.DB) error {
 		}
 	}
 
-	// Migration code. Add created_at and deleted_at columns to the patient table for soft delete.
-	if _, err := db.NewRaw(
+	// Migration code. Migrations run in order on every start, so each of them has to be idempotent.
+	migrations := []string{
+		// Add created_at and deleted_at columns to the appointments table for soft delete.
 		"ALTER TABLE appointments " +
 			"ADD COLUMN IF NOT EXISTS created_at timestamptz NOT NULL DEFAULT now(), " +
-			"ADD COLUMN IF NOT EXISTS deleted_at timestamptz;").Exec(ctx); err != nil {
-		return err
+			"ADD COLUMN IF NOT EXISTS deleted_at timestamptz;",
+
+		// Link appointments to the recurring series they were created from.
+		"ALTER TABLE appointments " +
+			"ADD COLUMN IF NOT EXISTS series_id integer;",
+
+		// Replace approved_by_patient and visited flags with status.
+		"ALTER TABLE appointments " +
+			"ADD COLUMN IF NOT EXISTS status varchar NOT NULL DEFAULT 'scheduled';",
+		"DO $$ BEGIN " +
+			"IF EXISTS (SELECT 1 FROM information_schema.columns " +
+			"WHERE table_name = 'appointments' AND column_name = 'visited') THEN " +
+			"UPDATE appointments SET status = CASE " +
+			"WHEN visited THEN '" + string(StatusCompleted) + "' " +
+			"WHEN approved_by_patient THEN '" + string(StatusConfirmed) + "' " +
+			"ELSE '" + string(StatusScheduled) + "' END; " +
+			"ALTER TABLE appointments DROP COLUMN approved_by_patient, DROP COLUMN visited; " +
+			"END IF; END $$;",
+
+		// Record why an appointment was cancelled.
+		"ALTER TABLE appointments " +
+			"ADD COLUMN IF NOT EXISTS cancellation_reason varchar, " +
+			"ADD COLUMN IF NOT EXISTS cancellation_note varchar;",
+
+		// Forbid overlapping appointments of the same doctor on the database level, so that concurrent requests
+		// cannot double-book a doctor. Deleted and cancelled appointments are not taken into account.
+		// The constraint created before statuses were introduced is replaced.
+		"CREATE EXTENSION IF NOT EXISTS btree_gist;",
+		"DO $$ BEGIN " +
+			"IF EXISTS (SELECT 1 FROM pg_constraint WHERE conname = '" + doctorOverlapConstraint + "' " +
+			"AND pg_get_constraintdef(oid) NOT LIKE '%status%') THEN " +
+			"ALTER TABLE appointments DROP CONSTRAINT " + doctorOverlapConstraint + "; " +
+			"END IF; END $$;",
+		"DO $$ BEGIN " +
+			"IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = '" + doctorOverlapConstraint + "') THEN " +
+			"ALTER TABLE appointments ADD CONSTRAINT " + doctorOverlapConstraint + " " +
+			"EXCLUDE USING gist (doctor_id WITH =, tstzrange(start_time, end_time) WITH &&) " +
+			"WHERE (deleted_at IS NULL AND status <> '" + string(StatusCancelled) + "'); " +
+			"END IF; END $$;",
+
+		// Keyset pagination walks appointments in the order of the sort column and the ID.
+		"CREATE INDEX IF NOT EXISTS appointments_start_time_id_idx ON appointments (start_time, id);",
+		"CREATE INDEX IF NOT EXISTS appointments_created_at_id_idx ON appointments (created_at, id);",
+
+		// Events older than the retention period are pruned by their time.
+		"CREATE INDEX IF NOT EXISTS appointment_events_occurred_at_idx ON appointment_events (occurred_at);",
+
+		// The relay reads pending outbox messages in order.
+		"CREATE INDEX IF NOT EXISTS outbox_messages_pending_idx ON outbox_messages (id) WHERE published_at IS NULL;",
+
+		// The history of an entity is read by its type and ID.
+		"CREATE INDEX IF NOT EXISTS audit_events_entity_idx ON audit_events (entity_type, entity_id, id);",
+
+		// Version appointments for optimistic concurrency. Every update increments the version,
+		// so that a client holding an older version can't overwrite a newer change.
+		"ALTER TABLE appointments ADD COLUMN IF NOT EXISTS version integer NOT NULL DEFAULT 1;",
+		"CREATE OR REPLACE FUNCTION appointments_bump_version() RETURNS trigger AS $$ " +
+			"BEGIN NEW.version := OLD.version + 1; RETURN NEW; END; $$ LANGUAGE plpgsql;",
+		"DO $$ BEGIN " +
+			"IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'appointments_bump_version') THEN " +
+			"CREATE TRIGGER appointments_bump_version BEFORE UPDATE ON appointments " +
+			"FOR EACH ROW EXECUTE FUNCTION appointments_bump_version(); " +
+			"END IF; END $$;",
+
+		// Expired idempotency keys are pruned by their expiration time.
+		"CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);",
+
+		// Appointments may have a type from the catalogue. Names of types that are not deleted are unique.
+		"ALTER TABLE appointments ADD COLUMN IF NOT EXISTS type_id integer;",
+		"CREATE UNIQUE INDEX IF NOT EXISTS " + appointmentTypeNameConstraint + " " +
+			"ON appointment_types (lower(name)) WHERE deleted_at IS NULL;",
+
+		// Appointments may book rooms and equipment. Overlaps are looked up by the resources of the appointments.
+		// Names of resources that are not deleted are unique.
+		"ALTER TABLE appointments ADD COLUMN IF NOT EXISTS resource_ids integer[];",
+		"CREATE INDEX IF NOT EXISTS appointments_resource_ids_idx ON appointments USING gin (resource_ids);",
+		"CREATE UNIQUE INDEX IF NOT EXISTS " + resourceNameConstraint + " " +
+			"ON resources (lower(name)) WHERE deleted_at IS NULL;",
+
+		// Appointments may take place at a branch of the clinic. Names of locations that are not deleted are unique.
+		"ALTER TABLE appointments ADD COLUMN IF NOT EXISTS location_id integer;",
+		"CREATE INDEX IF NOT EXISTS appointments_location_id_idx ON appointments (location_id);",
+		"CREATE UNIQUE INDEX IF NOT EXISTS " + locationNameConstraint + " " +
+			"ON locations (lower(name)) WHERE deleted_at IS NULL;",
+
+		// A doctor has at most one schedule that is not deleted.
+		"CREATE UNIQUE INDEX IF NOT EXISTS schedules_doctor_id_key " +
+			"ON schedules (doctor_id) WHERE deleted_at IS NULL;",
 	}
 
-	return nil
+	// Scope the records to tenants, see tenantTables. Overlaps of doctors and unique names are checked
+	// within each tenant, so the constraint and the unique indexes created before are replaced.
+	for _, table := range tenantTables() {
+		migrations = append(migrations, tenantMigrations(table)...)
+	}
+	migrations = append(migrations,
+		// The status changes and the outbox messages recorded before they had a tenant take the tenant
+		// of their appointment. Idempotency keys are scoped to the tenant by their primary key.
+		"UPDATE status_changes SET tenant_id = appointments.tenant_id FROM appointments "+
+			"WHERE appointments.id = status_changes.appointment_id "+
+			"AND status_changes.tenant_id <> appointments.tenant_id;",
+		"UPDATE outbox_messages SET tenant_id = payload->>'tenant_id' "+
+			"WHERE tenant_id <> payload->>'tenant_id';",
+		"CREATE INDEX IF NOT EXISTS status_changes_appointment_id_idx ON status_changes (tenant_id, appointment_id);",
+		"DO $$ BEGIN "+
+			"IF EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'idempotency_keys_pkey' "+
+			"AND pg_get_constraintdef(oid) NOT LIKE '%tenant_id%') THEN "+
+			"ALTER TABLE idempotency_keys DROP CONSTRAINT idempotency_keys_pkey, "+
+			"ADD PRIMARY KEY (tenant_id, subject, method, key); "+
+			"END IF; END $$;",
+	)
+	migrations = append(migrations,
+		"DO $$ BEGIN "+
+			"IF EXISTS (SELECT 1 FROM pg_constraint WHERE conname = '"+doctorOverlapConstraint+"' "+
+			"AND pg_get_constraintdef(oid) NOT LIKE '%tenant_id%') THEN "+
+			"ALTER TABLE appointments DROP CONSTRAINT "+doctorOverlapConstraint+"; "+
+			"END IF; END $$;",
+		"DO $$ BEGIN "+
+			"IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = '"+doctorOverlapConstraint+"') THEN "+
+			"ALTER TABLE appointments ADD CONSTRAINT "+doctorOverlapConstraint+" "+
+			"EXCLUDE USING gist (tenant_id WITH =, doctor_id WITH =, tstzrange(start_time, end_time) WITH &&) "+
+			"WHERE (deleted_at IS NULL AND status <> '"+string(StatusCancelled)+"'); "+
+			"END IF; END $$;",
+	)
+	migrations = append(migrations, tenantIndexMigrations("schedules_doctor_id_key", "schedules",
+		"doctor_id", "deleted_at IS NULL")...)
+	migrations = append(migrations, tenantIndexMigrations(appointmentTypeNameConstraint, "appointment_types",
+		"lower(name)", "deleted_at IS NULL")...)
+	migrations = append(migrations, tenantIndexMigrations(resourceNameConstraint, "resources",
+		"lower(name)", "deleted_at IS NULL")...)
+	migrations = append(migrations, tenantIndexMigrations(locationNameConstraint, "locations",
+		"lower(name)", "deleted_at IS NULL")...)
+
+	migrations = append(migrations,
+		// Released slots are offered to the open waitlist entries of the doctor. A patient has at most
+		// one pending offer per entry, and the pending offers are expired by their expiration time.
+		"CREATE INDEX IF NOT EXISTS waitlist_entries_open_idx ON waitlist_entries (tenant_id, doctor_id) "+
+			"WHERE deleted_at IS NULL AND appointment_id IS NULL;",
+		"CREATE UNIQUE INDEX IF NOT EXISTS waitlist_offers_pending_key ON waitlist_offers (entry_id) "+
+			"WHERE status = '"+string(WaitlistOfferPending)+"';",
+		"CREATE INDEX IF NOT EXISTS waitlist_offers_expires_at_idx ON waitlist_offers (expires_at) "+
+			"WHERE status = '"+string(WaitlistOfferPending)+"';",
+
+		// Holds are checked like appointments of the doctor and released by their expiration time.
+		"CREATE INDEX IF NOT EXISTS slot_holds_doctor_id_idx ON slot_holds (tenant_id, doctor_id, start_time);",
+		"CREATE INDEX IF NOT EXISTS slot_holds_expires_at_idx ON slot_holds (expires_at);",
+
+		// Watchers read the events by the transactions that recorded them, see sendEvents.
+		// The events recorded before come first, in the order of their IDs.
+		"ALTER TABLE appointment_events ADD COLUMN IF NOT EXISTS transaction_id bigint NOT NULL DEFAULT 0;",
+		"CREATE INDEX IF NOT EXISTS appointment_events_position_idx ON appointment_events (transaction_id, id);",
+
+		// Series record their first occurrence, so that they can be updated and split.
+		// The series created before take the time of their earliest occurrence.
+		"ALTER TABLE appointment_series "+
+			"ADD COLUMN IF NOT EXISTS start_time timestamptz, "+
+			"ADD COLUMN IF NOT EXISTS end_time timestamptz;",
+		"UPDATE appointment_series SET start_time = first.start_time, end_time = first.end_time "+
+			"FROM (SELECT DISTINCT ON (series_id) series_id, start_time, end_time FROM appointments "+
+			"WHERE series_id IS NOT NULL ORDER BY series_id, start_time) AS first "+
+			"WHERE appointment_series.id = first.series_id AND appointment_series.start_time IS NULL;",
+	)
+
+	// Migrations update the rows of all tenants, so they bypass the row-level security policies.
+	return runInSystemTx(ctx, db, func(ctx context.Context, tx bun.Tx) error {
+		for _, migration := range migrations {
+			if _, err := tx.NewRaw(migration).Exec(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// errorDomain is the domain of the ErrorInfo details attached to the errors of the service.
+const errorDomain = "appointments.tekclinic"
+
+// Reasons of the ErrorInfo details attached to database errors.
+const (
+	reasonNotFound             = "NOT_FOUND"
+	reasonUniqueViolation      = "UNIQUE_VIOLATION"
+	reasonExclusionViolation   = "EXCLUSION_VIOLATION"
+	reasonSerializationFailure = "SERIALIZATION_FAILURE"
+	reasonCanceled             = "CANCELED"
+	reasonDeadlineExceeded     = "DEADLINE_EXCEEDED"
+	reasonUnavailable          = "UNAVAILABLE"
+	reasonInternal             = "INTERNAL"
+	reasonVersionMismatch      = "VERSION_MISMATCH"
+	reasonRequestInProgress    = "REQUEST_IN_PROGRESS"
+)
+
+// Reasons of the ErrorInfo details attached to scheduling conflicts, see conflictError.
+const (
+	reasonDoctorConflict   = "DOCTOR_CONFLICT"
+	reasonPatientConflict  = "PATIENT_CONFLICT"
+	reasonResourceConflict = "RESOURCE_CONFLICT"
+	reasonHoldConflict     = "HOLD_CONFLICT"
+
+	// conflictingIDsKey is the metadata key of the comma-separated IDs of the conflicting records.
+	conflictingIDsKey = "conflicting_ids"
+)
+
+// SQLSTATE codes PostgreSQL returns for the errors that are not internal.
+const (
+	pgUniqueViolation      = "23505"
+	pgExclusionViolation   = "23P01"
+	pgSerializationFailure = "40001"
+	pgDeadlockDetected     = "40P01"
+
+	// pgConnectionException is the class of the SQLSTATE codes of connection failures.
+	pgConnectionException = "08"
+	pgTooManyConnections  = "53300"
+	pgAdminShutdown       = "57P01"
+	pgCrashShutdown       = "57P02"
+	pgCannotConnectNow    = "57P03"
+
+	// pgFieldCode and pgFieldConstraint are the keys of the SQLSTATE code and the constraint name in a pgdriver.Error.
+	pgFieldCode       = 'C'
+	pgFieldConstraint = 'n'
+)
+
+// sqlStateError is an error reported by PostgreSQL, such as a pgdriver.Error, whose fields are looked up by key.
+type sqlStateError interface {
+	error
+	Field(k byte) string
+}
+
+// withDetails builds a GRPC error with the given details.
+// If the details can't be attached, the error is returned without them.
+func withDetails(code codes.Code, message string, details ...protoadapt.MessageV1) error {
+	st := status.New(code, message)
+	if detailed, err := st.WithDetails(details...); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// errorInfo builds an ErrorInfo detail of the service with the given reason.
+func errorInfo(reason string, metadata map[string]string) *errdetails.ErrorInfo {
+	return &errdetails.ErrorInfo{Reason: reason, Domain: errorDomain, Metadata: metadata}
+}
+
+// notFoundError builds a codes.NotFound error with the given message.
+func notFoundError(message string) error {
+	return withDetails(codes.NotFound, message, errorInfo(reasonNotFound, nil))
+}
+
+// conflictError builds a codes.AlreadyExists error for a booking that overlaps the records with the given IDs.
+// The reason tells the kind of the conflict, so that clients don't have to parse the message.
+func conflictError(reason string, message string, ids []int32) error {
+	return withDetails(codes.AlreadyExists, message,
+		errorInfo(reason, map[string]string{conflictingIDsKey: formatIDs(ids)}))
+}
+
+// invalidArgumentError builds a codes.InvalidArgument error with a BadRequest detail
+// that points to the invalid field of the request.
+func invalidArgumentError(field string, description string) error {
+	return badRequestError([]*errdetails.BadRequest_FieldViolation{{Field: field, Description: description}})
+}
+
+// badRequestError builds a codes.InvalidArgument error with a BadRequest detail listing all the violations.
+// The message of the error is the description of the first violation.
+func badRequestError(violations []*errdetails.BadRequest_FieldViolation) error {
+	return withDetails(codes.InvalidArgument, violations[0].GetDescription(),
+		&errdetails.BadRequest{FieldViolations: violations})
+}
+
+// dbError converts an error returned while working with the database to a GRPC error.
+// Errors that are already GRPC errors are returned as is. Otherwise, the message describes the failed operation:
+//   - a missing row is reported as codes.NotFound;
+//   - a violation of a unique or an exclusion constraint is reported as codes.AlreadyExists;
+//   - a serialization failure or a deadlock is reported as codes.Aborted, so that the client can retry;
+//   - a cancelled or expired context is reported as codes.Canceled or codes.DeadlineExceeded;
+//   - a lost or refused connection and a database that is shutting down or out of connections
+//     are reported as codes.Unavailable, so that the client can retry;
+//   - any other error is reported as codes.Internal.
+func dbError(err error, message string) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return withDetails(codes.NotFound, message+": not found", errorInfo(reasonNotFound, nil))
+	case errors.Is(err, context.Canceled):
+		return withDetails(codes.Canceled, message+": request was cancelled", errorInfo(reasonCanceled, nil))
+	case errors.Is(err, context.DeadlineExceeded):
+		return withDetails(codes.DeadlineExceeded, message+": deadline exceeded",
+			errorInfo(reasonDeadlineExceeded, nil))
+	case isConnectionError(err):
+		return withDetails(codes.Unavailable, message+": database is unavailable, please retry",
+			errorInfo(reasonUnavailable, nil))
+	}
+
+	var pgErr sqlStateError
+	if errors.As(err, &pgErr) {
+		metadata := map[string]string{"constraint": pgErr.Field(pgFieldConstraint)}
+		switch pgErr.Field(pgFieldCode) {
+		case pgUniqueViolation:
+			return withDetails(codes.AlreadyExists, message+": a conflicting record already exists",
+				errorInfo(reasonUniqueViolation, metadata))
+		case pgExclusionViolation:
+			return withDetails(codes.AlreadyExists, message+": a conflicting record already exists",
+				errorInfo(reasonExclusionViolation, metadata))
+		case pgSerializationFailure, pgDeadlockDetected:
+			return withDetails(codes.Aborted, message+": concurrent modification, please retry",
+				errorInfo(reasonSerializationFailure, nil))
+		case pgTooManyConnections, pgAdminShutdown, pgCrashShutdown, pgCannotConnectNow:
+			return withDetails(codes.Unavailable, message+": database is unavailable, please retry",
+				errorInfo(reasonUnavailable, nil))
+		}
+		if strings.HasPrefix(pgErr.Field(pgFieldCode), pgConnectionException) {
+			return withDetails(codes.Unavailable, message+": database is unavailable, please retry",
+				errorInfo(reasonUnavailable, nil))
+		}
+	}
+
+	return withDetails(codes.Internal, fmt.Errorf("%s: %w", message, err).Error(), errorInfo(reasonInternal, nil))
+}
+
+// isConnectionError reports whether the error means that the connection to the database was lost or couldn't be made.
+func isConnectionError(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.As(err, &netErr)
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	ppb "github.com/TekClinic/Appointments-MicroService/appointments_protobuf"
+	"github.com/uptrace/bun/driver/pgdriver"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// pgdriver.Error has to stay a sqlStateError for dbError to recognise the errors of PostgreSQL.
+var _ sqlStateError = pgdriver.Error{}
+
+// fakeSQLStateError is an error of PostgreSQL with the given fields.
+type fakeSQLStateError map[byte]string
+
+func (err fakeSQLStateError) Error() string {
+	return "SQLSTATE " + err[pgFieldCode]
+}
+
+func (err fakeSQLStateError) Field(k byte) string {
+	return err[k]
+}
+
+// errorReason returns the reason of the ErrorInfo detail of the error, if any.
+func errorReason(err error) string {
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			return info.GetReason()
+		}
+	}
+	return ""
+}
+
+func TestDBError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		code   codes.Code
+		reason string
+	}{
+		{"no rows", sql.ErrNoRows, codes.NotFound, reasonNotFound},
+		{"wrapped no rows", fmt.Errorf("scan: %w", sql.ErrNoRows), codes.NotFound, reasonNotFound},
+		{"unique violation", fakeSQLStateError{pgFieldCode: pgUniqueViolation, pgFieldConstraint: "name"},
+			codes.AlreadyExists, reasonUniqueViolation},
+		{"exclusion violation", fakeSQLStateError{pgFieldCode: pgExclusionViolation},
+			codes.AlreadyExists, reasonExclusionViolation},
+		{"serialization failure", fakeSQLStateError{pgFieldCode: pgSerializationFailure},
+			codes.Aborted, reasonSerializationFailure},
+		{"deadlock", fakeSQLStateError{pgFieldCode: pgDeadlockDetected}, codes.Aborted, reasonSerializationFailure},
+		{"connection failure", fakeSQLStateError{pgFieldCode: "08006"}, codes.Unavailable, reasonUnavailable},
+		{"too many connections", fakeSQLStateError{pgFieldCode: pgTooManyConnections},
+			codes.Unavailable, reasonUnavailable},
+		{"shutdown", fakeSQLStateError{pgFieldCode: pgAdminShutdown}, codes.Unavailable, reasonUnavailable},
+		{"starting up", fakeSQLStateError{pgFieldCode: pgCannotConnectNow}, codes.Unavailable, reasonUnavailable},
+		{"bad connection", driver.ErrBadConn, codes.Unavailable, reasonUnavailable},
+		{"closed connection", fmt.Errorf("read: %w", io.EOF), codes.Unavailable, reasonUnavailable},
+		{"refused connection", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")},
+			codes.Unavailable, reasonUnavailable},
+		{"cancelled", context.Canceled, codes.Canceled, reasonCanceled},
+		{"deadline", context.DeadlineExceeded, codes.DeadlineExceeded, reasonDeadlineExceeded},
+		{"syntax error", fakeSQLStateError{pgFieldCode: "42601"}, codes.Internal, reasonInternal},
+		{"unknown", errors.New("unknown"), codes.Internal, reasonInternal},
+		{"status", status.Error(codes.PermissionDenied, "denied"), codes.PermissionDenied, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := dbError(test.err, "failed")
+			if code := status.Code(err); code != test.code {
+				t.Errorf("code = %v, want %v (%v)", code, test.code, err)
+			}
+			if reason := errorReason(err); reason != test.reason {
+				t.Errorf("reason = %q, want %q", reason, test.reason)
+			}
+		})
+	}
+
+	if err := dbError(nil, "failed"); err != nil {
+		t.Errorf("dbError(nil) = %v, want nil", err)
+	}
+}
+
+func TestRPCErrorCodes(t *testing.T) {
+	// Nothing listens on the port, so every query fails to connect.
+	server := newTestServer(t, openTestDB(t, "127.0.0.1:1"))
+	tenantID := "tenant"
+	admin := adminToken(t, tenantID)
+	patient := testToken(t, tokenIdentity{Subject: "patient", TenantID: tenantID, PatientID: 1}, rolePatient)
+	noTenant := testToken(t, tokenIdentity{Subject: "admin"}, roleAdmin)
+
+	tests := []struct {
+		name string
+		call func(ctx context.Context) error
+		code codes.Code
+	}{
+		{"invalid token", func(ctx context.Context) error {
+			_, err := server.GetAppointment(ctx, &ppb.GetAppointmentRequest{Token: "invalid", Id: 1})
+			return err
+		}, codes.Unauthenticated},
+		{"token without tenant", func(ctx context.Context) error {
+			_, err := server.GetAppointment(ctx, &ppb.GetAppointmentRequest{Token: noTenant, Id: 1})
+			return err
+		}, codes.Unauthenticated},
+		{"role without permission", func(ctx context.Context) error {
+			_, err := server.CreateResource(ctx, &ppb.CreateResourceRequest{Token: patient, Name: "Room"})
+			return err
+		}, codes.PermissionDenied},
+		{"schedule override without permission", func(ctx context.Context) error {
+			_, err := server.CreateAppointment(ctx, &ppb.CreateAppointmentRequest{
+				Token: patient, PatientId: 1, DoctorId: 1, StartTime: "2030-01-01T10:00:00Z", IgnoreSchedule: true})
+			return err
+		}, codes.PermissionDenied},
+		{"missing doctor", func(ctx context.Context) error {
+			_, err := server.CreateAppointment(ctx, &ppb.CreateAppointmentRequest{
+				Token: admin, StartTime: "2030-01-01T10:00:00Z"})
+			return err
+		}, codes.InvalidArgument},
+		{"invalid start time", func(ctx context.Context) error {
+			_, err := server.CreateAppointment(ctx, &ppb.CreateAppointmentRequest{
+				Token: admin, DoctorId: 1, StartTime: "tomorrow"})
+			return err
+		}, codes.InvalidArgument},
+		{"deprecated visited", func(ctx context.Context) error {
+			_, err := server.UpdateAppointment(ctx, &ppb.UpdateAppointmentRequest{
+				Token: admin, Id: 1, ExpectedVersion: 1, Visited: true})
+			return err
+		}, codes.InvalidArgument},
+		{"deprecated approved_by_patient", func(ctx context.Context) error {
+			_, err := server.UpdateAppointment(ctx, &ppb.UpdateAppointmentRequest{
+				Token: admin, Id: 1, ExpectedVersion: 1, ApprovedByPatient: true})
+			return err
+		}, codes.InvalidArgument},
+		{"nested read mask", func(ctx context.Context) error {
+			_, err := server.ListAppointments(ctx, &ppb.ListAppointmentsRequest{Token: admin, Limit: 10,
+				ReadMask: &fieldmaskpb.FieldMask{Paths: []string{"id", "status_changes.to_status"}}})
+			return err
+		}, codes.InvalidArgument},
+		{"database unreachable", func(ctx context.Context) error {
+			_, err := server.GetAppointment(ctx, &ppb.GetAppointmentRequest{Token: admin, Id: 1})
+			return err
+		}, codes.Unavailable},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.call(context.Background())
+			if code := status.Code(err); code != test.code {
+				t.Errorf("code = %v, want %v (%v)", code, test.code, err)
+			}
+		})
+	}
+}
+
+func TestRPCErrorCodesWithDatabase(t *testing.T) {
+	server := newTestServer(t, testDB(t))
+	ctx := context.Background()
+	admin := adminToken(t, testTenant(t))
+
+	_, err := server.CreateLocation(ctx, &ppb.CreateLocationRequest{Token: admin, Name: "Main", Timezone: "UTC"})
+	if err != nil {
+		t.Fatalf("failed to create a location: %v", err)
+	}
+	start := time.Now().UTC().Truncate(time.Hour).Add(7 * 24 * time.Hour)
+	booked := createTestAppointment(t, server, admin, 1, 1, start, start.Add(time.Hour))
+	slot := func(offset time.Duration) (string, string) {
+		return start.Add(offset).Format(time.RFC3339), start.Add(offset + time.Hour).Format(time.RFC3339)
+	}
+
+	const missing = -1
+	tests := []struct {
+		name   string
+		call   func(ctx context.Context) error
+		code   codes.Code
+		reason string
+	}{
+		{"GetAppointment missing", func(ctx context.Context) error {
+			_, err := server.GetAppointment(ctx, &ppb.GetAppointmentRequest{Token: admin, Id: missing})
+			return err
+		}, codes.NotFound, reasonNotFound},
+		{"UpdateAppointment missing", func(ctx context.Context) error {
+			startTime, endTime := slot(2 * time.Hour)
+			_, err := server.UpdateAppointment(ctx, &ppb.UpdateAppointmentRequest{Token: admin, Id: missing,
+				ExpectedVersion: 1, DoctorId: 1, StartTime: startTime, EndTime: endTime, IgnoreSchedule: true})
+			return err
+		}, codes.NotFound, reasonNotFound},
+		{"AssignPatient missing", func(ctx context.Context) error {
+			_, err := server.AssignPatient(ctx, &ppb.AssignPatientRequest{
+				Token: admin, Id: missing, PatientId: 2, ExpectedVersion: 1})
+			return err
+		}, codes.NotFound, reasonNotFound},
+		{"RemovePatient missing", func(ctx context.Context) error {
+			_, err := server.RemovePatient(ctx, &ppb.RemovePatientRequest{Token: admin, Id: missing, ExpectedVersion: 1})
+			return err
+		}, codes.NotFound, reasonNotFound},
+		{"DeleteAppointment missing", func(ctx context.Context) error {
+			_, err := server.DeleteAppointment(ctx, &ppb.DeleteAppointmentRequest{
+				Token: admin, Id: missing, ExpectedVersion: 1})
+			return err
+		}, codes.NotFound, reasonNotFound},
+		{"CancelAppointment missing", func(ctx context.Context) error {
+			_, err := server.CancelAppointment(ctx, &ppb.CancelAppointmentRequest{Token: admin, Id: missing,
+				Reason: ppb.CancellationReason_CANCELLATION_REASON_PATIENT_REQUEST})
+			return err
+		}, codes.NotFound, reasonNotFound},
+		{"GetAppointmentHistory missing", func(ctx context.Context) error {
+			_, err := server.GetAppointmentHistory(ctx, &ppb.GetAppointmentHistoryRequest{
+				Token: admin, Id: missing, Limit: 10})
+			return err
+		}, codes.NotFound, reasonNotFound},
+		{"DeleteLocation missing", func(ctx context.Context) error {
+			_, err := server.DeleteLocation(ctx, &ppb.DeleteLocationRequest{Token: admin, Id: missing})
+			return err
+		}, codes.NotFound, reasonNotFound},
+		{"GetResource missing", func(ctx context.Context) error {
+			_, err := server.GetResource(ctx, &ppb.GetResourceRequest{Token: admin, Id: missing})
+			return err
+		}, codes.NotFound, reasonNotFound},
+		{"GetAppointmentType missing", func(ctx context.Context) error {
+			_, err := server.GetAppointmentType(ctx, &ppb.GetAppointmentTypeRequest{Token: admin, Id: missing})
+			return err
+		}, codes.NotFound, reasonNotFound},
+		{"GetWaitlistEntry missing", func(ctx context.Context) error {
+			_, err := server.GetWaitlistEntry(ctx, &ppb.GetWaitlistEntryRequest{Token: admin, Id: missing})
+			return err
+		}, codes.NotFound, reasonNotFound},
+		{"ConfirmHold missing", func(ctx context.Context) error {
+			_, err := server.ConfirmHold(ctx, &ppb.ConfirmHoldRequest{Token: admin, Id: missing, PatientId: 1})
+			return err
+		}, codes.NotFound, reasonNotFound},
+		{"CreateLocation duplicate", func(ctx context.Context) error {
+			_, err := server.CreateLocation(ctx, &ppb.CreateLocationRequest{Token: admin, Name: "Main", Timezone: "UTC"})
+			return err
+		}, codes.AlreadyExists, reasonUniqueViolation},
+		{"CreateAppointment doctor overlap", func(ctx context.Context) error {
+			startTime, endTime := slot(30 * time.Minute)
+			_, err := server.CreateAppointment(ctx, &ppb.CreateAppointmentRequest{Token: admin, PatientId: 2,
+				DoctorId: 1, StartTime: startTime, EndTime: endTime, IgnoreSchedule: true})
+			return err
+		}, codes.AlreadyExists, reasonDoctorConflict},
+		{"CreateAppointment patient overlap", func(ctx context.Context) error {
+			startTime, endTime := slot(30 * time.Minute)
+			_, err := server.CreateAppointment(ctx, &ppb.CreateAppointmentRequest{Token: admin, PatientId: 1,
+				DoctorId: 2, StartTime: startTime, EndTime: endTime, IgnoreSchedule: true})
+			return err
+		}, codes.AlreadyExists, reasonPatientConflict},
+		{"UpdateAppointment version mismatch", func(ctx context.Context) error {
+			startTime, endTime := slot(0)
+			_, err := server.UpdateAppointment(ctx, &ppb.UpdateAppointmentRequest{Token: admin, Id: booked,
+				ExpectedVersion: 5, PatientId: 1, DoctorId: 1, StartTime: startTime, EndTime: endTime,
+				IgnoreSchedule: true})
+			return err
+		}, codes.Aborted, reasonVersionMismatch},
+		{"AssignPatient version mismatch", func(ctx context.Context) error {
+			_, err := server.AssignPatient(ctx, &ppb.AssignPatientRequest{
+				Token: admin, Id: booked, PatientId: 2, ExpectedVersion: 5})
+			return err
+		}, codes.Aborted, reasonVersionMismatch},
+		{"DeleteAppointment stale etag", func(ctx context.Context) error {
+			_, err := server.DeleteAppointment(ctx, &ppb.DeleteAppointmentRequest{
+				Token: admin, Id: booked, Etag: appointmentETag(5)})
+			return err
+		}, codes.Aborted, reasonVersionMismatch},
+		{"RemovePatient without version", func(ctx context.Context) error {
+			_, err := server.RemovePatient(ctx, &ppb.RemovePatientRequest{Token: admin, Id: booked})
+			return err
+		}, codes.InvalidArgument, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.call(ctx)
+			if code := status.Code(err); code != test.code {
+				t.Errorf("code = %v, want %v (%v)", code, test.code, err)
+			}
+			if reason := errorReason(err); reason != test.reason {
+				t.Errorf("reason = %q, want %q", reason, test.reason)
+			}
+		})
+	}
+}
+
+func TestConflictError(t *testing.T) {
+	err := conflictError(reasonDoctorConflict, "the doctor already has overlapping appointments", []int32{3, 5})
+	if code := status.Code(err); code != codes.AlreadyExists {
+		t.Errorf("code = %v, want AlreadyExists", code)
+	}
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			if info.GetReason() != reasonDoctorConflict || info.GetDomain() != errorDomain {
+				t.Errorf("reason = %q in %q, want %q in %q", info.GetReason(), info.GetDomain(),
+					reasonDoctorConflict, errorDomain)
+			}
+			if ids := info.GetMetadata()[conflictingIDsKey]; ids != "3, 5" {
+				t.Errorf("conflicting IDs = %q, want %q", ids, "3, 5")
+			}
+			return
+		}
+	}
+	t.Errorf("error %v has no ErrorInfo detail", err)
+}
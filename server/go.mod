@@ -5,11 +5,15 @@ go 1.22.0
 require (
 	github.com/TekClinic/Appointments-MicroService/appointments_protobuf v0.100.0-integrated
 	github.com/TekClinic/MicroService-Lib v0.1.3
+	github.com/teambition/rrule-go v1.8.2
 	github.com/uptrace/bun v1.2.1
 	github.com/uptrace/bun/dialect/pgdialect v1.2.1
 	github.com/uptrace/bun/driver/pgdriver v1.2.1
 	go.uber.org/zap v1.27.0
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240812133136-8ffd90a71988
 	google.golang.org/grpc v1.65.0
+	google.golang.org/protobuf v1.34.2
+	k8s.io/apimachinery v0.31.0
 )
 
 require (
@@ -28,9 +32,6 @@ require (
 	golang.org/x/oauth2 v0.22.0 // indirect
 	golang.org/x/sys v0.24.0 // indirect
 	golang.org/x/text v0.17.0 // indirect
-	google.golang.org/genproto/googleapis/rpc v0.0.0-20240812133136-8ffd90a71988 // indirect
-	google.golang.org/protobuf v1.34.2 // indirect
-	k8s.io/apimachinery v0.31.0 // indirect
 	mellium.im/sasl v0.3.1 // indirect
 )
 
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	ppb "github.com/TekClinic/Appointments-MicroService/appointments_protobuf"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/schema"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// defaultHoldTTL is how long a slot is held if neither hold_minutes nor SLOT_HOLD_TTL is set.
+	defaultHoldTTL = 10 * time.Minute
+	// maxHoldMinutes is the maximal hold_minutes of a hold.
+	maxHoldMinutes = 60
+	// holdSweepInterval is how often expired holds are released.
+	holdSweepInterval = time.Minute
+	// maxActiveHolds is the maximal number of holds of a user that haven't expired yet.
+	maxActiveHolds = 3
+	// doctorHoldsLock and userHoldsLock are the namespaces of the advisory locks of the holds
+	// of a doctor and of a user, see lockInTenant.
+	doctorHoldsLock = "doctor_holds"
+	userHoldsLock   = "user_holds"
+)
+
+// parseHoldTTL parses how long slots are held by default, e.g. 10m.
+func parseHoldTTL(value string) (time.Duration, error) {
+	ttl, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %s: %w", envSlotHoldTTL, err)
+	}
+	if ttl <= 0 {
+		return 0, fmt.Errorf("%s has to be positive", envSlotHoldTTL)
+	}
+	return ttl, nil
+}
+
+// appointment returns the appointment the hold reserves the time for, without a patient.
+func (hold SlotHold) appointment() Appointment {
+	return Appointment{
+		TenantID:    hold.TenantID,
+		DoctorID:    hold.DoctorID,
+		StartTime:   hold.StartTime,
+		EndTime:     hold.EndTime,
+		Status:      StatusScheduled,
+		TypeID:      hold.TypeID,
+		ResourceIDs: hold.ResourceIDs,
+		LocationID:  hold.LocationID,
+	}
+}
+
+// toGRPC returns a GRPC version of SlotHold.
+func (hold SlotHold) toGRPC() *ppb.SlotHold {
+	return &ppb.SlotHold{
+		Id:          hold.ID,
+		DoctorId:    hold.DoctorID,
+		StartTime:   hold.StartTime.Format(time.RFC3339),
+		EndTime:     hold.EndTime.Format(time.RFC3339),
+		TypeId:      hold.TypeID,
+		ResourceIds: hold.ResourceIDs,
+		LocationId:  hold.LocationID,
+		HeldBy:      hold.HeldBy,
+		ExpiresAt:   hold.ExpiresAt.Format(time.RFC3339),
+	}
+}
+
+// holdTTL returns how long the hold defined by the request lasts: hold_minutes if set, the default TTL otherwise.
+// Returns codes.InvalidArgument if hold_minutes is out of range.
+func (server appointmentsServer) holdTTL(req *ppb.HoldSlotRequest) (time.Duration, error) {
+	if req.GetHoldMinutes() == 0 {
+		return server.slotHoldTTL, nil
+	}
+	if req.GetHoldMinutes() < 0 || req.GetHoldMinutes() > maxHoldMinutes {
+		return 0, invalidArgumentError("hold_minutes",
+			fmt.Sprintf("hold_minutes has to be between 1 and %d", maxHoldMinutes))
+	}
+	return time.Duration(req.GetHoldMinutes()) * time.Minute, nil
+}
+
+// whereHeld restricts the query to the holds that haven't expired yet and block the doctor or one of the resources.
+func whereHeld(query *bun.SelectQuery, doctorIDs []int32, resourceIDs []int32) *bun.SelectQuery {
+	return query.
+		Where("? > now()", bun.Ident("expires_at")).
+		WhereGroup(" AND ", func(query *bun.SelectQuery) *bun.SelectQuery {
+			query = query.Where("? IN (?)", bun.Ident("doctor_id"), bun.In(doctorIDs))
+			if len(resourceIDs) > 0 {
+				query = query.WhereOr("? && ?", bun.Ident("resource_ids"), pgdialect.Array(resourceIDs))
+			}
+			return query
+		})
+}
+
+// lockDoctorHolds takes a transaction-level advisory lock of the doctor of the tenant. Holds are not covered
+// by the doctorOverlapConstraint, so the checks of the appointments and the holds of a doctor are serialized
+// by the lock until the end of the transaction that saves them.
+// The lock is keyed by the doctor ID in the doctorHoldsLock namespace of the tenant.
+func lockDoctorHolds(ctx context.Context, db bun.IDB, tenantID string, doctorID int32) error {
+	if err := lockInTenant(ctx, db, doctorHoldsLock, tenantID, doctorID); err != nil {
+		return dbError(err, "failed to lock the holds of the doctor")
+	}
+	return nil
+}
+
+// checkActiveHolds returns codes.ResourceExhausted if the user already has maxActiveHolds holds
+// in the tenant that haven't expired. The holds of the user are locked by a transaction-level advisory lock,
+// keyed by the hash of the user in the userHoldsLock namespace of the tenant,
+// so that concurrent holds of the same user can't exceed the limit.
+func checkActiveHolds(ctx context.Context, tx bun.Tx, tenantID string, heldBy string) error {
+	err := lockInTenant(ctx, tx, userHoldsLock, tenantID, schema.SafeQuery("hashtext(?)", []any{heldBy}))
+	if err != nil {
+		return dbError(err, "failed to lock the holds of the user")
+	}
+	count, err := whereTenant(tx.NewSelect().Model((*SlotHold)(nil)), tenantID).
+		Where("? = ?", bun.Ident("held_by"), heldBy).
+		Where("? > now()", bun.Ident("expires_at")).
+		Count(ctx)
+	if err != nil {
+		return dbError(err, "failed to count the holds of the user")
+	}
+	if count >= maxActiveHolds {
+		return status.Error(codes.ResourceExhausted,
+			fmt.Sprintf("at most %d slots can be held at once, confirm or wait for the expiry of a hold", maxActiveHolds))
+	}
+	return nil
+}
+
+// checkHoldOverlap returns codes.AlreadyExists if the appointment overlaps a hold of its doctor
+// or of one of its resources that hasn't expired.
+func checkHoldOverlap(ctx context.Context, db bun.IDB, appointment *Appointment) error {
+	var holds []SlotHold
+	query := whereTenant(db.NewSelect().Model(&holds), appointment.TenantID)
+	query = whereOverlaps(whereHeld(query, []int32{appointment.DoctorID}, appointment.ResourceIDs),
+		appointment.timeRange())
+	if err := query.Order("expires_at").Scan(ctx); err != nil {
+		return dbError(err, "failed to check holds")
+	}
+	if len(holds) > 0 {
+		ids := make([]int32, len(holds))
+		for i, hold := range holds {
+			ids[i] = hold.ID
+		}
+		return conflictError(reasonHoldConflict,
+			"the time is held for another booking until "+holds[len(holds)-1].ExpiresAt.Format(time.RFC3339), ids)
+	}
+	return nil
+}
+
+// runHoldSweeper releases the expired holds until the context is done.
+// Expired holds don't block anything even before they are released, see whereHeld.
+func runHoldSweeper(ctx context.Context, db *bun.DB) {
+	ctx = withAuditAction(ctx, "ReleaseExpiredHolds")
+	sweep := time.NewTicker(holdSweepInterval)
+	defer sweep.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sweep.C:
+			if err := releaseExpiredHolds(ctx, db); err != nil {
+				zap.L().Error("Failed to release expired holds", zap.Error(err))
+			}
+		}
+	}
+}
+
+// releaseExpiredHolds deletes the expired holds of all tenants and records their release in the audit trail.
+func releaseExpiredHolds(ctx context.Context, db *bun.DB) error {
+	return runInSystemTx(ctx, db, func(ctx context.Context, tx bun.Tx) error {
+		var holds []SlotHold
+		_, err := tx.NewDelete().
+			Model(&holds).
+			Where("? <= now()", bun.Ident("expires_at")).
+			Returning("*").
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		for _, hold := range holds {
+			err = recordAudit(ctx, tx, hold.TenantID, auditEntitySlotHold, hold.ID, systemActor, hold.toGRPC(), nil)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// HoldSlot reserves the time of a doctor for a booking whose patient is not known yet, e.g. while the patient
+// fills in the online booking form. The hold blocks the time like an appointment until it expires
+// after hold_minutes, or SLOT_HOLD_TTL if not set, and is converted to an appointment by ConfirmHold.
+// Requires authentication. If authentication is not valid, codes.Unauthenticated is returned.
+// Requires the hold_slots permission, see accessPolicy. If roles are not sufficient,
+// codes.PermissionDenied is returned.
+// If the doctor, the time, the type, the resources, the location or hold_minutes are invalid,
+// codes.InvalidArgument is returned, and the time is checked against the booking rules like in CreateAppointment.
+// If the type, one of the resources or the location doesn't exist, codes.NotFound is returned.
+// If the time is outside of the doctor's working hours, codes.FailedPrecondition is returned.
+// If the time overlaps an appointment or a hold of the doctor or of one of the resources,
+// codes.AlreadyExists is returned.
+// If the user already holds maxActiveHolds slots that haven't expired, codes.ResourceExhausted is returned.
+func (server appointmentsServer) HoldSlot(ctx context.Context,
+	req *ppb.HoldSlotRequest) (*ppb.HoldSlotResponse, error) {
+	caller, err := server.authorize(ctx, req.GetToken(), permHoldSlots)
+	if err != nil {
+		return nil, err
+	}
+
+	startTime, err := time.Parse(time.RFC3339, req.GetStartTime())
+	if err != nil {
+		return nil, invalidArgumentError("start_time", fmt.Errorf("failed to parse start time: %w", err).Error())
+	}
+	if req.GetDoctorId() <= 0 {
+		return nil, invalidArgumentError("doctor_id", "DoctorID has to be a positive value")
+	}
+	ttl, err := server.holdTTL(req)
+	if err != nil {
+		return nil, err
+	}
+	hold := SlotHold{
+		TenantID:   caller.tenantID,
+		DoctorID:   req.GetDoctorId(),
+		StartTime:  startTime,
+		TypeID:     req.GetTypeId(),
+		LocationID: req.GetLocationId(),
+		HeldBy:     tokenActor(req.GetToken()),
+	}
+	err = runInTenantTx(ctx, server.db, caller.tenantID, func(ctx context.Context, tx bun.Tx) error {
+		var appointmentType *AppointmentType
+		var txErr error
+		if req.GetTypeId() != 0 {
+			appointmentType, txErr = fetchBookableType(ctx, tx, caller.tenantID, req.GetTypeId(), req.GetDoctorId())
+			if txErr != nil {
+				return txErr
+			}
+		}
+		if hold.EndTime, txErr = appointmentEnd(req.GetEndTime(), startTime, appointmentType); txErr != nil {
+			return txErr
+		}
+		txErr = server.bookingRules.forType(appointmentType).validate(timeRange{Start: startTime, End: hold.EndTime}, true)
+		if txErr != nil {
+			return txErr
+		}
+		if hold.ResourceIDs, txErr = fetchBookableResources(ctx, tx, caller.tenantID, req.GetResourceIds()); txErr != nil {
+			return txErr
+		}
+		if txErr = fetchBookableLocation(ctx, tx, caller.tenantID, req.GetLocationId()); txErr != nil {
+			return txErr
+		}
+
+		if txErr = checkActiveHolds(ctx, tx, caller.tenantID, hold.HeldBy); txErr != nil {
+			return txErr
+		}
+		appointment := hold.appointment()
+		if _, txErr = server.checkAppointment(ctx, tx, &appointment, false); txErr != nil {
+			return txErr
+		}
+		// The hold expires by the clock of the database, which is the clock that checks the expiry.
+		_, txErr = tx.NewInsert().Model(&hold).Value("expires_at", "?", nowPlus(ttl)).Returning("*").Exec(ctx)
+		if txErr != nil {
+			return txErr
+		}
+		return recordAudit(ctx, tx, caller.tenantID, auditEntitySlotHold, hold.ID, hold.HeldBy, nil, hold.toGRPC())
+	})
+	if err != nil {
+		return nil, dbError(err, "failed to hold a slot")
+	}
+
+	return &ppb.HoldSlotResponse{
+		Id:        hold.ID,
+		EndTime:   hold.EndTime.Format(time.RFC3339),
+		ExpiresAt: hold.ExpiresAt.Format(time.RFC3339),
+	}, nil
+}
+
+// ConfirmHold books the patient for the held time, which releases the hold.
+// The doctor's working hours and the booking rules were checked when the slot was held and are not checked again.
+// Requires authentication. If authentication is not valid, codes.Unauthenticated is returned.
+// Requires the hold_slots permission, see accessPolicy. If roles are not sufficient, the patient is not accessible
+// or the hold was made by another user, codes.PermissionDenied is returned.
+// If patient_id is not positive, codes.InvalidArgument is returned.
+// If the hold with the given ID doesn't exist or has been released, codes.NotFound is returned.
+// If the hold has expired, codes.FailedPrecondition is returned.
+// If the appointment overlaps another appointment of the same patient, the patient overlap policy is applied.
+func (server appointmentsServer) ConfirmHold(ctx context.Context,
+	req *ppb.ConfirmHoldRequest) (*ppb.ConfirmHoldResponse, error) {
+	caller, err := server.authorize(ctx, req.GetToken(), permHoldSlots)
+	if err != nil {
+		return nil, err
+	}
+	if req.GetPatientId() <= 0 {
+		return nil, invalidArgumentError("patient_id", "PatientID has to be a positive value")
+	}
+	if err = caller.checkPatient(req.GetPatientId()); err != nil {
+		return nil, err
+	}
+
+	actor := tokenActor(req.GetToken())
+	var appointment Appointment
+	var warnings []*ppb.SchedulingWarning
+	err = runInTenantTx(ctx, server.db, caller.tenantID, func(ctx context.Context, tx bun.Tx) error {
+		hold := new(SlotHold)
+		txErr := whereTenant(tx.NewSelect().Model(hold), caller.tenantID).
+			Where("? = ?", bun.Ident("id"), req.GetId()).
+			For("UPDATE").
+			Scan(ctx)
+		if txErr != nil {
+			return dbError(txErr, "failed to fetch a hold by id")
+		}
+		if !caller.all && hold.HeldBy != actor {
+			return status.Error(codes.PermissionDenied, permissionDeniedMessage)
+		}
+		if !hold.ExpiresAt.After(time.Now()) {
+			return status.Error(codes.FailedPrecondition, "the hold has expired")
+		}
+		if _, txErr = tx.NewDelete().Model(hold).WherePK().Exec(ctx); txErr != nil {
+			return txErr
+		}
+		txErr = recordAudit(ctx, tx, caller.tenantID, auditEntitySlotHold, hold.ID, actor, hold.toGRPC(), nil)
+		if txErr != nil {
+			return txErr
+		}
+
+		appointment = hold.appointment()
+		appointment.PatientID = req.GetPatientId()
+		if warnings, txErr = server.checkAppointment(ctx, tx, &appointment, true); txErr != nil {
+			return txErr
+		}
+		if _, txErr = tx.NewInsert().Model(&appointment).Returning("id, version").Exec(ctx); txErr != nil {
+			return txErr
+		}
+		return recordEvent(ctx, tx, EventCreated, &appointment, nil, actor)
+	})
+	if err != nil {
+		return nil, writeError(ctx, server.db, &appointment, err, "failed to confirm a hold")
+	}
+
+	return &ppb.ConfirmHoldResponse{AppointmentId: appointment.ID, Warnings: warnings}, nil
+}
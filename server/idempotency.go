@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/uptrace/bun"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+const (
+	// idempotencyKeyHeader is the metadata key that can carry the idempotency key instead of the request field.
+	idempotencyKeyHeader = "idempotency-key"
+	// maxIdempotencyKeyLength is the maximal length of an idempotency key.
+	maxIdempotencyKeyLength = 255
+	// defaultIdempotencyTTL is how long the response to an idempotency key is kept
+	// if IDEMPOTENCY_TTL is not set.
+	defaultIdempotencyTTL = 24 * time.Hour
+	// idempotentRequestTimeout is the maximal duration of a request with an idempotency key. A request that
+	// doesn't commit its changes in time fails, since its transaction is rolled back with its context.
+	idempotentRequestTimeout = time.Minute
+	// idempotencyLease is how long a request may be in progress. After that, the request is considered
+	// lost, e.g. because the service was restarted, and a retry with the same key is served again.
+	// It is longer than idempotentRequestTimeout, so that a request that is still running can't be served twice.
+	idempotencyLease = 2 * idempotentRequestTimeout
+	// idempotencyPruneInterval is how often expired idempotency keys are deleted.
+	idempotencyPruneInterval = time.Hour
+)
+
+// idempotentRequest is a common interface of the requests of mutating RPCs.
+type idempotentRequest interface {
+	proto.Message
+	GetToken() string
+	GetIdempotencyKey() string
+}
+
+// parseIdempotencyTTL parses the TTL of idempotency keys, e.g. 24h.
+func parseIdempotencyTTL(value string) (time.Duration, error) {
+	ttl, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %s: %w", envIdempotencyTTL, err)
+	}
+	if ttl <= 0 {
+		return 0, fmt.Errorf("%s has to be positive", envIdempotencyTTL)
+	}
+	return ttl, nil
+}
+
+// requestIdempotencyKey returns the idempotency key of the request, taken from the idempotency_key field
+// or from the idempotency-key metadata. Returns an empty key if neither is set.
+// Returns codes.InvalidArgument if the key is too long or the field and the metadata disagree.
+func requestIdempotencyKey(ctx context.Context, req idempotentRequest) (string, error) {
+	key := req.GetIdempotencyKey()
+	if values := metadata.ValueFromIncomingContext(ctx, idempotencyKeyHeader); len(values) > 0 {
+		if key != "" && key != values[0] {
+			return "", invalidArgumentError("idempotency_key",
+				"idempotency_key doesn't match the "+idempotencyKeyHeader+" metadata")
+		}
+		key = values[0]
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		return "", invalidArgumentError("idempotency_key",
+			fmt.Sprintf("idempotency key has to be at most %d characters long", maxIdempotencyKeyLength))
+	}
+	return key, nil
+}
+
+// requestHash returns a hash of the payload of the request. The token and the idempotency key are left out,
+// so that a retry with a refreshed token is recognized as the same request.
+func requestHash(req idempotentRequest) ([]byte, error) {
+	clone := proto.Clone(req)
+	reflected := clone.ProtoReflect()
+	fields := reflected.Descriptor().Fields()
+	for _, name := range []protoreflect.Name{"token", "idempotency_key"} {
+		if field := fields.ByName(name); field != nil {
+			reflected.Clear(field)
+		}
+	}
+
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(clone)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the request: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return sum[:], nil
+}
+
+// idempotencyInterceptor makes mutating RPCs idempotent. The first request with an idempotency key is served
+// and its response is stored for the configured TTL. A retry with the same key and the same payload gets the stored
+// response without serving the request again. Keys are scoped to the user of the token and to the RPC.
+// Failed requests are not stored, so they can be retried with the same key.
+// A request with a key is cancelled after idempotentRequestTimeout, see idempotencyLease.
+// Returns codes.Unauthenticated if the token is not valid or has no tenant, codes.InvalidArgument if the key was used
+// with a different payload, and codes.Aborted if the first request with the key is still in progress.
+func (server appointmentsServer) idempotencyInterceptor(ctx context.Context, req any,
+	info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	request, ok := req.(idempotentRequest)
+	if !ok {
+		return handler(ctx, req)
+	}
+	key, err := requestIdempotencyKey(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	if key == "" {
+		return handler(ctx, req)
+	}
+
+	// Stored responses are only replayed to the user that caused them, within the user's tenant.
+	if _, err = server.VerifyToken(ctx, request.GetToken()); err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	identity, err := parseTokenIdentity(request.GetToken())
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	tenantID, err := identity.tenant()
+	if err != nil {
+		return nil, err
+	}
+	hash, err := requestHash(request)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	record := &IdempotencyKey{
+		TenantID:    tenantID,
+		Subject:     identity.Subject,
+		Method:      info.FullMethod,
+		Key:         key,
+		RequestHash: hash,
+		CreatedAt:   time.Now(),
+		ExpiresAt:   time.Now().Add(server.idempotencyTTL),
+	}
+	replay, err := server.claimIdempotencyKey(ctx, record)
+	if err != nil || replay != nil {
+		return replay, err
+	}
+
+	handlerCtx, cancel := context.WithTimeout(ctx, idempotentRequestTimeout)
+	defer cancel()
+	response, err := handler(handlerCtx, req)
+	if err != nil {
+		// The key is released, so that the client can retry the request.
+		if releaseErr := server.releaseIdempotencyKey(context.WithoutCancel(ctx), record); releaseErr != nil {
+			zap.L().Error("Failed to release an idempotency key", zap.String("key", key), zap.Error(releaseErr))
+		}
+		return nil, err
+	}
+	if storeErr := server.storeIdempotentResponse(context.WithoutCancel(ctx), record, response); storeErr != nil {
+		// The request succeeded, so its response is returned even though a retry would be served again.
+		zap.L().Error("Failed to store an idempotent response", zap.String("key", key), zap.Error(storeErr))
+	}
+	return response, nil
+}
+
+// claimIdempotencyKey claims the key of the record for a new request. If the key was already used,
+// the stored response is returned instead. Expired keys and keys of lost requests are claimed again.
+func (server appointmentsServer) claimIdempotencyKey(ctx context.Context, record *IdempotencyKey) (any, error) {
+	existing := &IdempotencyKey{TenantID: record.TenantID, Subject: record.Subject, Method: record.Method,
+		Key: record.Key}
+	claimed := false
+	err := runInTenantTx(ctx, server.db, record.TenantID, func(ctx context.Context, tx bun.Tx) error {
+		result, txErr := tx.NewInsert().Model(record).On("CONFLICT DO NOTHING").Exec(ctx)
+		if txErr != nil {
+			return txErr
+		}
+		if affected, _ := result.RowsAffected(); affected == 1 {
+			claimed = true
+			return nil
+		}
+
+		if txErr = tx.NewSelect().Model(existing).WherePK().Scan(ctx); txErr != nil {
+			if errors.Is(txErr, sql.ErrNoRows) {
+				return withDetails(codes.Aborted, "the idempotency key was released concurrently, retry the request",
+					errorInfo(reasonRequestInProgress, nil))
+			}
+			return txErr
+		}
+		lost := existing.ResponseType == "" && existing.CreatedAt.Add(idempotencyLease).Before(time.Now())
+		if existing.ExpiresAt.Before(time.Now()) || lost {
+			claimed = true
+			return reclaimIdempotencyKey(ctx, tx, record, existing)
+		}
+		return nil
+	})
+	if err != nil || claimed {
+		return nil, dbError(err, "failed to claim the idempotency key")
+	}
+
+	if !bytes.Equal(existing.RequestHash, record.RequestHash) {
+		return nil, invalidArgumentError("idempotency_key",
+			"idempotency key was already used with a different request, use a new key for a new request")
+	}
+	if existing.ResponseType == "" {
+		return nil, withDetails(codes.Aborted, "a request with the same idempotency key is in progress, retry later",
+			errorInfo(reasonRequestInProgress, nil))
+	}
+
+	messageType, err := protoregistry.GlobalTypes.FindMessageByName(protoreflect.FullName(existing.ResponseType))
+	if err != nil {
+		return nil, status.Error(codes.Internal, fmt.Errorf("failed to find the stored response type: %w", err).Error())
+	}
+	response := messageType.New().Interface()
+	if err = proto.Unmarshal(existing.Response, response); err != nil {
+		return nil, status.Error(codes.Internal, fmt.Errorf("failed to decode the stored response: %w", err).Error())
+	}
+	return response, nil
+}
+
+// reclaimIdempotencyKey replaces an expired or lost record with a new one. The replacement is conditioned
+// on the record being unchanged, so that only one of concurrent retries claims the key.
+func reclaimIdempotencyKey(ctx context.Context, db bun.IDB, record *IdempotencyKey, existing *IdempotencyKey) error {
+	result, err := db.NewUpdate().
+		Model(record).
+		Column("request_hash", "response_type", "response", "created_at", "expires_at").
+		WherePK().
+		Where("? = ?", bun.Ident("created_at"), existing.CreatedAt).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return withDetails(codes.Aborted, "a request with the same idempotency key is in progress, retry later",
+			errorInfo(reasonRequestInProgress, nil))
+	}
+	return nil
+}
+
+// releaseIdempotencyKey deletes the record claimed by a failed request. The deletion is conditioned on the record
+// being unchanged, so that a record claimed again by a retry after the lease is kept.
+func (server appointmentsServer) releaseIdempotencyKey(ctx context.Context, record *IdempotencyKey) error {
+	return runInTenantTx(ctx, server.db, record.TenantID, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewDelete().
+			Model(record).
+			WherePK().
+			Where("? = ?", bun.Ident("created_at"), record.CreatedAt).
+			Where("? = ?", bun.Ident("request_hash"), record.RequestHash).
+			Where("? IS NULL", bun.Ident("response_type")).
+			Exec(ctx)
+		return err
+	})
+}
+
+// storeIdempotentResponse stores the response to the request that claimed the key of the record,
+// unless the record was claimed again by a retry in the meantime.
+func (server appointmentsServer) storeIdempotentResponse(ctx context.Context,
+	record *IdempotencyKey, response any) error {
+	message, ok := response.(proto.Message)
+	if !ok {
+		return fmt.Errorf("response of type %T is not a protobuf message", response)
+	}
+	data, err := proto.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to encode the response: %w", err)
+	}
+
+	record.ResponseType = string(message.ProtoReflect().Descriptor().FullName())
+	record.Response = data
+	return runInTenantTx(ctx, server.db, record.TenantID, func(ctx context.Context, tx bun.Tx) error {
+		_, txErr := tx.NewUpdate().
+			Model(record).
+			Column("response_type", "response").
+			WherePK().
+			Where("? = ?", bun.Ident("created_at"), record.CreatedAt).
+			Where("? = ?", bun.Ident("request_hash"), record.RequestHash).
+			Exec(ctx)
+		return txErr
+	})
+}
+
+// runIdempotencyPruner deletes expired idempotency keys until the context is done.
+func runIdempotencyPruner(ctx context.Context, db *bun.DB) {
+	prune := time.NewTicker(idempotencyPruneInterval)
+	defer prune.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-prune.C:
+			// The keys of all tenants are pruned at once.
+			err := runInSystemTx(ctx, db, func(ctx context.Context, tx bun.Tx) error {
+				_, txErr := tx.NewDelete().
+					Model((*IdempotencyKey)(nil)).
+					Where("? < now()", bun.Ident("expires_at")).
+					Exec(ctx)
+				return txErr
+			})
+			if err != nil {
+				zap.L().Error("Failed to prune idempotency keys", zap.Error(err))
+			}
+		}
+	}
+}
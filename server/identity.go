@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const jwtParts = 3
+
+// tokenIdentity holds the claims that identify the user of a token.
+// ms.Claims only exposes roles, so the identity is decoded from the token payload.
+// DoctorID and PatientID link the user to the doctor or the patient they are, if any.
+// TenantID is the clinic organisation of the user, see tenant.
+type tokenIdentity struct {
+	Subject   string `json:"sub"`
+	Username  string `json:"preferred_username"`
+	DoctorID  int32  `json:"doctor_id"`
+	PatientID int32  `json:"patient_id"`
+	TenantID  string `json:"tenant_id"`
+}
+
+// tenant returns the tenant of the user. Returns codes.Unauthenticated if the token has no tenant_id claim,
+// so that a token can't reach the records of a tenant it wasn't issued for.
+func (identity tokenIdentity) tenant() (string, error) {
+	if identity.TenantID == "" {
+		return "", status.Error(codes.Unauthenticated, "the token has no tenant_id claim")
+	}
+	return identity.TenantID, nil
+}
+
+// parseTokenIdentity decodes the identity claims of a JWT.
+// The signature is not checked, so the token has to be verified with VerifyToken beforehand.
+func parseTokenIdentity(rawToken string) (tokenIdentity, error) {
+	parts := strings.Split(rawToken, ".")
+	if len(parts) != jwtParts {
+		return tokenIdentity{}, errors.New("token is not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return tokenIdentity{}, err
+	}
+
+	var identity tokenIdentity
+	if err = json.Unmarshal(payload, &identity); err != nil {
+		return tokenIdentity{}, err
+	}
+	return identity, nil
+}
+
+// systemActor is the actor recorded with the changes made by the service itself,
+// e.g. by the background jobs that release holds and expire waitlist offers.
+const systemActor = "system"
+
+// tokenActor returns a human-readable identifier of the user of a verified token,
+// to be recorded with the changes made by the user.
+func tokenActor(rawToken string) string {
+	identity, err := parseTokenIdentity(rawToken)
+	if err != nil {
+		return ""
+	}
+	if identity.Username != "" {
+		return identity.Username
+	}
+	return identity.Subject
+}
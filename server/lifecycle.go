@@ -0,0 +1,469 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	ppb "github.com/TekClinic/Appointments-MicroService/appointments_protobuf"
+	"github.com/uptrace/bun"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// AppointmentStatus is a stage in the lifecycle of an appointment.
+type AppointmentStatus string
+
+const (
+	StatusScheduled  AppointmentStatus = "scheduled"
+	StatusConfirmed  AppointmentStatus = "confirmed"
+	StatusCheckedIn  AppointmentStatus = "checked_in"
+	StatusInProgress AppointmentStatus = "in_progress"
+	StatusCompleted  AppointmentStatus = "completed"
+	StatusCancelled  AppointmentStatus = "cancelled"
+	StatusNoShow     AppointmentStatus = "no_show"
+)
+
+// maxCancellationNoteLength is the maximal length of the free text explaining a cancellation.
+const maxCancellationNoteLength = 1000
+
+// CancellationReason is the reason code of a cancelled appointment.
+type CancellationReason string
+
+const (
+	ReasonPatientRequest    CancellationReason = "patient_request"
+	ReasonDoctorUnavailable CancellationReason = "doctor_unavailable"
+	ReasonClinicClosed      CancellationReason = "clinic_closed"
+	ReasonRescheduled       CancellationReason = "rescheduled"
+	ReasonDuplicate         CancellationReason = "duplicate"
+	ReasonOther             CancellationReason = "other"
+)
+
+// canTransitionTo reports whether an appointment in this status can be moved to the next status.
+// An appointment is confirmed by the patient, checked in on arrival, started and completed by the doctor.
+// It can be cancelled until the visit starts and marked as a no-show until the patient checks in. Completed, cancelled
+// and no-show appointments are final.
+func (current AppointmentStatus) canTransitionTo(next AppointmentStatus) bool {
+	switch current {
+	case StatusScheduled:
+		return next == StatusConfirmed || next == StatusCheckedIn || next == StatusCancelled || next == StatusNoShow
+	case StatusConfirmed:
+		return next == StatusCheckedIn || next == StatusCancelled || next == StatusNoShow
+	case StatusCheckedIn:
+		return next == StatusInProgress || next == StatusCancelled
+	case StatusInProgress:
+		return next == StatusCompleted
+	case StatusCompleted, StatusCancelled, StatusNoShow:
+		return false
+	default:
+		return false
+	}
+}
+
+// whereActive restricts the query to appointments that occupy their time, i.e. that are not cancelled.
+func whereActive(query *bun.SelectQuery) *bun.SelectQuery {
+	return query.Where("? != ?", bun.Ident("status"), StatusCancelled)
+}
+
+// approvedByPatient reports whether the patient has confirmed the appointment.
+func (current AppointmentStatus) approvedByPatient() bool {
+	switch current {
+	case StatusConfirmed, StatusCheckedIn, StatusInProgress, StatusCompleted:
+		return true
+	case StatusScheduled, StatusCancelled, StatusNoShow:
+		return false
+	default:
+		return false
+	}
+}
+
+// visited reports whether the patient has arrived to the appointment.
+func (current AppointmentStatus) visited() bool {
+	switch current {
+	case StatusCheckedIn, StatusInProgress, StatusCompleted:
+		return true
+	case StatusScheduled, StatusConfirmed, StatusCancelled, StatusNoShow:
+		return false
+	default:
+		return false
+	}
+}
+
+// upcoming reports whether the appointment is still to take place, i.e. it is neither cancelled
+// nor has it started or been missed.
+func (current AppointmentStatus) upcoming() bool {
+	switch current {
+	case StatusScheduled, StatusConfirmed:
+		return true
+	case StatusCheckedIn, StatusInProgress, StatusCompleted, StatusCancelled, StatusNoShow:
+		return false
+	default:
+		return false
+	}
+}
+
+// toGRPC returns a GRPC version of AppointmentStatus.
+func (current AppointmentStatus) toGRPC() ppb.AppointmentStatus {
+	switch current {
+	case StatusScheduled:
+		return ppb.AppointmentStatus_APPOINTMENT_STATUS_SCHEDULED
+	case StatusConfirmed:
+		return ppb.AppointmentStatus_APPOINTMENT_STATUS_CONFIRMED
+	case StatusCheckedIn:
+		return ppb.AppointmentStatus_APPOINTMENT_STATUS_CHECKED_IN
+	case StatusInProgress:
+		return ppb.AppointmentStatus_APPOINTMENT_STATUS_IN_PROGRESS
+	case StatusCompleted:
+		return ppb.AppointmentStatus_APPOINTMENT_STATUS_COMPLETED
+	case StatusCancelled:
+		return ppb.AppointmentStatus_APPOINTMENT_STATUS_CANCELLED
+	case StatusNoShow:
+		return ppb.AppointmentStatus_APPOINTMENT_STATUS_NO_SHOW
+	default:
+		return ppb.AppointmentStatus_APPOINTMENT_STATUS_UNSPECIFIED
+	}
+}
+
+// statusFromGRPC returns the AppointmentStatus of a GRPC status.
+// Reports false if the status is not specified or unknown.
+func statusFromGRPC(status ppb.AppointmentStatus) (AppointmentStatus, bool) {
+	switch status {
+	case ppb.AppointmentStatus_APPOINTMENT_STATUS_SCHEDULED:
+		return StatusScheduled, true
+	case ppb.AppointmentStatus_APPOINTMENT_STATUS_CONFIRMED:
+		return StatusConfirmed, true
+	case ppb.AppointmentStatus_APPOINTMENT_STATUS_CHECKED_IN:
+		return StatusCheckedIn, true
+	case ppb.AppointmentStatus_APPOINTMENT_STATUS_IN_PROGRESS:
+		return StatusInProgress, true
+	case ppb.AppointmentStatus_APPOINTMENT_STATUS_COMPLETED:
+		return StatusCompleted, true
+	case ppb.AppointmentStatus_APPOINTMENT_STATUS_CANCELLED:
+		return StatusCancelled, true
+	case ppb.AppointmentStatus_APPOINTMENT_STATUS_NO_SHOW:
+		return StatusNoShow, true
+	case ppb.AppointmentStatus_APPOINTMENT_STATUS_UNSPECIFIED:
+		return "", false
+	default:
+		return "", false
+	}
+}
+
+// toGRPC returns a GRPC version of CancellationReason.
+func (reason CancellationReason) toGRPC() ppb.CancellationReason {
+	switch reason {
+	case ReasonPatientRequest:
+		return ppb.CancellationReason_CANCELLATION_REASON_PATIENT_REQUEST
+	case ReasonDoctorUnavailable:
+		return ppb.CancellationReason_CANCELLATION_REASON_DOCTOR_UNAVAILABLE
+	case ReasonClinicClosed:
+		return ppb.CancellationReason_CANCELLATION_REASON_CLINIC_CLOSED
+	case ReasonRescheduled:
+		return ppb.CancellationReason_CANCELLATION_REASON_RESCHEDULED
+	case ReasonDuplicate:
+		return ppb.CancellationReason_CANCELLATION_REASON_DUPLICATE
+	case ReasonOther:
+		return ppb.CancellationReason_CANCELLATION_REASON_OTHER
+	default:
+		return ppb.CancellationReason_CANCELLATION_REASON_UNSPECIFIED
+	}
+}
+
+// cancellationFromGRPC validates the reason and the note of a cancellation request.
+// Returns codes.InvalidArgument if the reason is not set or the note is too long.
+func cancellationFromGRPC(reason ppb.CancellationReason, note string) (CancellationReason, string, error) {
+	if len(note) > maxCancellationNoteLength {
+		return "", "", invalidArgumentError("note",
+			fmt.Sprintf("note can't be longer than %d characters", maxCancellationNoteLength))
+	}
+	switch reason {
+	case ppb.CancellationReason_CANCELLATION_REASON_PATIENT_REQUEST:
+		return ReasonPatientRequest, note, nil
+	case ppb.CancellationReason_CANCELLATION_REASON_DOCTOR_UNAVAILABLE:
+		return ReasonDoctorUnavailable, note, nil
+	case ppb.CancellationReason_CANCELLATION_REASON_CLINIC_CLOSED:
+		return ReasonClinicClosed, note, nil
+	case ppb.CancellationReason_CANCELLATION_REASON_RESCHEDULED:
+		return ReasonRescheduled, note, nil
+	case ppb.CancellationReason_CANCELLATION_REASON_DUPLICATE:
+		return ReasonDuplicate, note, nil
+	case ppb.CancellationReason_CANCELLATION_REASON_OTHER:
+		return ReasonOther, note, nil
+	case ppb.CancellationReason_CANCELLATION_REASON_UNSPECIFIED:
+		return "", "", invalidArgumentError("reason", "cancellation reason is required")
+	default:
+		return "", "", invalidArgumentError("reason", "unknown cancellation reason")
+	}
+}
+
+// changeStatus moves the appointment to the next status and records the transition.
+// Returns codes.FailedPrecondition if the transition is not allowed from the current status.
+// The appointment has to be locked by the transaction, so that concurrent transitions are serialized.
+func changeStatus(ctx context.Context, tx bun.Tx, appointment *Appointment,
+	next AppointmentStatus, actor string) error {
+	if !appointment.Status.canTransitionTo(next) {
+		return status.Error(codes.FailedPrecondition,
+			fmt.Sprintf("an appointment can't be moved from %s to %s", appointment.Status, next))
+	}
+	return recordStatus(ctx, tx, appointment, next, actor)
+}
+
+// recordStatus saves the next status of the appointment and records the transition without checking it.
+// The cancellation reason is kept only when the appointment is cancelled.
+func recordStatus(ctx context.Context, tx bun.Tx, appointment *Appointment,
+	next AppointmentStatus, actor string) error {
+	change := StatusChange{
+		TenantID:      appointment.TenantID,
+		AppointmentID: appointment.ID,
+		FromStatus:    appointment.Status,
+		ToStatus:      next,
+		ChangedBy:     actor,
+	}
+	former := *appointment
+	appointment.Status = next
+	if next != StatusCancelled {
+		appointment.CancellationReason = ""
+		appointment.CancellationNote = ""
+	}
+	_, err := tx.NewUpdate().
+		Model(appointment).
+		Column("status", "cancellation_reason", "cancellation_note").
+		WherePK().
+		Returning("version").
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err = tx.NewInsert().Model(&change).Exec(ctx); err != nil {
+		return err
+	}
+
+	eventType := EventStatusChanged
+	if next == StatusCancelled {
+		eventType = EventCancelled
+	}
+	return recordEvent(ctx, tx, eventType, appointment, &former, actor)
+}
+
+// statusBeforeCancellation returns the status the appointment of the tenant had when it was cancelled most recently.
+func statusBeforeCancellation(ctx context.Context, db bun.IDB, tenantID string, id int32) (AppointmentStatus, error) {
+	change := new(StatusChange)
+	err := whereTenant(db.NewSelect().Model(change), tenantID).
+		Where("? = ?", bun.Ident("appointment_id"), id).
+		Where("? = ?", bun.Ident("to_status"), StatusCancelled).
+		Order("changed_at DESC", "id DESC").
+		Limit(1).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return StatusScheduled, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return change.FromStatus, nil
+}
+
+// transitionAppointment moves the appointment with the given ID to the next status on behalf of the token's user.
+// If prepare is not nil, it is applied to the locked appointment before the transition.
+// Requires authentication. If authentication is not valid, codes.Unauthenticated is returned.
+// Requires the permission of the next status, see statusPermission. If roles are not sufficient
+// or the appointment is not accessible, codes.PermissionDenied is returned.
+// If the appointment with the given ID doesn't exist, codes.NotFound is returned.
+// If the transition is not allowed from the current status, codes.FailedPrecondition is returned.
+func (server appointmentsServer) transitionAppointment(ctx context.Context,
+	token string, id int32, next AppointmentStatus, prepare func(*Appointment)) (ppb.AppointmentStatus, error) {
+	caller, err := server.authorize(ctx, token, statusPermission(next))
+	if err != nil {
+		return ppb.AppointmentStatus_APPOINTMENT_STATUS_UNSPECIFIED, err
+	}
+
+	appointment := new(Appointment)
+	err = runInTenantTx(ctx, server.db, caller.tenantID, func(ctx context.Context, tx bun.Tx) error {
+		txErr := whereTenant(tx.NewSelect().Model(appointment), caller.tenantID).
+			Where("? = ?", bun.Ident("id"), id).
+			For("UPDATE").
+			Scan(ctx)
+		if txErr != nil {
+			return dbError(txErr, "failed to fetch an appointment by id")
+		}
+		if txErr = caller.check(appointment); txErr != nil {
+			return txErr
+		}
+		former := *appointment
+		if prepare != nil {
+			prepare(appointment)
+		}
+		if txErr = changeStatus(ctx, tx, appointment, next, tokenActor(token)); txErr != nil {
+			return txErr
+		}
+		return server.offerReleasedSlot(ctx, tx, &former, appointment)
+	})
+	if err != nil {
+		return ppb.AppointmentStatus_APPOINTMENT_STATUS_UNSPECIFIED, dbError(err, "failed to change appointment status")
+	}
+
+	return appointment.Status.toGRPC(), nil
+}
+
+// ConfirmAppointment marks a scheduled appointment as confirmed by the patient.
+// Errors are described in transitionAppointment.
+func (server appointmentsServer) ConfirmAppointment(ctx context.Context,
+	req *ppb.ConfirmAppointmentRequest) (*ppb.ConfirmAppointmentResponse, error) {
+	appointmentStatus, err := server.transitionAppointment(ctx, req.GetToken(), req.GetId(), StatusConfirmed, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &ppb.ConfirmAppointmentResponse{Status: appointmentStatus}, nil
+}
+
+// CheckInAppointment marks the patient of a scheduled or confirmed appointment as arrived.
+// Errors are described in transitionAppointment.
+func (server appointmentsServer) CheckInAppointment(ctx context.Context,
+	req *ppb.CheckInAppointmentRequest) (*ppb.CheckInAppointmentResponse, error) {
+	appointmentStatus, err := server.transitionAppointment(ctx, req.GetToken(), req.GetId(), StatusCheckedIn, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &ppb.CheckInAppointmentResponse{Status: appointmentStatus}, nil
+}
+
+// StartAppointment marks a checked-in appointment as in progress.
+// Errors are described in transitionAppointment.
+func (server appointmentsServer) StartAppointment(ctx context.Context,
+	req *ppb.StartAppointmentRequest) (*ppb.StartAppointmentResponse, error) {
+	appointmentStatus, err := server.transitionAppointment(ctx, req.GetToken(), req.GetId(), StatusInProgress, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &ppb.StartAppointmentResponse{Status: appointmentStatus}, nil
+}
+
+// CompleteAppointment marks an appointment in progress as completed.
+// Errors are described in transitionAppointment.
+func (server appointmentsServer) CompleteAppointment(ctx context.Context,
+	req *ppb.CompleteAppointmentRequest) (*ppb.CompleteAppointmentResponse, error) {
+	appointmentStatus, err := server.transitionAppointment(ctx, req.GetToken(), req.GetId(), StatusCompleted, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &ppb.CompleteAppointmentResponse{Status: appointmentStatus}, nil
+}
+
+// CancelAppointment cancels an appointment that has not started yet. A cancelled appointment frees its time.
+// The reason is required. If it is missing or the note is too long, codes.InvalidArgument is returned.
+// Other errors are described in transitionAppointment.
+func (server appointmentsServer) CancelAppointment(ctx context.Context,
+	req *ppb.CancelAppointmentRequest) (*ppb.CancelAppointmentResponse, error) {
+	reason, note, err := cancellationFromGRPC(req.GetReason(), req.GetNote())
+	if err != nil {
+		return nil, err
+	}
+
+	appointmentStatus, err := server.transitionAppointment(ctx, req.GetToken(), req.GetId(), StatusCancelled,
+		func(appointment *Appointment) {
+			appointment.CancellationReason = reason
+			appointment.CancellationNote = note
+		})
+	if err != nil {
+		return nil, err
+	}
+	return &ppb.CancelAppointmentResponse{Status: appointmentStatus}, nil
+}
+
+// MarkNoShow marks a scheduled or confirmed appointment as missed by the patient.
+// Errors are described in transitionAppointment.
+func (server appointmentsServer) MarkNoShow(ctx context.Context,
+	req *ppb.MarkNoShowRequest) (*ppb.MarkNoShowResponse, error) {
+	appointmentStatus, err := server.transitionAppointment(ctx, req.GetToken(), req.GetId(), StatusNoShow, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &ppb.MarkNoShowResponse{Status: appointmentStatus}, nil
+}
+
+// RestoreAppointment undoes a deletion or a cancellation of an appointment.
+// A deleted appointment is undeleted and keeps its status. A cancelled appointment returns to the status
+// it had before it was cancelled. A restored appointment has to pass the same scheduling checks as a new one.
+// Requires authentication. If authentication is not valid, codes.Unauthenticated is returned.
+// Requires the delete_appointments permission, see accessPolicy. If roles are not sufficient
+// or the appointment is not accessible, codes.PermissionDenied is returned.
+// If ignore_schedule is set and the roles don't grant the override_schedule permission,
+// codes.PermissionDenied is returned.
+// If the appointment with the given ID doesn't exist, codes.NotFound is returned.
+// If the appointment is neither deleted nor cancelled, codes.FailedPrecondition is returned.
+// If the appointment is outside of the doctor's working hours and ignore_schedule is not set,
+// codes.FailedPrecondition is returned.
+// If the appointment overlaps another appointment of the same doctor, codes.AlreadyExists is returned.
+// If the appointment overlaps another appointment of the same patient and the patient overlap policy
+// is reject, codes.AlreadyExists is returned.
+func (server appointmentsServer) RestoreAppointment(ctx context.Context,
+	req *ppb.RestoreAppointmentRequest) (*ppb.RestoreAppointmentResponse, error) {
+	caller, err := server.authorize(ctx, req.GetToken(), permDeleteAppointments)
+	if err != nil {
+		return nil, err
+	}
+	if err = server.authorizeScheduleOverride(ctx, req.GetToken(), req.GetIgnoreSchedule()); err != nil {
+		return nil, err
+	}
+
+	appointment := new(Appointment)
+	var warnings []*ppb.SchedulingWarning
+	err = runInTenantTx(ctx, server.db, caller.tenantID, func(ctx context.Context, tx bun.Tx) error {
+		txErr := whereTenant(tx.NewSelect().Model(appointment), caller.tenantID).
+			Where("? = ?", bun.Ident("id"), req.GetId()).
+			WhereAllWithDeleted().
+			For("UPDATE").
+			Scan(ctx)
+		if txErr != nil {
+			return dbError(txErr, "failed to fetch an appointment by id")
+		}
+		if txErr = caller.check(appointment); txErr != nil {
+			return txErr
+		}
+
+		deleted := !appointment.DeletedAt.IsZero()
+		if !deleted && appointment.Status != StatusCancelled {
+			return status.Error(codes.FailedPrecondition, "the appointment is neither deleted nor cancelled")
+		}
+
+		// The checks are run against the appointment as it will be after the restore.
+		restored := *appointment
+		restored.DeletedAt = time.Time{}
+		if !deleted {
+			restored.Status, txErr = statusBeforeCancellation(ctx, tx, appointment.TenantID, appointment.ID)
+			if txErr != nil {
+				return txErr
+			}
+		}
+		if restored.Status != StatusCancelled {
+			warnings, txErr = server.checkAppointment(ctx, tx, &restored, req.GetIgnoreSchedule())
+			if txErr != nil {
+				return txErr
+			}
+		}
+
+		if deleted {
+			former := *appointment
+			appointment.DeletedAt = time.Time{}
+			_, txErr = tx.NewUpdate().
+				Model(appointment).
+				Set("? = NULL", bun.Ident("deleted_at")).
+				WherePK().
+				WhereAllWithDeleted().
+				Returning("version").
+				Exec(ctx)
+			if txErr != nil {
+				return txErr
+			}
+			return recordEvent(ctx, tx, EventRestored, appointment, &former, tokenActor(req.GetToken()))
+		}
+		return recordStatus(ctx, tx, appointment, restored.Status, tokenActor(req.GetToken()))
+	})
+	if err != nil {
+		return nil, writeError(ctx, server.db, appointment, err, "failed to restore an appointment")
+	}
+
+	return &ppb.RestoreAppointmentResponse{Status: appointment.Status.toGRPC(), Warnings: warnings}, nil
+}
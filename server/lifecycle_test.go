@@ -0,0 +1,34 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCanTransitionTo(t *testing.T) {
+	statuses := []AppointmentStatus{StatusScheduled, StatusConfirmed, StatusCheckedIn, StatusInProgress,
+		StatusCompleted, StatusCancelled, StatusNoShow}
+	tests := []struct {
+		current AppointmentStatus
+		allowed []AppointmentStatus
+	}{
+		{StatusScheduled, []AppointmentStatus{StatusConfirmed, StatusCheckedIn, StatusCancelled, StatusNoShow}},
+		{StatusConfirmed, []AppointmentStatus{StatusCheckedIn, StatusCancelled, StatusNoShow}},
+		{StatusCheckedIn, []AppointmentStatus{StatusInProgress, StatusCancelled}},
+		{StatusInProgress, []AppointmentStatus{StatusCompleted}},
+		{StatusCompleted, nil},
+		{StatusCancelled, nil},
+		{StatusNoShow, nil},
+		{"unknown", nil},
+	}
+	for _, test := range tests {
+		t.Run(string(test.current), func(t *testing.T) {
+			for _, next := range statuses {
+				want := slices.Contains(test.allowed, next)
+				if got := test.current.canTransitionTo(next); got != want {
+					t.Errorf("%s.canTransitionTo(%s) = %v, want %v", test.current, next, got, want)
+				}
+			}
+		})
+	}
+}
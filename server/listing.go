@@ -0,0 +1,332 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	ppb "github.com/TekClinic/Appointments-MicroService/appointments_protobuf"
+	"github.com/uptrace/bun"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// maxFilterIDs is the maximal number of doctor or patient IDs appointments can be filtered by at once.
+const maxFilterIDs = 100
+
+// appointmentsRequest is a common interface of the requests that list appointments.
+type appointmentsRequest interface {
+	proto.Message
+	GetDate() string
+	GetLocationId() int32
+	GetFrom() string
+	GetTo() string
+	GetDoctorId() int32
+	GetDoctorIds() []int32
+	GetPatientId() int32
+	GetPatientIds() []int32
+	GetStatuses() []ppb.AppointmentStatus
+	GetIncludeDeleted() bool
+	GetUnassignedOnly() bool
+	GetSortOrder() ppb.AppointmentSortOrder
+	GetSkip() int32
+	GetLimit() int32
+	GetPageToken() string
+	GetSkipCount() bool
+}
+
+// filterAppointments applies the filters and the sort order of the request to the query of appointments.
+// All filters are combined, so that an appointment is returned only if it matches every one of them.
+// Returns codes.InvalidArgument if one of the filters is malformed
+// and codes.NotFound if the location filtered by doesn't exist in the tenant.
+func filterAppointments(ctx context.Context, db bun.IDB, tenantID string,
+	query *bun.SelectQuery, req appointmentsRequest) (*bun.SelectQuery, error) {
+	if err := validatePagination(req.GetSkip(), req.GetLimit()); err != nil {
+		return nil, err
+	}
+
+	// Filter by location, whose timezone defines the day of the date filter
+	day := time.UTC
+	if req.GetLocationId() < 0 {
+		return nil, invalidArgumentError("location_id", "LocationID has to be a non-negative value")
+	}
+	if req.GetLocationId() != 0 {
+		location := new(Location)
+		err := whereTenant(db.NewSelect().Model(location), tenantID).
+			Where("? = ?", bun.Ident("id"), req.GetLocationId()).
+			WhereAllWithDeleted().
+			Scan(ctx)
+		if err != nil {
+			return nil, dbError(err, "failed to fetch a location by id")
+		}
+		if day, err = location.timeLocation(); err != nil {
+			return nil, dbError(err, "failed to load the timezone of the location")
+		}
+		query = query.Where("? = ?", bun.Ident("location_id"), location.ID)
+	}
+
+	query, err := filterTimeRange(query, req, day)
+	if err != nil {
+		return nil, err
+	}
+
+	// Filter by doctor IDs
+	doctorIDs, err := filterIDs("doctor_ids", req.GetDoctorId(), req.GetDoctorIds())
+	if err != nil {
+		return nil, err
+	}
+	if len(doctorIDs) != 0 {
+		query = query.Where("? IN (?)", bun.Ident("doctor_id"), bun.In(doctorIDs))
+	}
+
+	// Filter by patient IDs or by the absence of a patient
+	patientIDs, err := filterIDs("patient_ids", req.GetPatientId(), req.GetPatientIds())
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case req.GetUnassignedOnly() && len(patientIDs) != 0:
+		return nil, invalidArgumentError("unassigned_only", "unassigned_only can't be combined with patient IDs")
+	case req.GetUnassignedOnly():
+		query = query.Where("? = 0", bun.Ident("patient_id"))
+	case len(patientIDs) != 0:
+		query = query.Where("? IN (?)", bun.Ident("patient_id"), bun.In(patientIDs))
+	}
+
+	// Filter by statuses
+	if len(req.GetStatuses()) != 0 {
+		statuses := make([]AppointmentStatus, 0, len(req.GetStatuses()))
+		for _, grpcStatus := range req.GetStatuses() {
+			appointmentStatus, ok := statusFromGRPC(grpcStatus)
+			if !ok {
+				return nil, invalidArgumentError("statuses", "statuses have to be specified and known")
+			}
+			statuses = append(statuses, appointmentStatus)
+		}
+		query = query.Where("? IN (?)", bun.Ident("status"), bun.In(statuses))
+	}
+
+	if req.GetIncludeDeleted() {
+		query = query.WhereAllWithDeleted()
+	}
+
+	column, descending, err := sortColumn(req.GetSortOrder())
+	if err != nil {
+		return nil, err
+	}
+	direction := "ASC"
+	if descending {
+		direction = "DESC"
+	}
+	return query.OrderExpr("? "+direction+", ? "+direction, bun.Ident(column), bun.Ident("id")), nil
+}
+
+// filterTimeRange restricts the query to the appointments that overlap the date and the from-to range of the request.
+// The date is a local day in the given timezone. An appointment that crosses midnight belongs to both days.
+// Either bound of the range may be omitted.
+// Returns codes.InvalidArgument if the date or the range is malformed.
+func filterTimeRange(query *bun.SelectQuery, req appointmentsRequest, day *time.Location) (*bun.SelectQuery, error) {
+	if req.GetDate() != "" {
+		date, err := time.ParseInLocation(dateFormat, req.GetDate(), day)
+		if err != nil {
+			return nil, invalidArgumentError("date", fmt.Errorf("failed to parse date: %w", err).Error())
+		}
+		query = whereOverlaps(query, timeRange{Start: date, End: date.AddDate(0, 0, 1)})
+	}
+
+	var from time.Time
+	if req.GetFrom() != "" {
+		var err error
+		if from, err = time.Parse(time.RFC3339, req.GetFrom()); err != nil {
+			return nil, invalidArgumentError("from", fmt.Errorf("failed to parse from: %w", err).Error())
+		}
+		query = query.Where("? > ?", bun.Ident("end_time"), from)
+	}
+	if req.GetTo() != "" {
+		to, err := time.Parse(time.RFC3339, req.GetTo())
+		if err != nil {
+			return nil, invalidArgumentError("to", fmt.Errorf("failed to parse to: %w", err).Error())
+		}
+		if !from.IsZero() && !to.After(from) {
+			return nil, invalidArgumentError("to", "to has to be after from")
+		}
+		query = query.Where("? < ?", bun.Ident("start_time"), to)
+	}
+	return query, nil
+}
+
+// filterIDs merges the single ID filter with the list of IDs of the same field and removes duplicates.
+// Returns codes.InvalidArgument if one of the IDs is not positive or there are too many of them.
+func filterIDs(field string, id int32, ids []int32) ([]int32, error) {
+	merged := slices.Clone(ids)
+	if id != 0 {
+		merged = append(merged, id)
+	}
+	slices.Sort(merged)
+	merged = slices.Compact(merged)
+	if len(merged) > maxFilterIDs {
+		return nil, invalidArgumentError(field, fmt.Sprintf("at most %d IDs can be filtered by at once", maxFilterIDs))
+	}
+	if len(merged) != 0 && merged[0] <= 0 {
+		return nil, invalidArgumentError(field, "IDs have to be positive values")
+	}
+	return merged, nil
+}
+
+// sortColumn returns the column appointments are sorted by and whether the order is descending.
+// Appointments with equal values of the column are sorted by ID in the same direction, so that the order is stable.
+// If the sort order is not specified, appointments are sorted by ascending start time.
+// Returns codes.InvalidArgument if the sort order is unknown.
+func sortColumn(order ppb.AppointmentSortOrder) (string, bool, error) {
+	switch order {
+	case ppb.AppointmentSortOrder_APPOINTMENT_SORT_ORDER_UNSPECIFIED,
+		ppb.AppointmentSortOrder_APPOINTMENT_SORT_ORDER_START_TIME_ASC:
+		return "start_time", false, nil
+	case ppb.AppointmentSortOrder_APPOINTMENT_SORT_ORDER_START_TIME_DESC:
+		return "start_time", true, nil
+	case ppb.AppointmentSortOrder_APPOINTMENT_SORT_ORDER_CREATED_AT_ASC:
+		return "created_at", false, nil
+	case ppb.AppointmentSortOrder_APPOINTMENT_SORT_ORDER_CREATED_AT_DESC:
+		return "created_at", true, nil
+	default:
+		return "", false, invalidArgumentError("sort_order", "unknown sort order")
+	}
+}
+
+// maskColumns returns the columns needed to build the fields of the mask
+// and whether the status changes have to be fetched. An empty mask selects all fields.
+// The ID and the sort columns are always selected.
+func maskColumns(mask *fieldmaskpb.FieldMask) ([]string, bool) {
+	if len(mask.GetPaths()) == 0 {
+		return nil, true
+	}
+
+	// The sort columns are needed to issue page tokens.
+	columns := []string{"id", "start_time", "created_at"}
+	withStatusChanges := false
+	for _, path := range mask.GetPaths() {
+		switch path {
+		case "patient_id", "doctor_id", "start_time", "end_time", "series_id", "type_id",
+			"resource_ids", "location_id", "cancellation_reason", "cancellation_note":
+			columns = append(columns, path)
+		case "status", "approved_by_patient", "visited":
+			columns = append(columns, "status")
+		case "deleted", "deleted_at":
+			columns = append(columns, "deleted_at")
+		case "version", "etag":
+			columns = append(columns, "version")
+		case "status_changes":
+			// The status changes are joined by the tenant and the ID of the appointment.
+			columns = append(columns, "tenant_id")
+			withStatusChanges = true
+		}
+	}
+	slices.Sort(columns)
+	return slices.Compact(columns), withStatusChanges
+}
+
+// validateReadMask returns codes.InvalidArgument if the mask lists a field that is not a field of
+// GetAppointmentResponse. Only top-level fields can be selected: a nested path such as status_changes.to_status
+// is rejected, since the mask selects whole fields of the appointments.
+func validateReadMask(mask *fieldmaskpb.FieldMask) error {
+	for _, path := range mask.GetPaths() {
+		if top, _, nested := strings.Cut(path, "."); nested {
+			return invalidArgumentError("read_mask",
+				fmt.Sprintf("nested field %q can't be selected, select %q instead", path, top))
+		}
+	}
+	if mask != nil && !mask.IsValid((*ppb.GetAppointmentResponse)(nil)) {
+		return invalidArgumentError("read_mask", "read mask refers to unknown appointment fields")
+	}
+	return nil
+}
+
+// applyReadMask clears the fields of the message that are not listed in the mask.
+// An empty mask keeps all fields.
+func applyReadMask(message proto.Message, mask *fieldmaskpb.FieldMask) {
+	if len(mask.GetPaths()) == 0 {
+		return
+	}
+
+	reflected := message.ProtoReflect()
+	var cleared []protoreflect.FieldDescriptor
+	reflected.Range(func(field protoreflect.FieldDescriptor, _ protoreflect.Value) bool {
+		if !slices.Contains(mask.GetPaths(), string(field.Name())) {
+			cleared = append(cleared, field)
+		}
+		return true
+	})
+	for _, field := range cleared {
+		reflected.Clear(field)
+	}
+}
+
+// ListAppointments returns a list of appointments based on provided filters, like GetAppointments,
+// but with the appointments themselves instead of their IDs. If read_mask is set,
+// only the listed fields of the appointments are fetched and returned.
+// Requires authentication. If authentication is not valid, codes.Unauthenticated is returned.
+// Requires the read_appointments permission, see accessPolicy. If roles are not sufficient,
+// codes.PermissionDenied is returned. Doctors and patients only get their own appointments.
+// If location_id is set, date is a day in the timezone of the location, otherwise in UTC.
+// If one of the filters, the page token or the read mask is invalid, codes.InvalidArgument is returned.
+// If the location doesn't exist, codes.NotFound is returned.
+func (server appointmentsServer) ListAppointments(ctx context.Context,
+	req *ppb.ListAppointmentsRequest) (*ppb.ListAppointmentsResponse, error) {
+	caller, err := server.authorize(ctx, req.GetToken(), permReadAppointments)
+	if err != nil {
+		return nil, err
+	}
+
+	mask := req.GetReadMask()
+	if err = validateReadMask(mask); err != nil {
+		return nil, err
+	}
+
+	var appointments []Appointment
+	var count int
+	err = runInTenantTx(ctx, server.db, caller.tenantID, func(ctx context.Context, tx bun.Tx) error {
+		query, txErr := filterAppointments(ctx, tx, caller.tenantID,
+			caller.scope(tx.NewSelect().Model(&appointments)), req)
+		if txErr != nil {
+			return txErr
+		}
+
+		if !req.GetSkipCount() {
+			if count, txErr = query.Count(ctx); txErr != nil {
+				return dbError(txErr, "failed to count appointments")
+			}
+		}
+
+		columns, withStatusChanges := maskColumns(mask)
+		if query, txErr = paginate(query.Column(columns...), req); txErr != nil {
+			return txErr
+		}
+		if withStatusChanges {
+			query = query.Relation("StatusChanges", func(query *bun.SelectQuery) *bun.SelectQuery {
+				return query.Order("changed_at", "id")
+			})
+		}
+		return query.Scan(ctx)
+	})
+	if err != nil {
+		return nil, dbError(err, "failed to fetch appointments")
+	}
+	appointments, nextPageToken, err := nextPage(appointments, req)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &ppb.ListAppointmentsResponse{
+		Count:         int32(count),
+		Appointments:  make([]*ppb.GetAppointmentResponse, len(appointments)),
+		NextPageToken: nextPageToken,
+	}
+	for i := range appointments {
+		response.Appointments[i] = appointments[i].toGRPC()
+		applyReadMask(response.GetAppointments()[i], mask)
+	}
+	return response, nil
+}
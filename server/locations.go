@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	ppb "github.com/TekClinic/Appointments-MicroService/appointments_protobuf"
+	"github.com/uptrace/bun"
+)
+
+const (
+	// locationNameConstraint is the name of the unique index on the names of locations.
+	locationNameConstraint = "locations_name_key"
+
+	maxLocationNameLength = 100
+)
+
+// toGRPC returns a GRPC version of Location.
+func (location Location) toGRPC() *ppb.Location {
+	return &ppb.Location{
+		Id:       location.ID,
+		Name:     location.Name,
+		Timezone: location.Timezone,
+		Deleted:  !location.DeletedAt.IsZero(),
+	}
+}
+
+// timeLocation returns the timezone of the location.
+func (location Location) timeLocation() (*time.Location, error) {
+	return time.LoadLocation(location.Timezone)
+}
+
+// locationRequest is implemented by requests that define a location.
+type locationRequest interface {
+	GetName() string
+	GetTimezone() string
+}
+
+// locationFromGRPC validates the location definition of the request and converts it to Location.
+// The timezone defaults to defaultTimezone.
+// Returns codes.InvalidArgument if the definition is invalid.
+func locationFromGRPC(req locationRequest) (*Location, error) {
+	name := strings.TrimSpace(req.GetName())
+	if name == "" {
+		return nil, invalidArgumentError("name", "name is required")
+	}
+	if len(name) > maxLocationNameLength {
+		return nil, invalidArgumentError("name",
+			fmt.Sprintf("name has to be at most %d characters long", maxLocationNameLength))
+	}
+
+	location := &Location{Name: name, Timezone: req.GetTimezone()}
+	if location.Timezone == "" {
+		location.Timezone = defaultTimezone
+	}
+	if _, err := location.timeLocation(); err != nil {
+		return nil, invalidArgumentError("timezone", fmt.Errorf("failed to load timezone: %w", err).Error())
+	}
+	return location, nil
+}
+
+// fetchLocation returns the location of the tenant with the given ID that is not deleted.
+// Returns codes.NotFound if there is no such location.
+func fetchLocation(ctx context.Context, db bun.IDB, tenantID string, id int32) (*Location, error) {
+	location := new(Location)
+	query := db.NewSelect().Model(location).Where("? = ?", bun.Ident("id"), id)
+	if err := whereTenant(query, tenantID).Scan(ctx); err != nil {
+		return nil, dbError(err, "failed to fetch a location by id")
+	}
+	return location, nil
+}
+
+// fetchBookableLocation checks that the location of the tenant with the given ID can be booked.
+// Zero means no location and is always accepted.
+// Returns codes.InvalidArgument if the ID is negative and codes.NotFound if the location doesn't exist.
+func fetchBookableLocation(ctx context.Context, db bun.IDB, tenantID string, id int32) error {
+	if id < 0 {
+		return invalidArgumentError("location_id", "LocationID has to be a non-negative value")
+	}
+	if id == 0 {
+		return nil
+	}
+	_, err := fetchLocation(ctx, db, tenantID, id)
+	return err
+}
+
+// GetLocation returns the location with the given ID, including deleted locations.
+// Requires authentication. If authentication is not valid, codes.Unauthenticated is returned.
+// Requires the read_locations permission, see accessPolicy. If roles are not sufficient,
+// codes.PermissionDenied is returned.
+// If the location with the given ID doesn't exist, codes.NotFound is returned.
+func (server appointmentsServer) GetLocation(ctx context.Context,
+	req *ppb.GetLocationRequest) (*ppb.GetLocationResponse, error) {
+	caller, err := server.authorize(ctx, req.GetToken(), permReadLocations)
+	if err != nil {
+		return nil, err
+	}
+
+	location := new(Location)
+	err = runInTenantTx(ctx, server.db, caller.tenantID, func(ctx context.Context, tx bun.Tx) error {
+		return whereTenant(tx.NewSelect().Model(location), caller.tenantID).
+			Where("? = ?", bun.Ident("id"), req.GetId()).
+			WhereAllWithDeleted().
+			Scan(ctx)
+	})
+	if err != nil {
+		return nil, dbError(err, "failed to fetch a location by id")
+	}
+
+	return &ppb.GetLocationResponse{Location: location.toGRPC()}, nil
+}
+
+// ListLocations returns the locations sorted by name.
+// Requires authentication. If authentication is not valid, codes.Unauthenticated is returned.
+// Requires the read_locations permission, see accessPolicy. If roles are not sufficient,
+// codes.PermissionDenied is returned.
+// If skip or limit are invalid, codes.InvalidArgument is returned.
+func (server appointmentsServer) ListLocations(ctx context.Context,
+	req *ppb.ListLocationsRequest) (*ppb.ListLocationsResponse, error) {
+	caller, err := server.authorize(ctx, req.GetToken(), permReadLocations)
+	if err != nil {
+		return nil, err
+	}
+	if err = validatePagination(req.GetSkip(), req.GetLimit()); err != nil {
+		return nil, err
+	}
+
+	var locations []Location
+	query := whereTenant(server.db.NewSelect().Model(&locations), caller.tenantID)
+	if req.GetIncludeDeleted() {
+		query = query.WhereAllWithDeleted()
+	}
+	var count int
+	err = runInTenantTx(ctx, server.db, caller.tenantID, func(ctx context.Context, tx bun.Tx) error {
+		var txErr error
+		count, txErr = query.Conn(tx).
+			OrderExpr("lower(?), ?", bun.Ident("name"), bun.Ident("id")).
+			Offset(int(req.GetSkip())).
+			Limit(int(req.GetLimit())).
+			ScanAndCount(ctx)
+		return txErr
+	})
+	if err != nil {
+		return nil, dbError(err, "failed to fetch locations")
+	}
+
+	results := make([]*ppb.Location, len(locations))
+	for i, location := range locations {
+		results[i] = location.toGRPC()
+	}
+	return &ppb.ListLocationsResponse{Count: int32(count), Results: results}, nil
+}
+
+// CreateLocation adds a branch of the clinic.
+// Requires authentication. If authentication is not valid, codes.Unauthenticated is returned.
+// Requires the write_locations permission, see accessPolicy. If roles are not sufficient,
+// codes.PermissionDenied is returned.
+// If the location definition is invalid, codes.InvalidArgument is returned.
+// If another location has the same name, codes.AlreadyExists is returned.
+func (server appointmentsServer) CreateLocation(ctx context.Context,
+	req *ppb.CreateLocationRequest) (*ppb.CreateLocationResponse, error) {
+	caller, err := server.authorize(ctx, req.GetToken(), permWriteLocations)
+	if err != nil {
+		return nil, err
+	}
+	location, err := locationFromGRPC(req)
+	if err != nil {
+		return nil, err
+	}
+	location.TenantID = caller.tenantID
+
+	err = runInTenantTx(ctx, server.db, caller.tenantID, func(ctx context.Context, tx bun.Tx) error {
+		if _, txErr := tx.NewInsert().Model(location).Exec(ctx); txErr != nil {
+			return txErr
+		}
+		return recordAudit(ctx, tx, caller.tenantID, auditEntityLocation, location.ID, tokenActor(req.GetToken()),
+			nil, location.toGRPC())
+	})
+	if err != nil {
+		return nil, dbError(err, "failed to create a location")
+	}
+
+	return &ppb.CreateLocationResponse{Id: location.ID}, nil
+}
+
+// UpdateLocation renames a location or changes its timezone.
+// Existing appointments keep their time, only the local day they are listed under may change.
+// Requires authentication. If authentication is not valid, codes.Unauthenticated is returned.
+// Requires the write_locations permission, see accessPolicy. If roles are not sufficient,
+// codes.PermissionDenied is returned.
+// If the location definition is invalid, codes.InvalidArgument is returned.
+// If the location with the given ID doesn't exist, codes.NotFound is returned.
+// If another location has the same name, codes.AlreadyExists is returned.
+func (server appointmentsServer) UpdateLocation(ctx context.Context,
+	req *ppb.UpdateLocationRequest) (*ppb.UpdateLocationResponse, error) {
+	caller, err := server.authorize(ctx, req.GetToken(), permWriteLocations)
+	if err != nil {
+		return nil, err
+	}
+	location, err := locationFromGRPC(req)
+	if err != nil {
+		return nil, err
+	}
+	err = runInTenantTx(ctx, server.db, caller.tenantID, func(ctx context.Context, tx bun.Tx) error {
+		existing, txErr := fetchLocation(ctx, tx, caller.tenantID, req.GetId())
+		if txErr != nil {
+			return txErr
+		}
+		location.ID = existing.ID
+
+		if _, txErr = tx.NewUpdate().Model(location).Column("name", "timezone").WherePK().Exec(ctx); txErr != nil {
+			return txErr
+		}
+		return recordAudit(ctx, tx, caller.tenantID, auditEntityLocation, location.ID, tokenActor(req.GetToken()),
+			existing.toGRPC(), location.toGRPC())
+	})
+	if err != nil {
+		return nil, dbError(err, "failed to update a location")
+	}
+
+	return &ppb.UpdateLocationResponse{Id: location.ID}, nil
+}
+
+// DeleteLocation removes a location, after which appointments can't be booked at it.
+// Existing appointments at the location are not affected.
+// Requires authentication. If authentication is not valid, codes.Unauthenticated is returned.
+// Requires the write_locations permission, see accessPolicy. If roles are not sufficient,
+// codes.PermissionDenied is returned.
+// If the location with the given ID doesn't exist, codes.NotFound is returned.
+func (server appointmentsServer) DeleteLocation(ctx context.Context,
+	req *ppb.DeleteLocationRequest) (*ppb.DeleteLocationResponse, error) {
+	caller, err := server.authorize(ctx, req.GetToken(), permWriteLocations)
+	if err != nil {
+		return nil, err
+	}
+	err = runInTenantTx(ctx, server.db, caller.tenantID, func(ctx context.Context, tx bun.Tx) error {
+		location, txErr := fetchLocation(ctx, tx, caller.tenantID, req.GetId())
+		if txErr != nil {
+			return txErr
+		}
+		if _, txErr = tx.NewDelete().Model(location).WherePK().Exec(ctx); txErr != nil {
+			return txErr
+		}
+		return recordAudit(ctx, tx, caller.tenantID, auditEntityLocation, location.ID, tokenActor(req.GetToken()),
+			location.toGRPC(), nil)
+	})
+	if err != nil {
+		return nil, dbError(err, "failed to delete a location")
+	}
+
+	return &ppb.DeleteLocationResponse{Message: "Location deleted successfully"}, nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	ms "github.com/TekClinic/MicroService-Lib"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/driver/pgdriver"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// Environment variables of the database used by the tests that need one. The tests are skipped if
+// envTestDBAddress is not set. The user has to be an ordinary role, since row-level security
+// doesn't apply to superusers.
+const (
+	envTestDBAddress  = "TEST_DB_ADDR"
+	envTestDBUser     = "TEST_DB_USER"
+	envTestDBPassword = "TEST_DB_PASSWORD"
+	envTestDBDatabase = "TEST_DB_DATABASE"
+)
+
+// testClaims are the claims of a token verified by testBase.
+type testClaims sets.Set[string]
+
+// HasRole implements ms.Claims.HasRole.
+func (claims testClaims) HasRole(role string) bool {
+	return sets.Set[string](claims).Has(role)
+}
+
+// GetRoles implements ms.Claims.GetRoles.
+func (claims testClaims) GetRoles() sets.Set[string] {
+	return sets.Set[string](claims)
+}
+
+// testBase accepts the tokens built by testToken without checking their signature.
+type testBase struct{}
+
+// VerifyToken implements ms.BaseServiceServer.VerifyToken.
+func (testBase) VerifyToken(_ context.Context, rawToken string) (ms.Claims, error) {
+	parts := strings.Split(rawToken, ".")
+	if len(parts) != jwtParts {
+		return nil, errors.New("token is not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	var claims struct {
+		Roles []string `json:"roles"`
+	}
+	if err = json.Unmarshal(payload, &claims); err != nil {
+		return nil, err
+	}
+	return testClaims(sets.New(claims.Roles...)), nil
+}
+
+// GetPort implements ms.BaseServiceServer.GetPort.
+func (testBase) GetPort() string {
+	return ""
+}
+
+// testToken builds an unsigned token of the user with the given roles.
+func testToken(t *testing.T, identity tokenIdentity, roles ...role) string {
+	t.Helper()
+	names := make([]string, len(roles))
+	for i, r := range roles {
+		names[i] = string(r)
+	}
+	payload, err := json.Marshal(struct {
+		tokenIdentity
+		Roles []string `json:"roles"`
+	}{identity, names})
+	if err != nil {
+		t.Fatalf("failed to build a token: %v", err)
+	}
+	encode := base64.RawURLEncoding.EncodeToString
+	return encode([]byte(`{"alg":"none"}`)) + "." + encode(payload) + "." + encode([]byte("signature"))
+}
+
+// adminToken builds a token of an admin of the tenant.
+func adminToken(t *testing.T, tenantID string) string {
+	t.Helper()
+	return testToken(t, tokenIdentity{Subject: "admin", Username: "admin", TenantID: tenantID}, roleAdmin)
+}
+
+// newTestServer returns a server working with the given database and the default configuration.
+func newTestServer(t *testing.T, db *bun.DB) appointmentsServer {
+	t.Helper()
+	rules, err := parseBookingRules()
+	if err != nil {
+		t.Fatalf("failed to parse booking rules: %v", err)
+	}
+	return appointmentsServer{
+		BaseServiceServer:    testBase{},
+		db:                   db,
+		patientOverlapPolicy: overlapPolicyReject,
+		events:               newEventHub(),
+		outboxSink:           logSink{},
+		idempotencyTTL:       defaultIdempotencyTTL,
+		bookingRules:         rules,
+		waitlistOfferTTL:     defaultWaitlistOfferTTL,
+		slotHoldTTL:          defaultHoldTTL,
+	}
+}
+
+// openTestDB connects to the database at the address without checking that it is reachable.
+func openTestDB(t *testing.T, addr string) *bun.DB {
+	t.Helper()
+	connector := pgdriver.NewConnector(
+		pgdriver.WithNetwork("tcp"),
+		pgdriver.WithAddr(addr),
+		pgdriver.WithUser(ms.GetOptionalEnv(envTestDBUser, "appointments")),
+		pgdriver.WithPassword(ms.GetOptionalEnv(envTestDBPassword, "")),
+		pgdriver.WithDatabase(ms.GetOptionalEnv(envTestDBDatabase, "appointments")),
+		pgdriver.WithApplicationName(applicationName+"-test"),
+		pgdriver.WithInsecure(true),
+		pgdriver.WithDialTimeout(time.Second),
+	)
+	db := bun.NewDB(sql.OpenDB(connector), pgdialect.New())
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+// testDB returns the database of the tests with the schema of the service, or skips the test
+// if no database is configured.
+func testDB(t *testing.T) *bun.DB {
+	t.Helper()
+	addr := os.Getenv(envTestDBAddress)
+	if addr == "" {
+		t.Skipf("%s is not set", envTestDBAddress)
+	}
+	db := openTestDB(t, addr)
+	if err := createSchemaIfNotExists(context.Background(), db); err != nil {
+		t.Fatalf("failed to create schema: %v", err)
+	}
+	return db
+}
+
+// testTenant returns a tenant that has no records yet, so that the tests don't see each other's records.
+func testTenant(t *testing.T) string {
+	t.Helper()
+	return fmt.Sprintf("%s-%d", t.Name(), time.Now().UnixNano())
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const (
+	// outboxSinkLog writes domain events to the log. It is a local stand-in for development and tests.
+	outboxSinkLog = "log"
+	// outboxSinkWebhook posts domain events to the URL from the OUTBOX_WEBHOOK_URL environment variable.
+	outboxSinkWebhook = "webhook"
+
+	// outboxPollInterval is how often the relay looks for messages when it is not woken up by a notification.
+	outboxPollInterval = 5 * time.Second
+	// outboxBatchSize is the maximal number of messages claimed at once.
+	outboxBatchSize = 100
+	// outboxLease is how long a claimed batch is reserved for the relay that claimed it. It covers the delivery
+	// of a whole batch with the slowest webhook calls, so that a batch is claimed again only if its relay stopped.
+	outboxLease = outboxBatchSize*webhookTimeout + time.Minute
+	// outboxMinBackoff and outboxMaxBackoff bound the delay before a failed message is delivered again.
+	outboxMinBackoff = time.Second
+	outboxMaxBackoff = 5 * time.Minute
+	// outboxRetention is how long delivered messages are kept.
+	outboxRetention = 7 * 24 * time.Hour
+	// webhookTimeout is the maximal duration of a single webhook call.
+	webhookTimeout = 10 * time.Second
+)
+
+// eventSink delivers domain events to other services.
+// A message may be published more than once, so consumers have to deduplicate messages by their ID.
+type eventSink interface {
+	publish(ctx context.Context, message OutboxMessage) error
+}
+
+// newEventSink creates the sink of the given kind. url is used by the webhook sink only.
+func newEventSink(kind string, url string) (eventSink, error) {
+	switch strings.ToLower(kind) {
+	case outboxSinkLog:
+		return logSink{}, nil
+	case outboxSinkWebhook:
+		if url == "" {
+			return nil, fmt.Errorf("%s is required by the %s outbox sink", envOutboxWebhookURL, outboxSinkWebhook)
+		}
+		return webhookSink{url: url, client: &http.Client{Timeout: webhookTimeout}}, nil
+	default:
+		return nil, fmt.Errorf("unknown outbox sink %q, expected one of %s, %s", kind, outboxSinkLog, outboxSinkWebhook)
+	}
+}
+
+// logSink writes domain events to the log instead of delivering them.
+type logSink struct{}
+
+// publish writes the message to the log.
+func (logSink) publish(_ context.Context, message OutboxMessage) error {
+	zap.L().Info("Published appointment event",
+		zap.Int64("id", message.ID),
+		zap.String("topic", message.Topic),
+		zap.String("key", message.Key),
+		zap.ByteString("payload", message.Payload))
+	return nil
+}
+
+// webhookSink posts domain events to an HTTP endpoint.
+type webhookSink struct {
+	url    string
+	client *http.Client
+}
+
+// publish posts the payload of the message. The ID and the topic of the message are sent in the
+// X-Event-Id and X-Event-Topic headers. Any response status other than 2xx is an error.
+func (sink webhookSink) publish(ctx context.Context, message OutboxMessage) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, sink.url, bytes.NewReader(message.Payload))
+	if err != nil {
+		return fmt.Errorf("failed to create a webhook request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Event-Id", strconv.FormatInt(message.ID, 10))
+	request.Header.Set("X-Event-Topic", message.Topic)
+
+	response, err := sink.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("failed to call the webhook: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("webhook responded with status %s", response.Status)
+	}
+	return nil
+}
+
+// domainEvent is the payload of an outbox message. Appointment is encoded like GetAppointmentResponse
+// in the protobuf JSON mapping, without the status history.
+type domainEvent struct {
+	TenantID        string               `json:"tenant_id"`
+	Type            AppointmentEventType `json:"type"`
+	OccurredAt      time.Time            `json:"occurred_at"`
+	FormerDoctorID  int32                `json:"former_doctor_id,omitempty"`
+	FormerPatientID int32                `json:"former_patient_id,omitempty"`
+	Appointment     json.RawMessage      `json:"appointment"`
+}
+
+// addToOutbox writes the event to the outbox in the transaction that recorded it,
+// so that the event is published if and only if the change is committed.
+func addToOutbox(ctx context.Context, tx bun.Tx, event *AppointmentEvent) error {
+	appointment, err := protojson.Marshal(event.Appointment.toGRPC())
+	if err != nil {
+		return fmt.Errorf("failed to encode the appointment: %w", err)
+	}
+	payload, err := json.Marshal(domainEvent{
+		TenantID:        event.TenantID,
+		Type:            event.Type,
+		OccurredAt:      event.OccurredAt,
+		FormerDoctorID:  event.FormerDoctorID,
+		FormerPatientID: event.FormerPatientID,
+		Appointment:     appointment,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode the event: %w", err)
+	}
+
+	message := OutboxMessage{
+		TenantID: event.TenantID,
+		Topic:    "appointment." + string(event.Type),
+		Key:      strconv.Itoa(int(event.AppointmentID)),
+		Payload:  payload,
+	}
+	_, err = tx.NewInsert().Model(&message).Exec(ctx)
+	return err
+}
+
+// outboxBackoff returns the delay before a message that failed the given number of times is delivered again.
+func outboxBackoff(attempts int32) time.Duration {
+	backoff := outboxMinBackoff
+	for i := int32(1); i < attempts && backoff < outboxMaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, outboxMaxBackoff)
+}
+
+// claimOutbox leases a batch of pending messages to the caller by moving their next attempt past
+// outboxLease, and returns them in the order they were written. Messages are locked with SKIP LOCKED
+// only for the duration of the statement, so several replicas of the service can deliver in parallel
+// and no transaction is kept open while the messages are published.
+func claimOutbox(ctx context.Context, db bun.IDB) ([]OutboxMessage, error) {
+	pending := db.NewSelect().
+		Model((*OutboxMessage)(nil)).
+		Column("id").
+		Where("? IS NULL", bun.Ident("published_at")).
+		Where("? <= now()", bun.Ident("next_attempt_at")).
+		Order("id").
+		Limit(outboxBatchSize).
+		For("UPDATE SKIP LOCKED")
+
+	var messages []OutboxMessage
+	_, err := db.NewUpdate().
+		Model((*OutboxMessage)(nil)).
+		Set("? = ?", bun.Ident("next_attempt_at"), nowPlus(outboxLease)).
+		Where("? IN (?)", bun.Ident("id"), pending).
+		Returning("*").
+		Exec(ctx, &messages)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(messages, func(a, b OutboxMessage) int { return cmp.Compare(a.ID, b.ID) })
+	return messages, nil
+}
+
+// deliverOutbox publishes a batch of pending messages in the order they were written and marks them as published.
+// The batch is claimed by claimOutbox and published outside of any transaction, and each message is marked
+// in its own short transaction, so a slow sink doesn't hold locks or the transaction horizon of the database.
+// The messages belong to all tenants, so the transactions are system transactions, see runInSystemTx.
+// The first failure ends the batch: the failed message is retried after a growing backoff and the lease of the rest
+// of the batch is released. Returns the number of published messages.
+func deliverOutbox(ctx context.Context, db *bun.DB, sink eventSink) (int, error) {
+	var messages []OutboxMessage
+	err := runInSystemTx(ctx, db, func(ctx context.Context, tx bun.Tx) error {
+		var txErr error
+		messages, txErr = claimOutbox(ctx, tx)
+		return txErr
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range messages {
+		message := &messages[i]
+		if publishErr := sink.publish(ctx, *message); publishErr != nil {
+			zap.L().Warn("Failed to publish appointment event",
+				zap.Int64("id", message.ID), zap.Int32("attempts", message.Attempts+1), zap.Error(publishErr))
+			return i, runInSystemTx(ctx, db, func(ctx context.Context, tx bun.Tx) error {
+				return rescheduleOutbox(ctx, tx, message, publishErr, messages[i+1:])
+			})
+		}
+
+		err = runInSystemTx(ctx, db, func(ctx context.Context, tx bun.Tx) error {
+			_, txErr := tx.NewUpdate().
+				Model(message).
+				Set("? = now()", bun.Ident("published_at")).
+				WherePK().
+				Exec(ctx)
+			return txErr
+		})
+		if err != nil {
+			return i, err
+		}
+	}
+	return len(messages), nil
+}
+
+// rescheduleOutbox retries the failed message after a growing backoff and releases the lease of the rest
+// of its batch, so that they are delivered again right away.
+func rescheduleOutbox(ctx context.Context, db bun.IDB, failed *OutboxMessage, publishErr error,
+	rest []OutboxMessage) error {
+	failed.Attempts++
+	_, err := db.NewUpdate().
+		Model(failed).
+		Set("? = ?", bun.Ident("attempts"), failed.Attempts).
+		Set("? = ?", bun.Ident("last_error"), publishErr.Error()).
+		Set("? = ?", bun.Ident("next_attempt_at"), nowPlus(outboxBackoff(failed.Attempts))).
+		WherePK().
+		Exec(ctx)
+	if err != nil || len(rest) == 0 {
+		return err
+	}
+
+	ids := make([]int64, len(rest))
+	for i, message := range rest {
+		ids[i] = message.ID
+	}
+	_, err = db.NewUpdate().
+		Model((*OutboxMessage)(nil)).
+		Set("? = now()", bun.Ident("next_attempt_at")).
+		Where("? IN (?)", bun.Ident("id"), bun.In(ids)).
+		Where("? IS NULL", bun.Ident("published_at")).
+		Exec(ctx)
+	return err
+}
+
+// runOutboxRelay delivers the outbox to the sink until the context is done. It is woken up by the event hub
+// after every change and also polls the outbox, so that failed messages are retried.
+// Delivered messages older than outboxRetention are deleted.
+func runOutboxRelay(ctx context.Context, db *bun.DB, sink eventSink, hub *eventHub) {
+	wake, unsubscribe := hub.subscribe()
+	defer unsubscribe()
+	poll := time.NewTicker(outboxPollInterval)
+	defer poll.Stop()
+	prune := time.NewTicker(eventPruneInterval)
+	defer prune.Stop()
+
+	for {
+		// A full batch means that more messages may be waiting.
+		published, err := deliverOutbox(ctx, db, sink)
+		if err != nil {
+			zap.L().Error("Failed to deliver the outbox", zap.Error(err))
+		}
+		if published == outboxBatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-wake:
+		case <-poll.C:
+		case <-prune.C:
+			err = runInSystemTx(ctx, db, func(ctx context.Context, tx bun.Tx) error {
+				_, txErr := tx.NewDelete().
+					Model((*OutboxMessage)(nil)).
+					Where("? < ?", bun.Ident("published_at"), time.Now().Add(-outboxRetention)).
+					Exec(ctx)
+				return txErr
+			})
+			if err != nil {
+				zap.L().Error("Failed to prune the outbox", zap.Error(err))
+			}
+		}
+	}
+}
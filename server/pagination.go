@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/uptrace/bun"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// pageToken is the position of a page in a sorted list of appointments.
+// It is passed to clients as an opaque base64-encoded string.
+type pageToken struct {
+	// Filters is a fingerprint of the filters and the sort order of the request that issued the token.
+	Filters string `json:"f"`
+	// Value is the value of the sort column of the last appointment of the previous page.
+	Value time.Time `json:"v"`
+	// ID is the ID of the last appointment of the previous page.
+	ID int32 `json:"i"`
+}
+
+// filtersFingerprint returns a short hash of the filters and the sort order of the request,
+// so that a page token can't be used with a different query.
+func filtersFingerprint(req appointmentsRequest) (string, error) {
+	clone := proto.Clone(req)
+	reflected := clone.ProtoReflect()
+	fields := reflected.Descriptor().Fields()
+	for _, name := range []protoreflect.Name{"token", "skip", "limit", "page_token", "skip_count", "read_mask"} {
+		if field := fields.ByName(name); field != nil {
+			reflected.Clear(field)
+		}
+	}
+
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(clone)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal filters: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:8]), nil
+}
+
+// decodePageToken parses the page token of the request.
+// Returns codes.InvalidArgument if the token is malformed or was issued for other filters.
+func decodePageToken(req appointmentsRequest) (pageToken, error) {
+	var token pageToken
+	data, err := base64.RawURLEncoding.DecodeString(req.GetPageToken())
+	if err != nil || json.Unmarshal(data, &token) != nil {
+		return pageToken{}, invalidArgumentError("page_token", "page token is malformed")
+	}
+
+	fingerprint, err := filtersFingerprint(req)
+	if err != nil {
+		return pageToken{}, status.Error(codes.Internal, err.Error())
+	}
+	if token.Filters != fingerprint {
+		return pageToken{}, invalidArgumentError("page_token",
+			"page token was issued for other filters or sort order")
+	}
+	return token, nil
+}
+
+// paginate restricts the sorted query of appointments to the requested page.
+// With a page token, the page starts right after the appointment the token points to. Otherwise, skip appointments
+// are skipped. One appointment more than the limit is fetched, so that nextPage can tell whether there is a next page.
+// Returns codes.InvalidArgument if the page token is invalid or combined with skip.
+func paginate(query *bun.SelectQuery, req appointmentsRequest) (*bun.SelectQuery, error) {
+	query = query.Limit(int(req.GetLimit()) + 1)
+	if req.GetPageToken() == "" {
+		return query.Offset(int(req.GetSkip())), nil
+	}
+	if req.GetSkip() != 0 {
+		return nil, invalidArgumentError("skip", "skip can't be combined with page_token")
+	}
+
+	token, err := decodePageToken(req)
+	if err != nil {
+		return nil, err
+	}
+	column, descending, err := sortColumn(req.GetSortOrder())
+	if err != nil {
+		return nil, err
+	}
+	comparison := ">"
+	if descending {
+		comparison = "<"
+	}
+	return query.Where("(?, ?) "+comparison+" (?, ?)", bun.Ident(column), bun.Ident("id"), token.Value, token.ID), nil
+}
+
+// nextPage drops the extra appointment fetched by paginate and returns the token of the next page.
+// The token is empty if there are no more appointments.
+func nextPage(appointments []Appointment, req appointmentsRequest) ([]Appointment, string, error) {
+	if len(appointments) <= int(req.GetLimit()) {
+		return appointments, "", nil
+	}
+	appointments = appointments[:req.GetLimit()]
+
+	fingerprint, err := filtersFingerprint(req)
+	if err != nil {
+		return nil, "", status.Error(codes.Internal, err.Error())
+	}
+	column, _, err := sortColumn(req.GetSortOrder())
+	if err != nil {
+		return nil, "", err
+	}
+	last := appointments[len(appointments)-1]
+	token := pageToken{Filters: fingerprint, Value: last.StartTime, ID: last.ID}
+	if column == "created_at" {
+		token.Value = last.CreatedAt
+	}
+
+	data, err := json.Marshal(token)
+	if err != nil {
+		return nil, "", status.Error(codes.Internal, fmt.Errorf("failed to encode page token: %w", err).Error())
+	}
+	return appointments, base64.RawURLEncoding.EncodeToString(data), nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	ppb "github.com/TekClinic/Appointments-MicroService/appointments_protobuf"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestFiltersFingerprint(t *testing.T) {
+	base := &ppb.GetAppointmentsRequest{DoctorId: 1, Date: "2030-01-07"}
+	fingerprint, err := filtersFingerprint(base)
+	if err != nil {
+		t.Fatalf("filtersFingerprint() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		req  *ppb.GetAppointmentsRequest
+		same bool
+	}{
+		{"identical", &ppb.GetAppointmentsRequest{DoctorId: 1, Date: "2030-01-07"}, true},
+		{"token", &ppb.GetAppointmentsRequest{DoctorId: 1, Date: "2030-01-07", Token: "other"}, true},
+		{"skip and limit", &ppb.GetAppointmentsRequest{DoctorId: 1, Date: "2030-01-07", Skip: 10, Limit: 5}, true},
+		{"page token", &ppb.GetAppointmentsRequest{DoctorId: 1, Date: "2030-01-07", PageToken: "token"}, true},
+		{"skip count", &ppb.GetAppointmentsRequest{DoctorId: 1, Date: "2030-01-07", SkipCount: true}, true},
+		{"other doctor", &ppb.GetAppointmentsRequest{DoctorId: 2, Date: "2030-01-07"}, false},
+		{"other date", &ppb.GetAppointmentsRequest{DoctorId: 1, Date: "2030-01-08"}, false},
+		{"statuses", &ppb.GetAppointmentsRequest{DoctorId: 1, Date: "2030-01-07",
+			Statuses: []ppb.AppointmentStatus{ppb.AppointmentStatus_APPOINTMENT_STATUS_CONFIRMED}}, false},
+		{"sort order", &ppb.GetAppointmentsRequest{DoctorId: 1, Date: "2030-01-07",
+			SortOrder: ppb.AppointmentSortOrder_APPOINTMENT_SORT_ORDER_START_TIME_DESC}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := filtersFingerprint(test.req)
+			if err != nil {
+				t.Fatalf("filtersFingerprint() error = %v", err)
+			}
+			if (got == fingerprint) != test.same {
+				t.Errorf("filtersFingerprint() = %q, base fingerprint %q, want same %v", got, fingerprint, test.same)
+			}
+		})
+	}
+}
+
+func TestPageToken(t *testing.T) {
+	start := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	created := time.Date(2029, 12, 1, 12, 0, 0, 0, time.UTC)
+	appointments := []Appointment{
+		{ID: 1, StartTime: start, CreatedAt: created},
+		{ID: 2, StartTime: start.Add(time.Hour), CreatedAt: created.Add(time.Minute)},
+		{ID: 3, StartTime: start.Add(2 * time.Hour), CreatedAt: created.Add(2 * time.Minute)},
+	}
+
+	tests := []struct {
+		name  string
+		order ppb.AppointmentSortOrder
+		value time.Time
+	}{
+		{"start time", ppb.AppointmentSortOrder_APPOINTMENT_SORT_ORDER_START_TIME_ASC, start.Add(time.Hour)},
+		{"created at", ppb.AppointmentSortOrder_APPOINTMENT_SORT_ORDER_CREATED_AT_DESC, created.Add(time.Minute)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := &ppb.GetAppointmentsRequest{DoctorId: 1, Limit: 2, SortOrder: test.order}
+			page, next, err := nextPage(appointments, req)
+			if err != nil {
+				t.Fatalf("nextPage() error = %v", err)
+			}
+			if len(page) != 2 || next == "" {
+				t.Fatalf("nextPage() = %d appointments and token %q, want 2 and a token", len(page), next)
+			}
+
+			req.PageToken = next
+			token, err := decodePageToken(req)
+			if err != nil {
+				t.Fatalf("decodePageToken() error = %v", err)
+			}
+			if token.ID != 2 || !token.Value.Equal(test.value) {
+				t.Errorf("decodePageToken() = %d at %v, want 2 at %v", token.ID, token.Value, test.value)
+			}
+		})
+	}
+
+	t.Run("last page", func(t *testing.T) {
+		page, next, err := nextPage(appointments, &ppb.GetAppointmentsRequest{Limit: 3})
+		if err != nil || len(page) != 3 || next != "" {
+			t.Errorf("nextPage() = %d appointments, token %q, error %v, want 3 and no token", len(page), next, err)
+		}
+	})
+}
+
+func TestDecodePageTokenErrors(t *testing.T) {
+	_, issued, err := nextPage(make([]Appointment, 2), &ppb.GetAppointmentsRequest{DoctorId: 1, Limit: 1})
+	if err != nil {
+		t.Fatalf("nextPage() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		req   *ppb.GetAppointmentsRequest
+	}{
+		{"not base64", "!!!", &ppb.GetAppointmentsRequest{DoctorId: 1}},
+		{"not json", base64.RawURLEncoding.EncodeToString([]byte("page")), &ppb.GetAppointmentsRequest{DoctorId: 1}},
+		{"other filters", issued, &ppb.GetAppointmentsRequest{DoctorId: 2}},
+		{"other sort order", issued, &ppb.GetAppointmentsRequest{DoctorId: 1,
+			SortOrder: ppb.AppointmentSortOrder_APPOINTMENT_SORT_ORDER_CREATED_AT_ASC}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.req.PageToken = test.token
+			if _, err := decodePageToken(test.req); status.Code(err) != codes.InvalidArgument {
+				t.Errorf("decodePageToken() error = %v, want %v", err, codes.InvalidArgument)
+			}
+		})
+	}
+}
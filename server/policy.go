@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// role is a role of a token that is known to the access policy.
+type role string
+
+const (
+	roleAdmin        role = "admin"
+	roleReceptionist role = "receptionist"
+	roleDoctor       role = "doctor"
+	rolePatient      role = "patient"
+)
+
+// permission is an operation that is guarded by the access policy.
+type permission string
+
+const (
+	// permReadAppointments allows reading appointments and their history.
+	permReadAppointments permission = "read_appointments"
+	// permWriteAppointments allows creating appointments, changing their time, doctor and patient.
+	permWriteAppointments permission = "write_appointments"
+	// permDeleteAppointments allows deleting appointments and restoring deleted or cancelled ones.
+	permDeleteAppointments permission = "delete_appointments"
+	// permCancelAppointments allows cancelling appointments.
+	permCancelAppointments permission = "cancel_appointments"
+	// permConfirmAppointments allows confirming appointments on behalf of the patient.
+	permConfirmAppointments permission = "confirm_appointments"
+	// permCheckIn allows checking patients in and marking no-shows.
+	permCheckIn permission = "check_in"
+	// permConductVisits allows starting and completing visits.
+	permConductVisits permission = "conduct_visits"
+	// permReadSchedules allows reading doctor schedules.
+	permReadSchedules permission = "read_schedules"
+	// permWriteSchedules allows creating, updating and deleting doctor schedules.
+	permWriteSchedules permission = "write_schedules"
+	// permFindSlots allows searching for free slots.
+	permFindSlots permission = "find_slots"
+	// permReadAudit allows reading the audit trail of all entities.
+	permReadAudit permission = "read_audit"
+	// permReadAppointmentTypes allows reading the catalogue of appointment types.
+	permReadAppointmentTypes permission = "read_appointment_types"
+	// permWriteAppointmentTypes allows creating, updating and deleting appointment types.
+	permWriteAppointmentTypes permission = "write_appointment_types"
+	// permReadResources allows reading rooms and equipment.
+	permReadResources permission = "read_resources"
+	// permWriteResources allows creating, updating and deleting rooms and equipment.
+	permWriteResources permission = "write_resources"
+	// permReadLocations allows reading the branches of the clinic.
+	permReadLocations permission = "read_locations"
+	// permWriteLocations allows creating, updating and deleting the branches of the clinic.
+	permWriteLocations permission = "write_locations"
+	// permReadWaitlist allows reading the waitlist.
+	permReadWaitlist permission = "read_waitlist"
+	// permWriteWaitlist allows adding patients to the waitlist and removing them.
+	permWriteWaitlist permission = "write_waitlist"
+	// permAnswerWaitlistOffers allows accepting and declining the slots offered to patients on the waitlist.
+	permAnswerWaitlistOffers permission = "answer_waitlist_offers"
+	// permHoldSlots allows holding slots and booking patients for the held slots.
+	permHoldSlots permission = "hold_slots"
+	// permOverrideSchedule allows booking and restoring appointments outside of the doctor's working hours
+	// with ignore_schedule.
+	permOverrideSchedule permission = "override_schedule"
+)
+
+// statusPermission returns the permission required to move an appointment to the status.
+func statusPermission(next AppointmentStatus) permission {
+	switch next {
+	case StatusConfirmed:
+		return permConfirmAppointments
+	case StatusCheckedIn, StatusNoShow:
+		return permCheckIn
+	case StatusInProgress, StatusCompleted:
+		return permConductVisits
+	case StatusCancelled:
+		return permCancelAppointments
+	case StatusScheduled:
+		// Appointments return to scheduled only when they are restored.
+		return permDeleteAppointments
+	default:
+		return permDeleteAppointments
+	}
+}
+
+// grant gives a role a permission. If own is set, the permission is limited to the records of the token's user:
+// appointments and schedules of the doctor for doctors, appointments of the patient for patients.
+type grant struct {
+	role       role
+	permission permission
+	own        bool
+}
+
+// accessPolicy declares who can do what. Every handler checks its permission against this list via authorize,
+// so it is the only place where access rules are defined.
+func accessPolicy() []grant {
+	return []grant{
+		{role: roleAdmin, permission: permReadAppointments},
+		{role: roleAdmin, permission: permWriteAppointments},
+		{role: roleAdmin, permission: permDeleteAppointments},
+		{role: roleAdmin, permission: permCancelAppointments},
+		{role: roleAdmin, permission: permConfirmAppointments},
+		{role: roleAdmin, permission: permCheckIn},
+		{role: roleAdmin, permission: permConductVisits},
+		{role: roleAdmin, permission: permReadSchedules},
+		{role: roleAdmin, permission: permWriteSchedules},
+		{role: roleAdmin, permission: permFindSlots},
+		{role: roleAdmin, permission: permReadAudit},
+		{role: roleAdmin, permission: permReadAppointmentTypes},
+		{role: roleAdmin, permission: permWriteAppointmentTypes},
+		{role: roleAdmin, permission: permReadResources},
+		{role: roleAdmin, permission: permWriteResources},
+		{role: roleAdmin, permission: permReadLocations},
+		{role: roleAdmin, permission: permWriteLocations},
+		{role: roleAdmin, permission: permReadWaitlist},
+		{role: roleAdmin, permission: permWriteWaitlist},
+		{role: roleAdmin, permission: permAnswerWaitlistOffers},
+		{role: roleAdmin, permission: permHoldSlots},
+		{role: roleAdmin, permission: permOverrideSchedule},
+
+		{role: roleReceptionist, permission: permReadAppointments},
+		{role: roleReceptionist, permission: permWriteAppointments},
+		{role: roleReceptionist, permission: permCancelAppointments},
+		{role: roleReceptionist, permission: permConfirmAppointments},
+		{role: roleReceptionist, permission: permCheckIn},
+		{role: roleReceptionist, permission: permReadSchedules},
+		{role: roleReceptionist, permission: permFindSlots},
+		{role: roleReceptionist, permission: permReadAppointmentTypes},
+		{role: roleReceptionist, permission: permReadResources},
+		{role: roleReceptionist, permission: permReadLocations},
+		{role: roleReceptionist, permission: permReadWaitlist},
+		{role: roleReceptionist, permission: permWriteWaitlist},
+		{role: roleReceptionist, permission: permAnswerWaitlistOffers},
+		{role: roleReceptionist, permission: permHoldSlots},
+
+		{role: roleDoctor, permission: permReadAppointments, own: true},
+		{role: roleDoctor, permission: permCheckIn, own: true},
+		{role: roleDoctor, permission: permConductVisits, own: true},
+		{role: roleDoctor, permission: permReadSchedules, own: true},
+		{role: roleDoctor, permission: permFindSlots},
+		{role: roleDoctor, permission: permReadAppointmentTypes},
+		{role: roleDoctor, permission: permReadResources},
+		{role: roleDoctor, permission: permReadLocations},
+
+		{role: rolePatient, permission: permReadAppointments, own: true},
+		{role: rolePatient, permission: permCancelAppointments, own: true},
+		{role: rolePatient, permission: permConfirmAppointments, own: true},
+		{role: rolePatient, permission: permReadSchedules},
+		{role: rolePatient, permission: permFindSlots},
+		{role: rolePatient, permission: permReadAppointmentTypes},
+		{role: rolePatient, permission: permReadLocations},
+		{role: rolePatient, permission: permReadWaitlist, own: true},
+		{role: rolePatient, permission: permWriteWaitlist, own: true},
+		{role: rolePatient, permission: permAnswerWaitlistOffers, own: true},
+		{role: rolePatient, permission: permHoldSlots, own: true},
+	}
+}
+
+// access describes the records a caller may work with for a permission.
+// Only records of tenantID are accessible. If all is not set, only appointments of doctorID or patientID
+// are accessible, whichever is not zero.
+type access struct {
+	tenantID  string
+	all       bool
+	doctorID  int32
+	patientID int32
+}
+
+// allows reports whether the appointment of the caller's tenant is accessible.
+func (a access) allows(appointment *Appointment) bool {
+	return a.all ||
+		(a.doctorID != 0 && appointment.DoctorID == a.doctorID) ||
+		(a.patientID != 0 && appointment.PatientID == a.patientID)
+}
+
+// check returns codes.NotFound if the appointment belongs to another tenant, so that the records
+// of other tenants can't be discovered, and codes.PermissionDenied if the appointment is not accessible.
+func (a access) check(appointment *Appointment) error {
+	if appointment.TenantID != a.tenantID {
+		return notFoundError("the appointment doesn't exist")
+	}
+	if !a.allows(appointment) {
+		return status.Error(codes.PermissionDenied, permissionDeniedMessage)
+	}
+	return nil
+}
+
+// checkDoctor returns codes.PermissionDenied if the records of the doctor, such as their schedule,
+// are not accessible.
+func (a access) checkDoctor(doctorID int32) error {
+	if !a.all && (a.doctorID == 0 || doctorID != a.doctorID) {
+		return status.Error(codes.PermissionDenied, permissionDeniedMessage)
+	}
+	return nil
+}
+
+// checkPatient returns codes.PermissionDenied if the records of the patient, such as their waitlist entries,
+// are not accessible.
+func (a access) checkPatient(patientID int32) error {
+	if !a.all && (a.patientID == 0 || patientID != a.patientID) {
+		return status.Error(codes.PermissionDenied, permissionDeniedMessage)
+	}
+	return nil
+}
+
+// scope restricts the query of appointments to the accessible ones.
+func (a access) scope(query *bun.SelectQuery) *bun.SelectQuery {
+	query = whereTenant(query, a.tenantID)
+	if a.all {
+		return query
+	}
+	return query.WhereGroup(" AND ", func(query *bun.SelectQuery) *bun.SelectQuery {
+		if a.doctorID != 0 {
+			query = query.WhereOr("? = ?", bun.Ident("doctor_id"), a.doctorID)
+		}
+		if a.patientID != 0 {
+			query = query.WhereOr("? = ?", bun.Ident("patient_id"), a.patientID)
+		}
+		return query
+	})
+}
+
+// authorizeScheduleOverride returns codes.PermissionDenied if ignoreSchedule is set and none of the roles
+// of the token grants the override_schedule permission.
+func (server appointmentsServer) authorizeScheduleOverride(ctx context.Context, token string,
+	ignoreSchedule bool) error {
+	if !ignoreSchedule {
+		return nil
+	}
+	_, err := server.authorize(ctx, token, permOverrideSchedule)
+	return err
+}
+
+// authorize verifies the token and returns the records the caller may work with for the permission.
+// The caller is limited to the tenant of the token, see tokenIdentity.tenant.
+// Returns codes.Unauthenticated if the token is not valid or has no tenant and codes.PermissionDenied if none
+// of the roles of the token grants the permission. A role limited to own records grants nothing
+// if the token doesn't link the user to a doctor or a patient.
+func (server appointmentsServer) authorize(ctx context.Context, token string, perm permission) (access, error) {
+	claims, err := server.VerifyToken(ctx, token)
+	if err != nil {
+		return access{}, status.Error(codes.Unauthenticated, err.Error())
+	}
+	identity, err := parseTokenIdentity(token)
+	if err != nil {
+		return access{}, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	tenantID, err := identity.tenant()
+	if err != nil {
+		return access{}, err
+	}
+
+	result := access{tenantID: tenantID}
+	for _, rule := range accessPolicy() {
+		if rule.permission != perm || !claims.HasRole(string(rule.role)) {
+			continue
+		}
+		if !rule.own {
+			return access{tenantID: result.tenantID, all: true}, nil
+		}
+		switch rule.role {
+		case roleDoctor:
+			result.doctorID = identity.DoctorID
+		case rolePatient:
+			result.patientID = identity.PatientID
+		case roleAdmin, roleReceptionist:
+			// These roles are never limited to own records.
+		}
+	}
+	if result.doctorID == 0 && result.patientID == 0 {
+		return access{}, status.Error(codes.PermissionDenied, permissionDeniedMessage)
+	}
+	return result, nil
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	ppb "github.com/TekClinic/Appointments-MicroService/appointments_protobuf"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+)
+
+const (
+	// resourceNameConstraint is the name of the unique index on the names of resources.
+	resourceNameConstraint = "resources_name_key"
+
+	maxResourceNameLength   = 100
+	maxAppointmentResources = 10
+	resourceNotFoundMessage = "one of the resources doesn't exist"
+)
+
+// Kinds of resources.
+const (
+	resourceKindRoom      = ResourceKind("room")
+	resourceKindEquipment = ResourceKind("equipment")
+)
+
+// ResourceKind is a kind of a resource that is booked together with a doctor.
+type ResourceKind string
+
+// resourceKindFromGRPC converts a GRPC resource kind to ResourceKind.
+// Returns false if the kind is unknown or unspecified.
+func resourceKindFromGRPC(kind ppb.ResourceKind) (ResourceKind, bool) {
+	switch kind {
+	case ppb.ResourceKind_RESOURCE_KIND_ROOM:
+		return resourceKindRoom, true
+	case ppb.ResourceKind_RESOURCE_KIND_EQUIPMENT:
+		return resourceKindEquipment, true
+	case ppb.ResourceKind_RESOURCE_KIND_UNSPECIFIED:
+		return "", false
+	default:
+		return "", false
+	}
+}
+
+// toGRPC returns a GRPC version of ResourceKind.
+func (kind ResourceKind) toGRPC() ppb.ResourceKind {
+	switch kind {
+	case resourceKindRoom:
+		return ppb.ResourceKind_RESOURCE_KIND_ROOM
+	case resourceKindEquipment:
+		return ppb.ResourceKind_RESOURCE_KIND_EQUIPMENT
+	default:
+		return ppb.ResourceKind_RESOURCE_KIND_UNSPECIFIED
+	}
+}
+
+// toGRPC returns a GRPC version of Resource.
+func (resource Resource) toGRPC() *ppb.Resource {
+	return &ppb.Resource{
+		Id:      resource.ID,
+		Name:    resource.Name,
+		Kind:    resource.Kind.toGRPC(),
+		Deleted: !resource.DeletedAt.IsZero(),
+	}
+}
+
+// resourceRequest is implemented by requests that define a resource.
+type resourceRequest interface {
+	GetName() string
+	GetKind() ppb.ResourceKind
+}
+
+// resourceFromGRPC validates the resource definition of the request and converts it to Resource.
+// Returns codes.InvalidArgument if the definition is invalid.
+func resourceFromGRPC(req resourceRequest) (*Resource, error) {
+	name := strings.TrimSpace(req.GetName())
+	if name == "" {
+		return nil, invalidArgumentError("name", "name is required")
+	}
+	if len(name) > maxResourceNameLength {
+		return nil, invalidArgumentError("name",
+			fmt.Sprintf("name has to be at most %d characters long", maxResourceNameLength))
+	}
+	kind, ok := resourceKindFromGRPC(req.GetKind())
+	if !ok {
+		return nil, invalidArgumentError("kind", "kind is required")
+	}
+	return &Resource{Name: name, Kind: kind}, nil
+}
+
+// parseResourceIDs sorts and deduplicates the resource IDs of a request.
+// Returns codes.InvalidArgument if one of them is not positive or there are too many of them.
+func parseResourceIDs(ids []int32) ([]int32, error) {
+	ids = slices.Clone(ids)
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+	if len(ids) > maxAppointmentResources {
+		return nil, invalidArgumentError("resource_ids",
+			fmt.Sprintf("at most %d resources can be booked at once", maxAppointmentResources))
+	}
+	if len(ids) > 0 && ids[0] <= 0 {
+		return nil, invalidArgumentError("resource_ids", "ResourceIDs have to be positive values")
+	}
+	return ids, nil
+}
+
+// fetchBookableResources validates the resource IDs of a request and checks that all of them exist
+// in the tenant and are not deleted. Returns the sorted and deduplicated IDs.
+// Returns codes.InvalidArgument if the IDs are invalid and codes.NotFound if one of the resources doesn't exist.
+func fetchBookableResources(ctx context.Context, db bun.IDB, tenantID string, ids []int32) ([]int32, error) {
+	ids, err := parseResourceIDs(ids)
+	if err != nil || len(ids) == 0 {
+		return ids, err
+	}
+	query := db.NewSelect().Model((*Resource)(nil)).Where("? IN (?)", bun.Ident("id"), bun.In(ids))
+	count, err := whereTenant(query, tenantID).Count(ctx)
+	if err != nil {
+		return nil, dbError(err, "failed to fetch resources")
+	}
+	if count != len(ids) {
+		return nil, notFoundError(resourceNotFoundMessage)
+	}
+	return ids, nil
+}
+
+// checkResourceOverlap returns codes.AlreadyExists if one of the resources of the appointment is used by
+// an overlapping appointment. The resources are locked until the end of the transaction first,
+// so that concurrent bookings of the same resource are checked one after another.
+func checkResourceOverlap(ctx context.Context, db bun.IDB, appointment *Appointment) error {
+	if len(appointment.ResourceIDs) == 0 {
+		return nil
+	}
+
+	var locked []int32
+	err := whereTenant(db.NewSelect().Model((*Resource)(nil)), appointment.TenantID).
+		Column("id").
+		Where("? IN (?)", bun.Ident("id"), bun.In(appointment.ResourceIDs)).
+		WhereAllWithDeleted().
+		Order("id").
+		For("UPDATE").
+		Scan(ctx, &locked)
+	if err != nil {
+		return dbError(err, "failed to lock resources")
+	}
+	if len(locked) != len(appointment.ResourceIDs) {
+		return notFoundError(resourceNotFoundMessage)
+	}
+
+	var ids []int32
+	query := db.NewSelect().
+		Model((*Appointment)(nil)).
+		Column("id").
+		Where("? && ?", bun.Ident("resource_ids"), pgdialect.Array(appointment.ResourceIDs)).
+		Where("? != ?", bun.Ident("id"), appointment.ID).
+		Order("start_time")
+	query = whereTenant(query, appointment.TenantID)
+	if err = whereActive(whereOverlaps(query, appointment.timeRange())).Scan(ctx, &ids); err != nil {
+		return dbError(err, "failed to check resource availability")
+	}
+	if len(ids) > 0 {
+		return conflictError(reasonResourceConflict,
+			"the resources are already booked for overlapping appointments: "+formatIDs(ids), ids)
+	}
+	return nil
+}
+
+// fetchResource returns the resource of the tenant with the given ID that is not deleted.
+// Returns codes.NotFound if there is no such resource.
+func fetchResource(ctx context.Context, db bun.IDB, tenantID string, id int32) (*Resource, error) {
+	resource := new(Resource)
+	query := db.NewSelect().Model(resource).Where("? = ?", bun.Ident("id"), id)
+	if err := whereTenant(query, tenantID).Scan(ctx); err != nil {
+		return nil, dbError(err, "failed to fetch a resource by id")
+	}
+	return resource, nil
+}
+
+// GetResource returns the resource with the given ID, including deleted resources.
+// Requires authentication. If authentication is not valid, codes.Unauthenticated is returned.
+// Requires the read_resources permission, see accessPolicy. If roles are not sufficient,
+// codes.PermissionDenied is returned.
+// If the resource with the given ID doesn't exist, codes.NotFound is returned.
+func (server appointmentsServer) GetResource(ctx context.Context,
+	req *ppb.GetResourceRequest) (*ppb.GetResourceResponse, error) {
+	caller, err := server.authorize(ctx, req.GetToken(), permReadResources)
+	if err != nil {
+		return nil, err
+	}
+
+	resource := new(Resource)
+	err = runInTenantTx(ctx, server.db, caller.tenantID, func(ctx context.Context, tx bun.Tx) error {
+		return whereTenant(tx.NewSelect().Model(resource), caller.tenantID).
+			Where("? = ?", bun.Ident("id"), req.GetId()).
+			WhereAllWithDeleted().
+			Scan(ctx)
+	})
+	if err != nil {
+		return nil, dbError(err, "failed to fetch a resource by id")
+	}
+
+	return &ppb.GetResourceResponse{Resource: resource.toGRPC()}, nil
+}
+
+// ListResources returns the resources sorted by name, optionally only those of the given kind.
+// Requires authentication. If authentication is not valid, codes.Unauthenticated is returned.
+// Requires the read_resources permission, see accessPolicy. If roles are not sufficient,
+// codes.PermissionDenied is returned.
+// If skip or limit are invalid, codes.InvalidArgument is returned.
+func (server appointmentsServer) ListResources(ctx context.Context,
+	req *ppb.ListResourcesRequest) (*ppb.ListResourcesResponse, error) {
+	caller, err := server.authorize(ctx, req.GetToken(), permReadResources)
+	if err != nil {
+		return nil, err
+	}
+	if err = validatePagination(req.GetSkip(), req.GetLimit()); err != nil {
+		return nil, err
+	}
+
+	var resources []Resource
+	query := whereTenant(server.db.NewSelect().Model(&resources), caller.tenantID)
+	if req.GetKind() != ppb.ResourceKind_RESOURCE_KIND_UNSPECIFIED {
+		kind, ok := resourceKindFromGRPC(req.GetKind())
+		if !ok {
+			return nil, invalidArgumentError("kind", "unknown resource kind")
+		}
+		query = query.Where("? = ?", bun.Ident("kind"), kind)
+	}
+	if req.GetIncludeDeleted() {
+		query = query.WhereAllWithDeleted()
+	}
+	var count int
+	err = runInTenantTx(ctx, server.db, caller.tenantID, func(ctx context.Context, tx bun.Tx) error {
+		var txErr error
+		count, txErr = query.Conn(tx).
+			OrderExpr("lower(?), ?", bun.Ident("name"), bun.Ident("id")).
+			Offset(int(req.GetSkip())).
+			Limit(int(req.GetLimit())).
+			ScanAndCount(ctx)
+		return txErr
+	})
+	if err != nil {
+		return nil, dbError(err, "failed to fetch resources")
+	}
+
+	results := make([]*ppb.Resource, len(resources))
+	for i, resource := range resources {
+		results[i] = resource.toGRPC()
+	}
+	return &ppb.ListResourcesResponse{Count: int32(count), Results: results}, nil
+}
+
+// CreateResource adds a room or a piece of equipment that can be booked with appointments.
+// Requires authentication. If authentication is not valid, codes.Unauthenticated is returned.
+// Requires the write_resources permission, see accessPolicy. If roles are not sufficient,
+// codes.PermissionDenied is returned.
+// If the resource definition is invalid, codes.InvalidArgument is returned.
+// If another resource has the same name, codes.AlreadyExists is returned.
+func (server appointmentsServer) CreateResource(ctx context.Context,
+	req *ppb.CreateResourceRequest) (*ppb.CreateResourceResponse, error) {
+	caller, err := server.authorize(ctx, req.GetToken(), permWriteResources)
+	if err != nil {
+		return nil, err
+	}
+	resource, err := resourceFromGRPC(req)
+	if err != nil {
+		return nil, err
+	}
+	resource.TenantID = caller.tenantID
+
+	err = runInTenantTx(ctx, server.db, caller.tenantID, func(ctx context.Context, tx bun.Tx) error {
+		if _, txErr := tx.NewInsert().Model(resource).Exec(ctx); txErr != nil {
+			return txErr
+		}
+		return recordAudit(ctx, tx, caller.tenantID, auditEntityResource, resource.ID, tokenActor(req.GetToken()),
+			nil, resource.toGRPC())
+	})
+	if err != nil {
+		return nil, dbError(err, "failed to create a resource")
+	}
+
+	return &ppb.CreateResourceResponse{Id: resource.ID}, nil
+}
+
+// UpdateResource renames a resource or changes its kind.
+// Requires authentication. If authentication is not valid, codes.Unauthenticated is returned.
+// Requires the write_resources permission, see accessPolicy. If roles are not sufficient,
+// codes.PermissionDenied is returned.
+// If the resource definition is invalid, codes.InvalidArgument is returned.
+// If the resource with the given ID doesn't exist, codes.NotFound is returned.
+// If another resource has the same name, codes.AlreadyExists is returned.
+func (server appointmentsServer) UpdateResource(ctx context.Context,
+	req *ppb.UpdateResourceRequest) (*ppb.UpdateResourceResponse, error) {
+	caller, err := server.authorize(ctx, req.GetToken(), permWriteResources)
+	if err != nil {
+		return nil, err
+	}
+	resource, err := resourceFromGRPC(req)
+	if err != nil {
+		return nil, err
+	}
+	err = runInTenantTx(ctx, server.db, caller.tenantID, func(ctx context.Context, tx bun.Tx) error {
+		existing, txErr := fetchResource(ctx, tx, caller.tenantID, req.GetId())
+		if txErr != nil {
+			return txErr
+		}
+		resource.ID = existing.ID
+
+		if _, txErr = tx.NewUpdate().Model(resource).Column("name", "kind").WherePK().Exec(ctx); txErr != nil {
+			return txErr
+		}
+		return recordAudit(ctx, tx, caller.tenantID, auditEntityResource, resource.ID, tokenActor(req.GetToken()),
+			existing.toGRPC(), resource.toGRPC())
+	})
+	if err != nil {
+		return nil, dbError(err, "failed to update a resource")
+	}
+
+	return &ppb.UpdateResourceResponse{Id: resource.ID}, nil
+}
+
+// DeleteResource removes a resource, after which it can't be booked.
+// Existing appointments keep the resource and are still checked against it.
+// Requires authentication. If authentication is not valid, codes.Unauthenticated is returned.
+// Requires the write_resources permission, see accessPolicy. If roles are not sufficient,
+// codes.PermissionDenied is returned.
+// If the resource with the given ID doesn't exist, codes.NotFound is returned.
+func (server appointmentsServer) DeleteResource(ctx context.Context,
+	req *ppb.DeleteResourceRequest) (*ppb.DeleteResourceResponse, error) {
+	caller, err := server.authorize(ctx, req.GetToken(), permWriteResources)
+	if err != nil {
+		return nil, err
+	}
+	err = runInTenantTx(ctx, server.db, caller.tenantID, func(ctx context.Context, tx bun.Tx) error {
+		resource, txErr := fetchResource(ctx, tx, caller.tenantID, req.GetId())
+		if txErr != nil {
+			return txErr
+		}
+		if _, txErr = tx.NewDelete().Model(resource).WherePK().Exec(ctx); txErr != nil {
+			return txErr
+		}
+		return recordAudit(ctx, tx, caller.tenantID, auditEntityResource, resource.ID, tokenActor(req.GetToken()),
+			resource.toGRPC(), nil)
+	})
+	if err != nil {
+		return nil, dbError(err, "failed to delete a resource")
+	}
+
+	return &ppb.DeleteResourceResponse{Message: "Resource deleted successfully"}, nil
+}
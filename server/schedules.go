@@ -0,0 +1,402 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	ppb "github.com/TekClinic/Appointments-MicroService/appointments_protobuf"
+	"github.com/uptrace/bun"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	clockFormat     = "15:04"
+	endOfDay        = "24:00"
+	hoursPerDay     = 24
+	minutesPerHour  = 60
+	defaultTimezone = "UTC"
+)
+
+// scheduleRequest is implemented by requests that define a schedule.
+type scheduleRequest interface {
+	GetDoctorId() int32
+	GetTimezone() string
+	GetWeeklyHours() []*ppb.WeeklyHours
+	GetBreaks() []*ppb.WeeklyHours
+	GetOverrides() []*ppb.ScheduleOverride
+}
+
+// clockTime is a time of a day. Hour is 24 only for the end of the day.
+type clockTime struct {
+	Hour   int
+	Minute int
+}
+
+// parseClock parses a time of a day in the clockFormat. 24:00 is accepted as the end of the day.
+func parseClock(value string) (clockTime, error) {
+	if value == endOfDay {
+		return clockTime{Hour: hoursPerDay}, nil
+	}
+	parsed, err := time.Parse(clockFormat, value)
+	if err != nil {
+		return clockTime{}, fmt.Errorf("failed to parse time of day %q: %w", value, err)
+	}
+	return clockTime{Hour: parsed.Hour(), Minute: parsed.Minute()}, nil
+}
+
+// on returns the instant of the clock time on the given day, in the day's location.
+func (clock clockTime) on(day time.Time) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), clock.Hour, clock.Minute, 0, 0, day.Location())
+}
+
+// minutes returns the number of minutes since the start of the day.
+func (clock clockTime) minutes() int {
+	return clock.Hour*minutesPerHour + clock.Minute
+}
+
+// clockRange returns the time range between two clock times on the given day.
+// Returns an error if the clock times are malformed or the end is not after the start.
+func clockRange(day time.Time, startTime string, endTime string) (timeRange, error) {
+	start, err := parseClock(startTime)
+	if err != nil {
+		return timeRange{}, err
+	}
+	end, err := parseClock(endTime)
+	if err != nil {
+		return timeRange{}, err
+	}
+	if end.minutes() <= start.minutes() {
+		return timeRange{}, fmt.Errorf("end time %s has to be after start time %s", endTime, startTime)
+	}
+	return timeRange{Start: start.on(day), End: end.on(day)}, nil
+}
+
+// workingRanges returns the working time of the schedule within r, merged and sorted by start.
+func (schedule Schedule) workingRanges(r timeRange) ([]timeRange, error) {
+	location, err := time.LoadLocation(schedule.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	overrides := make(map[string][]DailyHours, len(schedule.Overrides))
+	for _, override := range schedule.Overrides {
+		overrides[override.Date] = override.Hours
+	}
+
+	var ranges []timeRange
+	start := r.Start.In(location)
+	firstDay := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, location)
+	for day := firstDay; day.Before(r.End); day = day.AddDate(0, 0, 1) {
+		dayRanges, dayErr := schedule.dayRanges(day, overrides)
+		if dayErr != nil {
+			return nil, dayErr
+		}
+		for _, dayRange := range dayRanges {
+			ranges = append(ranges, dayRange.clip(r))
+		}
+	}
+	return mergeRanges(ranges), nil
+}
+
+// dayRanges returns the working time of the schedule on the given day.
+func (schedule Schedule) dayRanges(day time.Time, overrides map[string][]DailyHours) ([]timeRange, error) {
+	if hours, ok := overrides[day.Format(dateFormat)]; ok {
+		ranges := make([]timeRange, 0, len(hours))
+		for _, interval := range hours {
+			r, err := clockRange(day, interval.StartTime, interval.EndTime)
+			if err != nil {
+				return nil, err
+			}
+			ranges = append(ranges, r)
+		}
+		return ranges, nil
+	}
+
+	working, err := weekdayRanges(day, schedule.WeeklyHours)
+	if err != nil {
+		return nil, err
+	}
+	breaks, err := weekdayRanges(day, schedule.Breaks)
+	if err != nil {
+		return nil, err
+	}
+	return subtractRanges(working, breaks), nil
+}
+
+// weekdayRanges returns the intervals that belong to the weekday of the given day as time ranges on that day.
+func weekdayRanges(day time.Time, intervals []WeeklyHours) ([]timeRange, error) {
+	var ranges []timeRange
+	for _, interval := range intervals {
+		if interval.Weekday != day.Weekday() {
+			continue
+		}
+		r, err := clockRange(day, interval.StartTime, interval.EndTime)
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+// fetchSchedule returns the schedule of the doctor in the tenant, or nil if the doctor doesn't have one.
+func fetchSchedule(ctx context.Context, db bun.IDB, tenantID string, doctorID int32) (*Schedule, error) {
+	schedule := new(Schedule)
+	err := whereTenant(db.NewSelect().Model(schedule), tenantID).
+		Where("? = ?", bun.Ident("doctor_id"), doctorID).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil //nolint:nilnil // a missing schedule is not an error
+	}
+	if err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// checkWorkingHours returns codes.FailedPrecondition if the appointment is outside of its doctor's working hours.
+// Doctors without a schedule can be booked at any time.
+func checkWorkingHours(ctx context.Context, db bun.IDB, appointment *Appointment) error {
+	schedule, err := fetchSchedule(ctx, db, appointment.TenantID, appointment.DoctorID)
+	if err != nil {
+		return dbError(err, "failed to fetch doctor schedule")
+	}
+	if schedule == nil {
+		return nil
+	}
+
+	working, err := schedule.workingRanges(appointment.timeRange())
+	if err != nil {
+		return dbError(err, "failed to compute working hours")
+	}
+	if !appointment.timeRange().coveredBy(working) {
+		return status.Error(codes.FailedPrecondition, "the appointment is outside of the doctor's working hours")
+	}
+	return nil
+}
+
+// scheduleFromGRPC validates the schedule defined by the request and converts it to Schedule.
+// Returns codes.InvalidArgument if the definition is invalid.
+func scheduleFromGRPC(req scheduleRequest) (*Schedule, error) {
+	if req.GetDoctorId() <= 0 {
+		return nil, invalidArgumentError("doctor_id", "DoctorID has to be a positive value")
+	}
+
+	schedule := &Schedule{DoctorID: req.GetDoctorId(), Timezone: req.GetTimezone()}
+	if schedule.Timezone == "" {
+		schedule.Timezone = defaultTimezone
+	}
+	if _, err := time.LoadLocation(schedule.Timezone); err != nil {
+		return nil, invalidArgumentError("timezone", fmt.Errorf("failed to load timezone: %w", err).Error())
+	}
+
+	var err error
+	if schedule.WeeklyHours, err = weeklyHoursFromGRPC(req.GetWeeklyHours()); err != nil {
+		return nil, invalidArgumentError("weekly_hours", fmt.Errorf("invalid weekly hours: %w", err).Error())
+	}
+	if schedule.Breaks, err = weeklyHoursFromGRPC(req.GetBreaks()); err != nil {
+		return nil, invalidArgumentError("breaks", fmt.Errorf("invalid breaks: %w", err).Error())
+	}
+
+	dates := make(map[string]bool, len(req.GetOverrides()))
+	for _, override := range req.GetOverrides() {
+		if _, dateErr := time.Parse(dateFormat, override.GetDate()); dateErr != nil {
+			return nil, invalidArgumentError("overrides", fmt.Errorf("failed to parse override date: %w", dateErr).Error())
+		}
+		if dates[override.GetDate()] {
+			return nil, invalidArgumentError("overrides", "multiple overrides for date "+override.GetDate())
+		}
+		dates[override.GetDate()] = true
+
+		hours := make([]DailyHours, len(override.GetHours()))
+		for i, interval := range override.GetHours() {
+			if _, rangeErr := clockRange(time.Time{}, interval.GetStartTime(), interval.GetEndTime()); rangeErr != nil {
+				return nil, invalidArgumentError("overrides", fmt.Errorf("invalid override hours: %w", rangeErr).Error())
+			}
+			hours[i] = DailyHours{StartTime: interval.GetStartTime(), EndTime: interval.GetEndTime()}
+		}
+		schedule.Overrides = append(schedule.Overrides, ScheduleOverride{Date: override.GetDate(), Hours: hours})
+	}
+	return schedule, nil
+}
+
+// weeklyHoursFromGRPC validates the intervals and converts them to WeeklyHours.
+func weeklyHoursFromGRPC(intervals []*ppb.WeeklyHours) ([]WeeklyHours, error) {
+	result := make([]WeeklyHours, len(intervals))
+	for i, interval := range intervals {
+		weekday := time.Weekday(interval.GetWeekday())
+		if weekday < time.Sunday || weekday > time.Saturday {
+			return nil, fmt.Errorf("weekday has to be between %d (Sunday) and %d (Saturday)", time.Sunday, time.Saturday)
+		}
+		if _, err := clockRange(time.Time{}, interval.GetStartTime(), interval.GetEndTime()); err != nil {
+			return nil, err
+		}
+		result[i] = WeeklyHours{Weekday: weekday, StartTime: interval.GetStartTime(), EndTime: interval.GetEndTime()}
+	}
+	return result, nil
+}
+
+// GetSchedule returns the working hours schedule of the given doctor.
+// Requires authentication. If authentication is not valid, codes.Unauthenticated is returned.
+// Requires the read_schedules permission, see accessPolicy. If roles are not sufficient
+// or the doctor's schedule is not accessible, codes.PermissionDenied is returned.
+// If the doctor doesn't have a schedule, codes.NotFound is returned.
+func (server appointmentsServer) GetSchedule(ctx context.Context,
+	req *ppb.GetScheduleRequest) (*ppb.GetScheduleResponse, error) {
+	caller, err := server.authorize(ctx, req.GetToken(), permReadSchedules)
+	if err != nil {
+		return nil, err
+	}
+	if err = caller.checkDoctor(req.GetDoctorId()); err != nil {
+		return nil, err
+	}
+
+	var schedule *Schedule
+	err = runInTenantTx(ctx, server.db, caller.tenantID, func(ctx context.Context, tx bun.Tx) error {
+		var txErr error
+		schedule, txErr = fetchSchedule(ctx, tx, caller.tenantID, req.GetDoctorId())
+		return txErr
+	})
+	if err != nil {
+		return nil, dbError(err, "failed to fetch a schedule")
+	}
+	if schedule == nil {
+		return nil, notFoundError("the doctor doesn't have a schedule")
+	}
+
+	return schedule.toGRPC(), nil
+}
+
+// CreateSchedule creates the working hours schedule of a doctor.
+// Requires authentication. If authentication is not valid, codes.Unauthenticated is returned.
+// Requires the write_schedules permission, see accessPolicy. If roles are not sufficient
+// or the doctor's schedule is not accessible, codes.PermissionDenied is returned.
+// If the schedule definition is invalid, codes.InvalidArgument is returned.
+// If the doctor already has a schedule, codes.AlreadyExists is returned.
+func (server appointmentsServer) CreateSchedule(ctx context.Context,
+	req *ppb.CreateScheduleRequest) (*ppb.CreateScheduleResponse, error) {
+	caller, err := server.authorize(ctx, req.GetToken(), permWriteSchedules)
+	if err != nil {
+		return nil, err
+	}
+
+	schedule, err := scheduleFromGRPC(req)
+	if err != nil {
+		return nil, err
+	}
+	if err = caller.checkDoctor(schedule.DoctorID); err != nil {
+		return nil, err
+	}
+	schedule.TenantID = caller.tenantID
+
+	err = runInTenantTx(ctx, server.db, caller.tenantID, func(ctx context.Context, tx bun.Tx) error {
+		existing, txErr := fetchSchedule(ctx, tx, caller.tenantID, schedule.DoctorID)
+		if txErr != nil {
+			return dbError(txErr, "failed to fetch a schedule")
+		}
+		if existing != nil {
+			return status.Error(codes.AlreadyExists, "the doctor already has a schedule")
+		}
+
+		if _, txErr = tx.NewInsert().Model(schedule).Exec(ctx); txErr != nil {
+			return txErr
+		}
+		return recordAudit(ctx, tx, caller.tenantID, auditEntitySchedule, schedule.ID, tokenActor(req.GetToken()),
+			nil, schedule.toGRPC())
+	})
+	if err != nil {
+		return nil, dbError(err, "failed to create a schedule")
+	}
+
+	return &ppb.CreateScheduleResponse{Id: schedule.ID}, nil
+}
+
+// UpdateSchedule replaces the working hours schedule of a doctor.
+// Existing appointments are not affected, the schedule only applies to appointments created or updated later.
+// Requires authentication. If authentication is not valid, codes.Unauthenticated is returned.
+// Requires the write_schedules permission, see accessPolicy. If roles are not sufficient
+// or the doctor's schedule is not accessible, codes.PermissionDenied is returned.
+// If the schedule definition is invalid, codes.InvalidArgument is returned.
+// If the doctor doesn't have a schedule, codes.NotFound is returned.
+func (server appointmentsServer) UpdateSchedule(ctx context.Context,
+	req *ppb.UpdateScheduleRequest) (*ppb.UpdateScheduleResponse, error) {
+	caller, err := server.authorize(ctx, req.GetToken(), permWriteSchedules)
+	if err != nil {
+		return nil, err
+	}
+
+	schedule, err := scheduleFromGRPC(req)
+	if err != nil {
+		return nil, err
+	}
+	if err = caller.checkDoctor(schedule.DoctorID); err != nil {
+		return nil, err
+	}
+	schedule.TenantID = caller.tenantID
+
+	err = runInTenantTx(ctx, server.db, caller.tenantID, func(ctx context.Context, tx bun.Tx) error {
+		existing, txErr := fetchSchedule(ctx, tx, caller.tenantID, schedule.DoctorID)
+		if txErr != nil {
+			return dbError(txErr, "failed to fetch a schedule")
+		}
+		if existing == nil {
+			return notFoundError("the doctor doesn't have a schedule")
+		}
+		schedule.ID = existing.ID
+
+		_, txErr = tx.NewUpdate().
+			Model(schedule).
+			WherePK().
+			ExcludeColumn("created_at", "deleted_at").
+			Exec(ctx)
+		if txErr != nil {
+			return txErr
+		}
+		return recordAudit(ctx, tx, caller.tenantID, auditEntitySchedule, schedule.ID, tokenActor(req.GetToken()),
+			existing.toGRPC(), schedule.toGRPC())
+	})
+	if err != nil {
+		return nil, dbError(err, "failed to update a schedule")
+	}
+
+	return &ppb.UpdateScheduleResponse{Id: schedule.ID}, nil
+}
+
+// DeleteSchedule deletes the working hours schedule of a doctor, after which the doctor can be booked at any time.
+// Requires authentication. If authentication is not valid, codes.Unauthenticated is returned.
+// Requires the write_schedules permission, see accessPolicy. If roles are not sufficient
+// or the doctor's schedule is not accessible, codes.PermissionDenied is returned.
+// If the doctor doesn't have a schedule, codes.NotFound is returned.
+func (server appointmentsServer) DeleteSchedule(ctx context.Context,
+	req *ppb.DeleteScheduleRequest) (*ppb.DeleteScheduleResponse, error) {
+	caller, err := server.authorize(ctx, req.GetToken(), permWriteSchedules)
+	if err != nil {
+		return nil, err
+	}
+	if err = caller.checkDoctor(req.GetDoctorId()); err != nil {
+		return nil, err
+	}
+
+	err = runInTenantTx(ctx, server.db, caller.tenantID, func(ctx context.Context, tx bun.Tx) error {
+		schedule, txErr := fetchSchedule(ctx, tx, caller.tenantID, req.GetDoctorId())
+		if txErr != nil {
+			return dbError(txErr, "failed to fetch a schedule")
+		}
+		if schedule == nil {
+			return notFoundError("the doctor doesn't have a schedule")
+		}
+		if _, txErr = tx.NewDelete().Model(schedule).WherePK().Exec(ctx); txErr != nil {
+			return txErr
+		}
+		return recordAudit(ctx, tx, caller.tenantID, auditEntitySchedule, schedule.ID, tokenActor(req.GetToken()),
+			schedule.toGRPC(), nil)
+	})
+	if err != nil {
+		return nil, dbError(err, "failed to delete a schedule")
+	}
+
+	return &ppb.DeleteScheduleResponse{Message: "Schedule deleted successfully"}, nil
+}
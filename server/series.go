@@ -0,0 +1,584 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	ppb "github.com/TekClinic/Appointments-MicroService/appointments_protobuf"
+	"github.com/teambition/rrule-go"
+	"github.com/uptrace/bun"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	maxSeriesOccurrences = 200
+	rrulePrefix          = "RRULE:"
+)
+
+// expandRecurrence returns the start times of all occurrences of the rule, starting from the first occurrence.
+// The rule is expanded in the given location, so that occurrences keep their local time across DST changes.
+// The rule has to be bounded by COUNT or UNTIL and can't yield more than maxSeriesOccurrences occurrences.
+func expandRecurrence(rule string, exDates []time.Time, first time.Time, location *time.Location) ([]time.Time, error) {
+	option, err := rrule.StrToROptionInLocation(strings.TrimPrefix(strings.TrimSpace(rule), rrulePrefix), location)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse rrule: %w", err)
+	}
+	if option.Count == 0 && option.Until.IsZero() {
+		return nil, errors.New("rrule has to be bounded by COUNT or UNTIL")
+	}
+	option.Dtstart = first.In(location)
+
+	recurrence, err := rrule.NewRRule(*option)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build rrule: %w", err)
+	}
+	set := rrule.Set{}
+	set.RRule(recurrence)
+	set.SetExDates(exDates)
+
+	var occurrences []time.Time
+	next := set.Iterator()
+	for occurrence, ok := next(); ok; occurrence, ok = next() {
+		if len(occurrences) == maxSeriesOccurrences {
+			return nil, fmt.Errorf("rrule can't yield more than %d occurrences", maxSeriesOccurrences)
+		}
+		occurrences = append(occurrences, occurrence)
+	}
+	if len(occurrences) == 0 {
+		return nil, errors.New("rrule doesn't yield any occurrences")
+	}
+	return occurrences, nil
+}
+
+// rewriteRule parses the rule in the given location, lets edit change it and formats it again.
+func rewriteRule(rule string, location *time.Location, edit func(option *rrule.ROption)) (string, error) {
+	option, err := rrule.StrToROptionInLocation(strings.TrimPrefix(strings.TrimSpace(rule), rrulePrefix), location)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse rrule: %w", err)
+	}
+	edit(option)
+	return option.RRuleString(), nil
+}
+
+// checkOccurrence runs the checks of a single appointment on an occurrence of a series.
+// If the occurrence can't be booked, the reason is returned as a conflict instead of an error.
+func (server appointmentsServer) checkOccurrence(ctx context.Context, db bun.IDB,
+	appointment *Appointment, ignoreSchedule bool) (*ppb.SeriesConflict, []*ppb.SchedulingWarning, error) {
+	warnings, err := server.checkAppointment(ctx, db, appointment, ignoreSchedule)
+	switch status.Code(err) { //nolint:exhaustive // other codes are not scheduling conflicts
+	case codes.OK:
+		return nil, warnings, nil
+	case codes.FailedPrecondition, codes.AlreadyExists:
+		return &ppb.SeriesConflict{
+			StartTime: appointment.StartTime.Format(time.RFC3339),
+			EndTime:   appointment.EndTime.Format(time.RFC3339),
+			Reason:    status.Convert(err).Message(),
+		}, nil, nil
+	default:
+		return nil, nil, err
+	}
+}
+
+// fetchSeriesScope returns the occurrences of the anchor's series that belong to the scope, sorted by start time.
+func fetchSeriesScope(ctx context.Context, db bun.IDB, anchor *Appointment,
+	scope ppb.SeriesScope) ([]Appointment, error) {
+	// The anchor is locked by fetchSeriesAnchor.
+	if scope == ppb.SeriesScope_SERIES_SCOPE_THIS {
+		return []Appointment{*anchor}, nil
+	}
+
+	var occurrences []Appointment
+	query := whereTenant(db.NewSelect().Model(&occurrences), anchor.TenantID).
+		Where("? = ?", bun.Ident("series_id"), anchor.SeriesID).
+		Order("start_time").
+		For("UPDATE")
+	if scope == ppb.SeriesScope_SERIES_SCOPE_THIS_AND_FOLLOWING {
+		query = query.Where("? >= ?", bun.Ident("start_time"), anchor.StartTime)
+	}
+	if err := query.Scan(ctx); err != nil {
+		return nil, err
+	}
+	return occurrences, nil
+}
+
+// fetchSeriesAnchor returns the appointment of the tenant a series-level request refers to
+// and locks it until the end of the transaction.
+// Returns codes.InvalidArgument if the scope is not set, codes.NotFound if the appointment doesn't exist
+// and codes.FailedPrecondition if the appointment is not part of a series.
+func fetchSeriesAnchor(ctx context.Context, db bun.IDB,
+	tenantID string, id int32, scope ppb.SeriesScope) (*Appointment, error) {
+	if _, ok := ppb.SeriesScope_name[int32(scope)]; !ok || scope == ppb.SeriesScope_SERIES_SCOPE_UNSPECIFIED {
+		return nil, invalidArgumentError("scope", "scope is required")
+	}
+
+	anchor := new(Appointment)
+	err := whereTenant(db.NewSelect().Model(anchor), tenantID).
+		Where("? = ?", bun.Ident("id"), id).
+		For("UPDATE").
+		Scan(ctx)
+	if err != nil {
+		return nil, dbError(err, "failed to fetch an appointment by id")
+	}
+	if anchor.SeriesID == 0 {
+		return nil, status.Error(codes.FailedPrecondition, "the appointment is not part of a series")
+	}
+	return anchor, nil
+}
+
+// parseSeriesRequest validates the series definition of the request and expands it to appointments.
+// Every occurrence has to satisfy the booking rules.
+// The returned appointments are not linked to a series yet.
+func parseSeriesRequest(req *ppb.CreateAppointmentSeriesRequest,
+	rules bookingRules) (*AppointmentSeries, []Appointment, error) {
+	startTime, err := time.Parse(time.RFC3339, req.GetStartTime())
+	if err != nil {
+		return nil, nil, invalidArgumentError("start_time", fmt.Errorf("failed to parse start time: %w", err).Error())
+	}
+	endTime, err := time.Parse(time.RFC3339, req.GetEndTime())
+	if err != nil {
+		return nil, nil, invalidArgumentError("end_time", fmt.Errorf("failed to parse end time: %w", err).Error())
+	}
+	if err = rules.validate(timeRange{Start: startTime, End: endTime}, true); err != nil {
+		return nil, nil, err
+	}
+	if req.GetDoctorId() <= 0 {
+		return nil, nil, invalidArgumentError("doctor_id", "DoctorID has to be a positive value")
+	}
+	if req.GetPatientId() < 0 {
+		return nil, nil, invalidArgumentError("patient_id", "PatientID has to be a non-negative value")
+	}
+
+	series := &AppointmentSeries{
+		PatientID: req.GetPatientId(),
+		DoctorID:  req.GetDoctorId(),
+		RRule:     req.GetRrule(),
+		Timezone:  req.GetTimezone(),
+		StartTime: startTime,
+		EndTime:   endTime,
+	}
+	if series.Timezone == "" {
+		series.Timezone = defaultTimezone
+	}
+	location, err := time.LoadLocation(series.Timezone)
+	if err != nil {
+		return nil, nil, invalidArgumentError("timezone", fmt.Errorf("failed to load timezone: %w", err).Error())
+	}
+	for _, exDate := range req.GetExdates() {
+		parsed, exDateErr := time.Parse(time.RFC3339, exDate)
+		if exDateErr != nil {
+			return nil, nil, invalidArgumentError("exdates", fmt.Errorf("failed to parse exdate: %w", exDateErr).Error())
+		}
+		series.ExDates = append(series.ExDates, parsed)
+	}
+
+	starts, err := expandRecurrence(series.RRule, series.ExDates, startTime, location)
+	if err != nil {
+		return nil, nil, invalidArgumentError("rrule", err.Error())
+	}
+	appointments := make([]Appointment, len(starts))
+	for i, start := range starts {
+		appointments[i] = Appointment{
+			PatientID: series.PatientID,
+			DoctorID:  series.DoctorID,
+			StartTime: start,
+			EndTime:   start.Add(endTime.Sub(startTime)),
+		}
+		if err = rules.validate(appointments[i].timeRange(), true); err != nil {
+			return nil, nil, err
+		}
+	}
+	return series, appointments, nil
+}
+
+// CreateAppointmentSeries creates a recurring series of appointments from an iCalendar RRULE.
+// Occurrences that can't be booked are skipped and reported as conflicts,
+// unless fail_on_conflict is set, in which case nothing is created.
+// Requires authentication. If authentication is not valid, codes.Unauthenticated is returned.
+// Requires the write_appointments permission, see accessPolicy. If roles are not sufficient
+// or the appointment is not accessible, codes.PermissionDenied is returned.
+// If ignore_schedule is set and the roles don't grant the override_schedule permission,
+// codes.PermissionDenied is returned.
+// If the series definition is invalid or one of the occurrences breaks the booking rules,
+// codes.InvalidArgument is returned.
+// If fail_on_conflict is set and one of the occurrences conflicts, codes.AlreadyExists is returned.
+// If none of the occurrences can be booked, codes.FailedPrecondition is returned.
+func (server appointmentsServer) CreateAppointmentSeries(ctx context.Context,
+	req *ppb.CreateAppointmentSeriesRequest) (*ppb.CreateAppointmentSeriesResponse, error) {
+	caller, err := server.authorize(ctx, req.GetToken(), permWriteAppointments)
+	if err != nil {
+		return nil, err
+	}
+	if err = server.authorizeScheduleOverride(ctx, req.GetToken(), req.GetIgnoreSchedule()); err != nil {
+		return nil, err
+	}
+
+	series, appointments, err := parseSeriesRequest(req, server.bookingRules)
+	if err != nil {
+		return nil, err
+	}
+	series.TenantID = caller.tenantID
+	for i := range appointments {
+		appointments[i].TenantID = caller.tenantID
+	}
+	// All occurrences have the same doctor and patient.
+	if err = caller.check(&appointments[0]); err != nil {
+		return nil, err
+	}
+
+	response := &ppb.CreateAppointmentSeriesResponse{}
+	var current *Appointment
+	err = runInTenantTx(ctx, server.db, caller.tenantID, func(ctx context.Context, tx bun.Tx) error {
+		if _, txErr := tx.NewInsert().Model(series).Exec(ctx); txErr != nil {
+			return txErr
+		}
+		for i := range appointments {
+			current = &appointments[i]
+			current.SeriesID = series.ID
+			conflict, warnings, txErr := server.checkOccurrence(ctx, tx, current, req.GetIgnoreSchedule())
+			if txErr != nil {
+				return txErr
+			}
+			if conflict != nil {
+				response.Conflicts = append(response.Conflicts, conflict)
+				continue
+			}
+			if _, txErr = tx.NewInsert().Model(current).Returning("id, version").Exec(ctx); txErr != nil {
+				return txErr
+			}
+			if txErr = recordEvent(ctx, tx, EventCreated, current, nil, tokenActor(req.GetToken())); txErr != nil {
+				return txErr
+			}
+			response.AppointmentIds = append(response.AppointmentIds, current.ID)
+			response.Warnings = append(response.Warnings, warnings...)
+		}
+
+		if len(response.GetConflicts()) > 0 && req.GetFailOnConflict() {
+			return status.Error(codes.AlreadyExists,
+				fmt.Sprintf("%d occurrences conflict, the first one at %s: %s", len(response.GetConflicts()),
+					response.GetConflicts()[0].GetStartTime(), response.GetConflicts()[0].GetReason()))
+		}
+		if len(response.GetAppointmentIds()) == 0 {
+			return status.Error(codes.FailedPrecondition,
+				"none of the occurrences can be booked: "+response.GetConflicts()[0].GetReason())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, writeError(ctx, server.db, current, err, "failed to create an appointment series")
+	}
+
+	response.SeriesId = series.ID
+	return response, nil
+}
+
+// updateSeriesDefinition updates the series of the anchor for the occurrences in the scope that are moved
+// by offset and get the given duration, doctor and patient. The scope of a single occurrence doesn't change
+// the series. The scope of all occurrences updates the series in place. The scope of the anchor
+// and the following occurrences splits the series: the series ends before the anchor, and a new series
+// starts with it, unless there are no earlier occurrences to keep.
+// Returns the ID of the series of the occurrences in the scope.
+func updateSeriesDefinition(ctx context.Context, tx bun.Tx, anchor *Appointment, scope ppb.SeriesScope,
+	update AppointmentSeries, offset time.Duration) (int32, error) {
+	if scope == ppb.SeriesScope_SERIES_SCOPE_THIS {
+		return anchor.SeriesID, nil
+	}
+
+	series := new(AppointmentSeries)
+	err := whereTenant(tx.NewSelect().Model(series), anchor.TenantID).
+		Where("? = ?", bun.Ident("id"), anchor.SeriesID).
+		For("UPDATE").
+		Scan(ctx)
+	if err != nil {
+		return 0, err
+	}
+	location, err := time.LoadLocation(series.Timezone)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load the timezone of series %d: %w", series.ID, err)
+	}
+
+	moved := *series
+	split := scope == ppb.SeriesScope_SERIES_SCOPE_THIS_AND_FOLLOWING && anchor.StartTime.After(series.StartTime)
+	if split {
+		starts, expandErr := expandRecurrence(series.RRule, series.ExDates, series.StartTime, location)
+		if expandErr != nil {
+			return 0, expandErr
+		}
+		moved.ID = 0
+		moved.StartTime = anchor.StartTime
+		moved.EndTime = anchor.EndTime
+		moved.ExDates = slices.DeleteFunc(slices.Clone(series.ExDates), func(exDate time.Time) bool {
+			return exDate.Before(anchor.StartTime)
+		})
+		// The new series can't keep counting from the start of the series, so it ends with the last occurrence.
+		if moved.RRule, err = rewriteRule(series.RRule, location, func(option *rrule.ROption) {
+			if option.Count != 0 {
+				option.Count = 0
+				option.Until = starts[len(starts)-1]
+			}
+		}); err != nil {
+			return 0, err
+		}
+
+		series.ExDates = slices.DeleteFunc(series.ExDates, func(exDate time.Time) bool {
+			return !exDate.Before(anchor.StartTime)
+		})
+		if series.RRule, err = rewriteRule(series.RRule, location, func(option *rrule.ROption) {
+			option.Count = 0
+			option.Until = anchor.StartTime.Add(-time.Second)
+		}); err != nil {
+			return 0, err
+		}
+		if _, err = tx.NewUpdate().Model(series).Column("rrule", "ex_dates").WherePK().Exec(ctx); err != nil {
+			return 0, err
+		}
+	}
+
+	moved.DoctorID = update.DoctorID
+	moved.PatientID = update.PatientID
+	moved.StartTime = moved.StartTime.Add(offset)
+	moved.EndTime = moved.StartTime.Add(update.EndTime.Sub(update.StartTime))
+	for i := range moved.ExDates {
+		moved.ExDates[i] = moved.ExDates[i].Add(offset)
+	}
+	if moved.RRule, err = rewriteRule(moved.RRule, location, func(option *rrule.ROption) {
+		if !option.Until.IsZero() {
+			option.Until = option.Until.Add(offset)
+		}
+	}); err != nil {
+		return 0, err
+	}
+	if split {
+		_, err = tx.NewInsert().Model(&moved).Exec(ctx)
+	} else {
+		_, err = tx.NewUpdate().
+			Model(&moved).
+			Column("doctor_id", "patient_id", "rrule", "ex_dates", "start_time", "end_time").
+			WherePK().
+			Exec(ctx)
+	}
+	return moved.ID, err
+}
+
+// UpdateAppointmentSeries updates occurrences of a recurring series.
+// The new start and end time apply to the given occurrence, other occurrences in the scope are moved
+// by the same offset and get the same duration. Occurrences that are no longer upcoming, e.g. cancelled
+// or completed ones, are left as is. All occurrences in the scope are updated or none of them.
+// The series is updated to match, and split in two for the given occurrence and the following ones.
+// Requires authentication. If authentication is not valid, codes.Unauthenticated is returned.
+// Requires the write_appointments permission, see accessPolicy. If roles are not sufficient
+// or the appointment is not accessible, codes.PermissionDenied is returned.
+// If ignore_schedule is set and the roles don't grant the override_schedule permission,
+// codes.PermissionDenied is returned.
+// If one of the fields has an invalid value or the new time of one of the occurrences breaks the booking rules
+// of its type, codes.InvalidArgument is returned.
+// If expected_version is set and the given occurrence has been changed since, codes.Aborted is returned.
+// If the appointment is not part of a series, codes.FailedPrecondition is returned.
+// If one of the occurrences can't be booked, the same error as in UpdateAppointment is returned.
+func (server appointmentsServer) UpdateAppointmentSeries(ctx context.Context,
+	req *ppb.UpdateAppointmentSeriesRequest) (*ppb.UpdateAppointmentSeriesResponse, error) {
+	caller, err := server.authorize(ctx, req.GetToken(), permWriteAppointments)
+	if err != nil {
+		return nil, err
+	}
+	if err = server.authorizeScheduleOverride(ctx, req.GetToken(), req.GetIgnoreSchedule()); err != nil {
+		return nil, err
+	}
+
+	startTime, err := time.Parse(time.RFC3339, req.GetStartTime())
+	if err != nil {
+		return nil, invalidArgumentError("start_time", fmt.Errorf("failed to parse start time: %w", err).Error())
+	}
+	endTime, err := time.Parse(time.RFC3339, req.GetEndTime())
+	if err != nil {
+		return nil, invalidArgumentError("end_time", fmt.Errorf("failed to parse end time: %w", err).Error())
+	}
+	if req.GetDoctorId() <= 0 {
+		return nil, invalidArgumentError("doctor_id", "DoctorID has to be a positive value")
+	}
+	if req.GetPatientId() < 0 {
+		return nil, invalidArgumentError("patient_id", "PatientID has to be a non-negative value")
+	}
+	if req.GetExpectedVersion() < 0 {
+		return nil, invalidArgumentError("expected_version", "expected_version has to be a non-negative value")
+	}
+	duration := endTime.Sub(startTime)
+
+	response := &ppb.UpdateAppointmentSeriesResponse{}
+	var current *Appointment
+	err = runInTenantTx(ctx, server.db, caller.tenantID, func(ctx context.Context, tx bun.Tx) error {
+		anchor, txErr := fetchSeriesAnchor(ctx, tx, caller.tenantID, req.GetAppointmentId(), req.GetScope())
+		if txErr != nil {
+			return txErr
+		}
+		if req.GetExpectedVersion() != 0 {
+			if txErr = checkVersion(anchor, req.GetExpectedVersion()); txErr != nil {
+				return txErr
+			}
+		}
+		offset := startTime.Sub(anchor.StartTime)
+
+		occurrences, txErr := fetchSeriesScope(ctx, tx, anchor, req.GetScope())
+		if txErr != nil {
+			return txErr
+		}
+		seriesID, txErr := updateSeriesDefinition(ctx, tx, anchor, req.GetScope(), AppointmentSeries{
+			DoctorID:  req.GetDoctorId(),
+			PatientID: req.GetPatientId(),
+			StartTime: startTime,
+			EndTime:   endTime,
+		}, offset)
+		if txErr != nil {
+			return txErr
+		}
+		types, txErr := fetchTypesByID(ctx, tx, caller.tenantID, occurrences)
+		if txErr != nil {
+			return txErr
+		}
+		// Occurrences are moved starting from the one closest to the direction of the move,
+		// so that a moved occurrence never overlaps an occurrence that has not been moved yet.
+		if offset > 0 {
+			slices.Reverse(occurrences)
+		}
+
+		// The released slots are offered to the waitlist once all occurrences are moved,
+		// since an occurrence may move into the slot released by another one.
+		formers := make([]Appointment, len(occurrences))
+		for i := range occurrences {
+			current = &occurrences[i]
+			if txErr = caller.check(current); txErr != nil {
+				return txErr
+			}
+			former := *current
+			formers[i] = former
+			current.SeriesID = seriesID
+			if !current.Status.upcoming() {
+				// The occurrence only follows its part of a split series.
+				if current.SeriesID == former.SeriesID {
+					continue
+				}
+				if _, txErr = tx.NewUpdate().Model(current).Column("series_id").WherePK().Returning("version").
+					Exec(ctx); txErr != nil {
+					return txErr
+				}
+				if txErr = recordEvent(ctx, tx, EventUpdated, current, &former,
+					tokenActor(req.GetToken())); txErr != nil {
+					return txErr
+				}
+				continue
+			}
+
+			current.PatientID = req.GetPatientId()
+			current.DoctorID = req.GetDoctorId()
+			current.StartTime = current.StartTime.Add(offset)
+			current.EndTime = current.StartTime.Add(duration)
+			if txErr = caller.check(current); txErr != nil {
+				return txErr
+			}
+			var appointmentType *AppointmentType
+			if found, ok := types[current.TypeID]; ok {
+				appointmentType = &found
+			}
+			if appointmentType != nil && current.DoctorID != former.DoctorID &&
+				!appointmentType.allows(current.DoctorID) {
+				return typeNotAllowedError(appointmentType)
+			}
+			txErr = server.bookingRules.forType(appointmentType).validate(current.timeRange(),
+				!current.StartTime.Equal(former.StartTime))
+			if txErr != nil {
+				return txErr
+			}
+
+			warnings, checkErr := server.checkAppointment(ctx, tx, current, req.GetIgnoreSchedule())
+			if checkErr != nil {
+				return checkErr
+			}
+			_, txErr = tx.NewUpdate().
+				Model(current).
+				WherePK().
+				ExcludeColumn("created_at", "deleted_at").
+				Returning("version").
+				Exec(ctx)
+			if txErr != nil {
+				return txErr
+			}
+			if txErr = recordEvent(ctx, tx, EventUpdated, current, &former, tokenActor(req.GetToken())); txErr != nil {
+				return txErr
+			}
+			response.AppointmentIds = append(response.AppointmentIds, current.ID)
+			response.Warnings = append(response.Warnings, warnings...)
+		}
+		for i := range occurrences {
+			if txErr = server.offerReleasedSlot(ctx, tx, &formers[i], &occurrences[i]); txErr != nil {
+				return txErr
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, writeError(ctx, server.db, current, err, "failed to update an appointment series")
+	}
+
+	return response, nil
+}
+
+// CancelAppointmentSeries cancels occurrences of a recurring series.
+// Occurrences that can no longer be cancelled, e.g. completed ones, are left as is.
+// Requires authentication. If authentication is not valid, codes.Unauthenticated is returned.
+// Requires the cancel_appointments permission, see accessPolicy. If roles are not sufficient
+// or the appointment is not accessible, codes.PermissionDenied is returned.
+// If the scope or the cancellation reason is not set, codes.InvalidArgument is returned.
+// If the appointment is not part of a series, codes.FailedPrecondition is returned.
+func (server appointmentsServer) CancelAppointmentSeries(ctx context.Context,
+	req *ppb.CancelAppointmentSeriesRequest) (*ppb.CancelAppointmentSeriesResponse, error) {
+	caller, err := server.authorize(ctx, req.GetToken(), permCancelAppointments)
+	if err != nil {
+		return nil, err
+	}
+
+	reason, note, err := cancellationFromGRPC(req.GetReason(), req.GetNote())
+	if err != nil {
+		return nil, err
+	}
+
+	response := &ppb.CancelAppointmentSeriesResponse{}
+	err = runInTenantTx(ctx, server.db, caller.tenantID, func(ctx context.Context, tx bun.Tx) error {
+		anchor, txErr := fetchSeriesAnchor(ctx, tx, caller.tenantID, req.GetAppointmentId(), req.GetScope())
+		if txErr != nil {
+			return txErr
+		}
+		occurrences, txErr := fetchSeriesScope(ctx, tx, anchor, req.GetScope())
+		if txErr != nil {
+			return txErr
+		}
+		actor := tokenActor(req.GetToken())
+		for i := range occurrences {
+			if txErr = caller.check(&occurrences[i]); txErr != nil {
+				return txErr
+			}
+			if !occurrences[i].Status.canTransitionTo(StatusCancelled) {
+				continue
+			}
+			former := occurrences[i]
+			occurrences[i].CancellationReason = reason
+			occurrences[i].CancellationNote = note
+			if txErr = changeStatus(ctx, tx, &occurrences[i], StatusCancelled, actor); txErr != nil {
+				return txErr
+			}
+			if txErr = server.offerReleasedSlot(ctx, tx, &former, &occurrences[i]); txErr != nil {
+				return txErr
+			}
+			response.AppointmentIds = append(response.AppointmentIds, occurrences[i].ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, dbError(err, "failed to cancel an appointment series")
+	}
+
+	return response, nil
+}
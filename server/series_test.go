@@ -0,0 +1,80 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestExpandRecurrence(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("failed to load timezone: %v", err)
+	}
+	// 2030-01-07 is a Monday. Daylight saving time starts in Berlin on 2030-03-31.
+	monday := time.Date(2030, 1, 7, 10, 0, 0, 0, time.UTC)
+	beforeDST := time.Date(2030, 3, 30, 10, 0, 0, 0, berlin)
+
+	tests := []struct {
+		name     string
+		rule     string
+		exDates  []time.Time
+		first    time.Time
+		location *time.Location
+		want     []time.Time
+		wantErr  bool
+	}{
+		{
+			name:     "weekly count",
+			rule:     "FREQ=WEEKLY;COUNT=3",
+			first:    monday,
+			location: time.UTC,
+			want:     []time.Time{monday, monday.AddDate(0, 0, 7), monday.AddDate(0, 0, 14)},
+		},
+		{
+			name:     "prefix and spaces",
+			rule:     " RRULE:FREQ=DAILY;COUNT=2 ",
+			first:    monday,
+			location: time.UTC,
+			want:     []time.Time{monday, monday.AddDate(0, 0, 1)},
+		},
+		{
+			name:     "until",
+			rule:     "FREQ=DAILY;UNTIL=20300109T100000Z",
+			first:    monday,
+			location: time.UTC,
+			want:     []time.Time{monday, monday.AddDate(0, 0, 1), monday.AddDate(0, 0, 2)},
+		},
+		{
+			name:     "excluded date",
+			rule:     "FREQ=DAILY;COUNT=3",
+			exDates:  []time.Time{monday.AddDate(0, 0, 1)},
+			first:    monday,
+			location: time.UTC,
+			want:     []time.Time{monday, monday.AddDate(0, 0, 2)},
+		},
+		{
+			name:     "local time across DST",
+			rule:     "FREQ=DAILY;COUNT=2",
+			first:    beforeDST.UTC(),
+			location: berlin,
+			want:     []time.Time{beforeDST, time.Date(2030, 3, 31, 10, 0, 0, 0, berlin)},
+		},
+		{name: "unbounded", rule: "FREQ=DAILY", first: monday, location: time.UTC, wantErr: true},
+		{name: "invalid", rule: "FREQ=SOMETIMES;COUNT=2", first: monday, location: time.UTC, wantErr: true},
+		{name: "too many", rule: "FREQ=DAILY;COUNT=201", first: monday, location: time.UTC, wantErr: true},
+		{name: "no occurrences", rule: "FREQ=DAILY;UNTIL=20200101T000000Z", first: monday, location: time.UTC,
+			wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			occurrences, err := expandRecurrence(test.rule, test.exDates, test.first, test.location)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("expandRecurrence() error = %v, want error %v", err, test.wantErr)
+			}
+			if !slices.EqualFunc(occurrences, test.want, time.Time.Equal) {
+				t.Errorf("expandRecurrence() = %v, want %v", occurrences, test.want)
+			}
+		})
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"slices"
+	"strings"
 	"time"
 
 	"go.uber.org/zap"
@@ -16,16 +18,26 @@ import (
 	"github.com/uptrace/bun"
 	"github.com/uptrace/bun/dialect/pgdialect"
 	"github.com/uptrace/bun/driver/pgdriver"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
 )
 
 // appointmentsServer is an implementation of GRPC appointment ms. It provides access to a database via db field.
+// patientOverlapPolicy defines how overlapping appointments of the same patient are handled.
+// events wakes up the watchers of appointment changes, outboxSink receives the domain events.
+// waitlistOfferTTL is how long patients on the waitlist can accept the slots offered to them,
+// slotHoldTTL is how long slots are held if the request doesn't set it.
 type appointmentsServer struct {
 	ppb.UnimplementedAppointmentsServiceServer
 	ms.BaseServiceServer
-	db *bun.DB
+	db                   *bun.DB
+	patientOverlapPolicy overlapPolicy
+	events               *eventHub
+	outboxSink           eventSink
+	idempotencyTTL       time.Duration
+	bookingRules         bookingRules
+	waitlistOfferTTL     time.Duration
+	slotHoldTTL          time.Duration
 }
 
 const (
@@ -34,6 +46,13 @@ const (
 	envDBDatabase = "DB_DATABASE"
 	envDBPassword = "DB_PASSWORD"
 
+	envPatientOverlapPolicy = "PATIENT_OVERLAP_POLICY"
+	envOutboxSink           = "OUTBOX_SINK"
+	envOutboxWebhookURL     = "OUTBOX_WEBHOOK_URL"
+	envIdempotencyTTL       = "IDEMPOTENCY_TTL"
+	envWaitlistOfferTTL     = "WAITLIST_OFFER_TTL"
+	envSlotHoldTTL          = "SLOT_HOLD_TTL"
+
 	applicationName = "appointments"
 
 	permissionDeniedMessage = "You don't have enough permission to access this resource"
@@ -44,27 +63,31 @@ const (
 
 // GetAppointment returns the appointment information corresponding to the given ID.
 // Requires authentication. If authentication is not valid, codes.Unauthenticated is returned.
-// Requires an admin role. If roles are not sufficient, codes.PermissionDenied is returned.
+// Requires the read_appointments permission, see accessPolicy. If roles are not sufficient
+// or the appointment is not accessible, codes.PermissionDenied is returned.
 // If the appointment with the given ID doesn't exist, codes.NotFound is returned.
 func (server appointmentsServer) GetAppointment(ctx context.Context,
 	req *ppb.GetAppointmentRequest) (*ppb.GetAppointmentResponse, error) {
-	claims, err := server.VerifyToken(ctx, req.GetToken())
+	caller, err := server.authorize(ctx, req.GetToken(), permReadAppointments)
 	if err != nil {
-		return nil, status.Error(codes.Unauthenticated, err.Error())
-	}
-	if !claims.HasRole("admin") {
-		return nil, status.Error(codes.PermissionDenied, permissionDeniedMessage)
+		return nil, err
 	}
 
 	appointment := new(Appointment)
-	err = server.db.NewSelect().
-		Model(appointment).
-		Where("? = ?", bun.Ident("id"), req.GetId()).
-		WhereAllWithDeleted().
-		Scan(ctx)
-
+	err = runInTenantTx(ctx, server.db, caller.tenantID, func(ctx context.Context, tx bun.Tx) error {
+		return whereTenant(tx.NewSelect().Model(appointment), caller.tenantID).
+			Where("? = ?", bun.Ident("id"), req.GetId()).
+			WhereAllWithDeleted().
+			Relation("StatusChanges", func(query *bun.SelectQuery) *bun.SelectQuery {
+				return query.Order("changed_at", "id")
+			}).
+			Scan(ctx)
+	})
 	if err != nil {
-		return nil, status.Error(codes.Internal, fmt.Errorf("failed to fetch an appointment by id: %w", err).Error())
+		return nil, dbError(err, "failed to fetch an appointment by id")
+	}
+	if err = caller.check(appointment); err != nil {
+		return nil, err
 	}
 
 	return appointment.toGRPC(), nil
@@ -72,215 +95,377 @@ func (server appointmentsServer) GetAppointment(ctx context.Context,
 
 // CreateAppointment creates a new appointment based on the provided details.
 // Requires authentication. If authentication is not valid, codes.Unauthenticated is returned.
-// Requires an admin role. If roles are not sufficient, codes.PermissionDenied is returned.
+// Requires the write_appointments permission, see accessPolicy. If roles are not sufficient
+// or the appointment is not accessible, codes.PermissionDenied is returned.
+// If ignore_schedule is set and the roles don't grant the override_schedule permission,
+// codes.PermissionDenied is returned.
+// If type_id is set, the end time defaults to the start time plus the duration of the type, and the booking rules
+// of the type apply. If the type doesn't exist, codes.NotFound is returned. If the doctor can't take
+// appointments of the type, codes.FailedPrecondition is returned.
+// If resource_ids are set, the rooms and equipment are booked with the appointment.
+// If one of the resources doesn't exist, codes.NotFound is returned.
+// If location_id is set and the location doesn't exist, codes.NotFound is returned.
 // If there's an error in parsing the start or end time, an appropriate error is returned.
+// If the time of the appointment breaks the booking rules, codes.InvalidArgument is returned
+// with a field violation for every broken rule, see bookingRules.
+// If the appointment is outside of the doctor's working hours and ignore_schedule is not set,
+// codes.FailedPrecondition is returned.
+// If the appointment overlaps another appointment of the same doctor or another appointment that books
+// one of the same resources, codes.AlreadyExists is returned.
+// If the appointment overlaps another appointment of the same patient, the patient overlap policy is applied:
+// codes.AlreadyExists is returned, a warning is added to the response, or the overlap is ignored.
 // If there's an error in creating the appointment, an appropriate error is returned.
 func (server appointmentsServer) CreateAppointment(
 	ctx context.Context,
 	req *ppb.CreateAppointmentRequest,
 ) (*ppb.CreateAppointmentResponse, error) {
-	claims, err := server.VerifyToken(ctx, req.GetToken())
+	caller, err := server.authorize(ctx, req.GetToken(), permWriteAppointments)
 	if err != nil {
-		return nil, status.Error(codes.Unauthenticated, err.Error())
+		return nil, err
 	}
-	if !claims.HasRole("admin") {
-		return nil, status.Error(codes.PermissionDenied, permissionDeniedMessage)
+	if err = server.authorizeScheduleOverride(ctx, req.GetToken(), req.GetIgnoreSchedule()); err != nil {
+		return nil, err
 	}
 
 	// Assuming req.GetStartTime() and req.GetEndTime() return strings in "2006-01-02T15:04:05Z" format
 	startTimeStr := req.GetStartTime()
 	startTime, err := time.Parse(time.RFC3339, startTimeStr)
 	if err != nil {
-		return nil, status.Error(codes.InvalidArgument, fmt.Errorf("failed to parse start time: %w", err).Error())
-	}
-
-	endTimeStr := req.GetEndTime()
-	endTime, err := time.Parse(time.RFC3339, endTimeStr)
-	if err != nil {
-		return nil, status.Error(codes.InvalidArgument, fmt.Errorf("failed to parse end time: %w", err).Error())
+		return nil, invalidArgumentError("start_time", fmt.Errorf("failed to parse start time: %w", err).Error())
 	}
 
 	patientID := req.GetPatientId()
 	doctorID := req.GetDoctorId()
 	if doctorID == 0 {
-		return nil, status.Error(codes.InvalidArgument,
+		return nil, invalidArgumentError("doctor_id",
 			errors.New("DoctorID is required in order to create an appointment").Error())
 	}
-	if patientID < 0 || doctorID < 0 {
-		return nil, status.Error(codes.InvalidArgument,
-			errors.New("PatientID, DoctorID have to be non-negative values").Error())
+	if patientID < 0 {
+		return nil, invalidArgumentError("patient_id", "PatientID has to be a non-negative value")
+	}
+	if doctorID < 0 {
+		return nil, invalidArgumentError("doctor_id", "DoctorID has to be a non-negative value")
 	}
 
 	appointment := Appointment{
-		PatientID:         patientID,
-		DoctorID:          doctorID,
-		StartTime:         startTime,
-		EndTime:           endTime,
-		ApprovedByPatient: false,
-		Visited:           false,
-	}
+		TenantID:   caller.tenantID,
+		PatientID:  patientID,
+		DoctorID:   doctorID,
+		StartTime:  startTime,
+		Status:     StatusScheduled,
+		TypeID:     req.GetTypeId(),
+		LocationID: req.GetLocationId(),
+	}
+	var warnings []*ppb.SchedulingWarning
+	err = runInTenantTx(ctx, server.db, caller.tenantID, func(ctx context.Context, tx bun.Tx) error {
+		var appointmentType *AppointmentType
+		var txErr error
+		if req.GetTypeId() != 0 {
+			if appointmentType, txErr = fetchBookableType(ctx, tx, caller.tenantID, req.GetTypeId(), doctorID); txErr != nil {
+				return txErr
+			}
+		}
+		if appointment.EndTime, txErr = appointmentEnd(req.GetEndTime(), startTime, appointmentType); txErr != nil {
+			return txErr
+		}
+		txErr = server.bookingRules.forType(appointmentType).validate(appointment.timeRange(), true)
+		if txErr != nil {
+			return txErr
+		}
+		resourceIDs, txErr := fetchBookableResources(ctx, tx, caller.tenantID, req.GetResourceIds())
+		if txErr != nil {
+			return txErr
+		}
+		appointment.ResourceIDs = resourceIDs
+		if txErr = fetchBookableLocation(ctx, tx, caller.tenantID, req.GetLocationId()); txErr != nil {
+			return txErr
+		}
+		if txErr = caller.check(&appointment); txErr != nil {
+			return txErr
+		}
 
-	_, err = server.db.NewInsert().Model(&appointment).Exec(ctx)
+		warnings, txErr = server.checkAppointment(ctx, tx, &appointment, req.GetIgnoreSchedule())
+		if txErr != nil {
+			return txErr
+		}
+		if _, txErr = tx.NewInsert().Model(&appointment).Returning("id, version").Exec(ctx); txErr != nil {
+			return txErr
+		}
+		return recordEvent(ctx, tx, EventCreated, &appointment, nil, tokenActor(req.GetToken()))
+	})
 	if err != nil {
-		return nil, status.Error(codes.Internal, fmt.Errorf("failed to create an appointment: %w", err).Error())
+		return nil, writeError(ctx, server.db, &appointment, err, "failed to create an appointment")
 	}
 
-	return &ppb.CreateAppointmentResponse{Id: appointment.ID}, nil
+	return &ppb.CreateAppointmentResponse{Id: appointment.ID, Warnings: warnings}, nil
 }
 
-// GetAppointments returns a list of appointments based on provided filters.
+// GetAppointments returns a list of appointments based on provided filters, sorted by the requested order.
 // Requires authentication. If authentication is not valid, codes.Unauthenticated is returned.
-// Requires an admin role. If roles are not sufficient, codes.PermissionDenied is returned.
-// If there's an error in parsing the date or fetching appointments, an appropriate error is returned.
+// Requires the read_appointments permission, see accessPolicy. If roles are not sufficient,
+// codes.PermissionDenied is returned. Doctors and patients only get their own appointments.
+// Pages are selected either by skip or by the page token returned with the previous page, see docs/grpc.md.
+// If location_id is set, date is a day in the timezone of the location, otherwise in UTC.
+// If one of the filters or the page token is invalid, codes.InvalidArgument is returned.
+// If the location doesn't exist, codes.NotFound is returned.
+// If there's an error in fetching appointments, an appropriate error is returned.
 func (server appointmentsServer) GetAppointments(ctx context.Context,
 	req *ppb.GetAppointmentsRequest) (*ppb.GetAppointmentsResponse, error) {
-	claims, err := server.VerifyToken(ctx, req.GetToken())
+	caller, err := server.authorize(ctx, req.GetToken(), permReadAppointments)
 	if err != nil {
-		return nil, status.Error(codes.Unauthenticated, err.Error())
-	}
-	if !claims.HasRole("admin") {
-		return nil, status.Error(codes.PermissionDenied, permissionDeniedMessage)
+		return nil, err
 	}
 
-	if req.GetSkip() < 0 {
-		return nil, status.Error(codes.InvalidArgument, "skip has to be a non-negative integer")
-	}
-	if req.GetLimit() <= 0 {
-		return nil, status.Error(codes.InvalidArgument, "limit has to be a positive integer")
-	}
-	if req.GetLimit() > maxPaginationLimit {
-		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("maximum allowed limit values is %d", maxPaginationLimit))
-	}
+	var count int
+	var appointments []Appointment
+	err = runInTenantTx(ctx, server.db, caller.tenantID, func(ctx context.Context, tx bun.Tx) error {
+		// Fetch appointments based on filters
+		baseQuery, txErr := filterAppointments(ctx, tx, caller.tenantID,
+			caller.scope(tx.NewSelect().Model((*Appointment)(nil))), req)
+		if txErr != nil {
+			return txErr
+		}
 
-	// Fetch appointments based on filters
-	baseQuery := server.db.NewSelect().Model((*Appointment)(nil))
+		// Count appointments unless the client doesn't need the total
+		if !req.GetSkipCount() {
+			if count, txErr = baseQuery.Count(ctx); txErr != nil {
+				return dbError(txErr, "failed to count appointments")
+			}
+		}
 
-	// Filter by date range
-	dateStr := req.GetDate()
-	if dateStr != "" {
-		date, dateErr := time.Parse(dateFormat, dateStr)
-		if dateErr != nil {
-			return nil, status.Error(codes.InvalidArgument, fmt.Errorf("failed to parse date: %w", dateErr).Error())
+		// Fetch a page of appointments with the columns needed to issue the next page token
+		query, txErr := paginate(baseQuery.Column("id", "start_time", "created_at"), req)
+		if txErr != nil {
+			return txErr
 		}
-		baseQuery = baseQuery.Where("start_time >= ?", date).Where("end_time <= ?", date.AddDate(0, 0, 1))
+		if txErr = query.Scan(ctx, &appointments); txErr != nil {
+			return dbError(txErr, "failed to fetch appointment IDs")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, dbError(err, "failed to fetch appointments")
 	}
-
-	// Filter by doctor ID
-	if req.GetDoctorId() != 0 {
-		baseQuery = baseQuery.Where("doctor_id = ?", req.GetDoctorId())
+	appointments, nextPageToken, err := nextPage(appointments, req)
+	if err != nil {
+		return nil, err
 	}
 
-	// Filter by patient ID
-	if req.GetPatientId() != 0 {
-		baseQuery = baseQuery.Where("patient_id = ?", req.GetPatientId())
+	ids := make([]int32, len(appointments))
+	for i, appointment := range appointments {
+		ids[i] = appointment.ID
 	}
+	return &ppb.GetAppointmentsResponse{
+		Count:         int32(count),
+		Results:       ids,
+		NextPageToken: nextPageToken,
+	}, nil
+}
 
-	// Execute a query and get count
-	var ids []int32
-	err = baseQuery.Column("id").Offset(int(req.GetSkip())).Limit(int(req.GetLimit())).Scan(ctx, &ids)
-	if err != nil {
-		return nil, status.Error(codes.Internal, fmt.Errorf("failed to fetch appointment IDs: %w", err).Error())
+// validatePagination returns codes.InvalidArgument if skip or limit are out of the allowed range.
+func validatePagination(skip int32, limit int32) error {
+	if skip < 0 {
+		return invalidArgumentError("skip", "skip has to be a non-negative integer")
+	}
+	if limit <= 0 {
+		return invalidArgumentError("limit", "limit has to be a positive integer")
+	}
+	if limit > maxPaginationLimit {
+		return invalidArgumentError("limit", fmt.Sprintf("maximum allowed limit values is %d", maxPaginationLimit))
 	}
+	return nil
+}
 
-	count, err := baseQuery.Count(ctx)
+// fetchAppointment returns the appointment of the caller's tenant with the given ID that is not deleted.
+// Returns codes.NotFound if there is no such appointment, codes.PermissionDenied if the appointment
+// is not accessible and codes.Aborted if it doesn't have the expected version.
+func fetchAppointment(ctx context.Context, db bun.IDB, caller access, id int32, expected int32) (*Appointment, error) {
+	appointment := new(Appointment)
+	err := whereTenant(db.NewSelect().Model(appointment), caller.tenantID).
+		Where("? = ?", bun.Ident("id"), id).
+		Scan(ctx)
 	if err != nil {
-		return nil, status.Error(codes.Internal, fmt.Errorf("failed to count appointments: %w", err).Error())
+		return nil, dbError(err, "failed to fetch an appointment by id")
 	}
-
-	return &ppb.GetAppointmentsResponse{
-		Count:   int32(count),
-		Results: ids,
-	}, nil
+	if err = caller.check(appointment); err != nil {
+		return nil, err
+	}
+	if err = checkVersion(appointment, expected); err != nil {
+		return nil, err
+	}
+	return appointment, nil
 }
 
 // AssignPatient assigns a patient to an existing appointment.
 // Requires authentication. If authentication is not valid, codes.Unauthenticated is returned.
-// Requires an admin role. If roles are not sufficient, codes.PermissionDenied is returned.
+// Requires the write_appointments permission, see accessPolicy. If roles are not sufficient
+// or the appointment is not accessible, codes.PermissionDenied is returned.
+// If the appointment overlaps another appointment of the same patient, the patient overlap policy is applied:
+// codes.AlreadyExists is returned, a warning is added to the response, or the overlap is ignored.
 // If there's an error in fetching or updating the appointment, an appropriate error is returned.
+// Requires the version of the appointment the change is based on, as expected_version or etag.
+// If neither is set, codes.InvalidArgument is returned. If the appointment has been changed since,
+// codes.Aborted is returned.
+// If the appointment with the given ID doesn't exist, codes.NotFound is returned.
 func (server appointmentsServer) AssignPatient(ctx context.Context,
 	req *ppb.AssignPatientRequest) (*ppb.AssignPatientResponse, error) {
-	claims, err := server.VerifyToken(ctx, req.GetToken())
+	caller, err := server.authorize(ctx, req.GetToken(), permWriteAppointments)
 	if err != nil {
-		return nil, status.Error(codes.Unauthenticated, err.Error())
-	}
-	if !claims.HasRole("admin") {
-		return nil, status.Error(codes.PermissionDenied, permissionDeniedMessage)
+		return nil, err
 	}
 
-	appointment := new(Appointment)
-	err = server.db.NewSelect().Model(appointment).Where("? = ?", bun.Ident("id"), req.GetId()).Scan(ctx)
+	expected, err := expectedVersion(req)
 	if err != nil {
-		return nil, status.Error(codes.Internal, fmt.Errorf("failed to fetch an appointment by id: %w", err).Error())
+		return nil, err
 	}
 
 	patientID := req.GetPatientId()
 	if patientID < 0 {
-		return nil, status.Error(codes.InvalidArgument,
-			errors.New("PatientID has to be non-negative values").Error())
+		return nil, invalidArgumentError("patient_id", "PatientID has to be a non-negative value")
 	}
-	formerPatientID := appointment.PatientID
-	appointment.PatientID = patientID
-	_, err = server.db.NewUpdate().Model(appointment).WherePK().Exec(ctx)
+
+	var appointment *Appointment
+	var former Appointment
+	var warnings []*ppb.SchedulingWarning
+	err = runInTenantTx(ctx, server.db, caller.tenantID, func(ctx context.Context, tx bun.Tx) error {
+		var txErr error
+		if appointment, txErr = fetchAppointment(ctx, tx, caller, req.GetId(), expected); txErr != nil {
+			return txErr
+		}
+		former = *appointment
+		appointment.PatientID = patientID
+		if txErr = caller.check(appointment); txErr != nil {
+			return txErr
+		}
+
+		warnings, txErr = checkPatientOverlap(ctx, tx, server.patientOverlapPolicy, appointment)
+		if txErr != nil {
+			return txErr
+		}
+		result, txErr := tx.NewUpdate().
+			Model(appointment).
+			WherePK().
+			Where("? = ?", bun.Ident("version"), expected).
+			Returning("version").
+			Exec(ctx)
+		if txErr != nil {
+			return txErr
+		}
+		if txErr = checkVersionedWrite(result, expected); txErr != nil {
+			return txErr
+		}
+		return recordEvent(ctx, tx, EventPatientAssigned, appointment, &former, tokenActor(req.GetToken()))
+	})
 	if err != nil {
-		return nil, status.Error(codes.Internal, fmt.Errorf("failed to assign patient to appointment: %w", err).Error())
+		return nil, writeError(ctx, server.db, appointment, err, "failed to assign patient to appointment")
 	}
 
-	return &ppb.AssignPatientResponse{PatientId: formerPatientID}, nil
+	return &ppb.AssignPatientResponse{PatientId: former.PatientID, Warnings: warnings, Version: appointment.Version}, nil
 }
 
 // RemovePatient removes a patient from an existing appointment.
 // Requires authentication. If authentication is not valid, codes.Unauthenticated is returned.
-// Requires an admin role. If roles are not sufficient, codes.PermissionDenied is returned.
+// Requires the write_appointments permission, see accessPolicy. If roles are not sufficient
+// or the appointment is not accessible, codes.PermissionDenied is returned.
 // If there's an error in fetching or updating the appointment, an appropriate error is returned.
+// Requires the version of the appointment the change is based on, as expected_version or etag.
+// If neither is set, codes.InvalidArgument is returned. If the appointment has been changed since,
+// codes.Aborted is returned.
+// If the appointment with the given ID doesn't exist, codes.NotFound is returned.
 func (server appointmentsServer) RemovePatient(ctx context.Context,
 	req *ppb.RemovePatientRequest) (*ppb.RemovePatientResponse, error) {
-	claims, err := server.VerifyToken(ctx, req.GetToken())
+	caller, err := server.authorize(ctx, req.GetToken(), permWriteAppointments)
 	if err != nil {
-		return nil, status.Error(codes.Unauthenticated, err.Error())
-	}
-	if !claims.HasRole("admin") {
-		return nil, status.Error(codes.PermissionDenied, permissionDeniedMessage)
+		return nil, err
 	}
 
-	appointment := new(Appointment)
-	err = server.db.NewSelect().Model(appointment).Where("? = ?", bun.Ident("id"), req.GetId()).Scan(ctx)
+	expected, err := expectedVersion(req)
 	if err != nil {
-		return nil, status.Error(codes.Internal, fmt.Errorf("failed to fetch an appointment by id: %w", err).Error())
+		return nil, err
 	}
 
-	patientID := appointment.PatientID
-	appointment.PatientID = 0
-	_, err = server.db.NewUpdate().Model(appointment).WherePK().Exec(ctx)
+	var appointment *Appointment
+	var former Appointment
+	err = runInTenantTx(ctx, server.db, caller.tenantID, func(ctx context.Context, tx bun.Tx) error {
+		var txErr error
+		if appointment, txErr = fetchAppointment(ctx, tx, caller, req.GetId(), expected); txErr != nil {
+			return txErr
+		}
+		former = *appointment
+		appointment.PatientID = 0
+
+		result, txErr := tx.NewUpdate().
+			Model(appointment).
+			WherePK().
+			Where("? = ?", bun.Ident("version"), expected).
+			Returning("version").
+			Exec(ctx)
+		if txErr != nil {
+			return txErr
+		}
+		if txErr = checkVersionedWrite(result, expected); txErr != nil {
+			return txErr
+		}
+		txErr = recordEvent(ctx, tx, EventPatientRemoved, appointment, &former, tokenActor(req.GetToken()))
+		if txErr != nil {
+			return txErr
+		}
+		return server.offerReleasedSlot(ctx, tx, &former, appointment)
+	})
 	if err != nil {
-		return nil, status.Error(codes.Internal, fmt.Errorf("failed to remove patient from appointment: %w", err).Error())
+		return nil, dbError(err, "failed to remove patient from appointment")
 	}
 
-	return &ppb.RemovePatientResponse{PatientId: patientID}, nil
+	return &ppb.RemovePatientResponse{PatientId: former.PatientID, Version: appointment.Version}, nil
 }
 
 // DeleteAppointment deletes an appointment based on the provided ID.
 // Requires authentication. If authentication is not valid, codes.Unauthenticated is returned.
-// Requires an admin role. If roles are not sufficient, codes.PermissionDenied is returned.
+// Requires the delete_appointments permission, see accessPolicy. If roles are not sufficient
+// or the appointment is not accessible, codes.PermissionDenied is returned.
 // If there's an error in fetching or deleting the appointment, an appropriate error is returned.
+// Requires the version of the appointment the change is based on, as expected_version or etag.
+// If neither is set, codes.InvalidArgument is returned. If the appointment has been changed since,
+// codes.Aborted is returned.
+// If the appointment with the given ID doesn't exist, codes.NotFound is returned.
 func (server appointmentsServer) DeleteAppointment(ctx context.Context,
 	req *ppb.DeleteAppointmentRequest) (*ppb.DeleteAppointmentResponse, error) {
-	claims, err := server.VerifyToken(ctx, req.GetToken())
+	caller, err := server.authorize(ctx, req.GetToken(), permDeleteAppointments)
 	if err != nil {
-		return nil, status.Error(codes.Unauthenticated, err.Error())
-	}
-	if !claims.HasRole("admin") {
-		return nil, status.Error(codes.PermissionDenied, permissionDeniedMessage)
+		return nil, err
 	}
 
-	appointment := new(Appointment)
-	err = server.db.NewSelect().Model(appointment).Where("? = ?", bun.Ident("id"), req.GetId()).Scan(ctx)
+	expected, err := expectedVersion(req)
 	if err != nil {
-		return nil, status.Error(codes.Internal, fmt.Errorf("failed to fetch an appointment by id: %w", err).Error())
+		return nil, err
 	}
 
-	_, err = server.db.NewDelete().Model(appointment).WherePK().Exec(ctx)
+	err = runInTenantTx(ctx, server.db, caller.tenantID, func(ctx context.Context, tx bun.Tx) error {
+		appointment, txErr := fetchAppointment(ctx, tx, caller, req.GetId(), expected)
+		if txErr != nil {
+			return txErr
+		}
+		former := *appointment
+
+		result, txErr := tx.NewDelete().
+			Model(appointment).
+			WherePK().
+			Where("? = ?", bun.Ident("version"), expected).
+			Returning("version").
+			Exec(ctx)
+		if txErr != nil {
+			return txErr
+		}
+		if txErr = checkVersionedWrite(result, expected); txErr != nil {
+			return txErr
+		}
+		if txErr = recordEvent(ctx, tx, EventDeleted, appointment, &former, tokenActor(req.GetToken())); txErr != nil {
+			return txErr
+		}
+		return server.offerReleasedSlot(ctx, tx, &former, nil)
+	})
 	if err != nil {
-		return nil, status.Error(codes.Internal, fmt.Errorf("failed to delete appointment: %w", err).Error())
+		return nil, dbError(err, "failed to delete appointment")
 	}
 
 	return &ppb.DeleteAppointmentResponse{Message: "Appointment deleted successfully"}, nil
@@ -308,6 +493,34 @@ func createAppointmentsServer() (*appointmentsServer, error) {
 	if err != nil {
 		return nil, err
 	}
+	patientOverlapPolicy, err := parseOverlapPolicy(
+		ms.GetOptionalEnv(envPatientOverlapPolicy, string(overlapPolicyReject)))
+	if err != nil {
+		return nil, err
+	}
+	outboxSink, err := newEventSink(ms.GetOptionalEnv(envOutboxSink, outboxSinkLog),
+		ms.GetOptionalEnv(envOutboxWebhookURL, ""))
+	if err != nil {
+		return nil, err
+	}
+	idempotencyTTL, err := parseIdempotencyTTL(
+		ms.GetOptionalEnv(envIdempotencyTTL, defaultIdempotencyTTL.String()))
+	if err != nil {
+		return nil, err
+	}
+	rules, err := parseBookingRules()
+	if err != nil {
+		return nil, err
+	}
+	waitlistOfferTTL, err := parseWaitlistOfferTTL(
+		ms.GetOptionalEnv(envWaitlistOfferTTL, defaultWaitlistOfferTTL.String()))
+	if err != nil {
+		return nil, err
+	}
+	slotHoldTTL, err := parseHoldTTL(ms.GetOptionalEnv(envSlotHoldTTL, defaultHoldTTL.String()))
+	if err != nil {
+		return nil, err
+	}
 	connector := pgdriver.NewConnector(
 		pgdriver.WithNetwork("tcp"),
 		pgdriver.WithAddr(addr),
@@ -319,76 +532,230 @@ func createAppointmentsServer() (*appointmentsServer, error) {
 	)
 	db := bun.NewDB(sql.OpenDB(connector), pgdialect.New())
 	db.AddQueryHook(ms.GetDBQueryHook())
-	return &appointmentsServer{BaseServiceServer: base, db: db}, nil
+	return &appointmentsServer{
+		BaseServiceServer:    base,
+		db:                   db,
+		patientOverlapPolicy: patientOverlapPolicy,
+		events:               newEventHub(),
+		outboxSink:           outboxSink,
+		idempotencyTTL:       idempotencyTTL,
+		bookingRules:         rules,
+		waitlistOfferTTL:     waitlistOfferTTL,
+		slotHoldTTL:          slotHoldTTL,
+	}, nil
+}
+
+// updatableFields are the fields of an appointment that UpdateAppointment can change,
+// in the order they are validated. The status is changed by the lifecycle RPCs only.
+func updatableFields() []string {
+	return []string{"patient_id", "doctor_id", "start_time", "end_time", "resource_ids", "location_id"}
+}
+
+// checkDeprecatedStatusFields returns codes.InvalidArgument if the request sets one of the flags that were
+// replaced by the status. The status is changed by the lifecycle RPCs only, so the flags can't be applied,
+// and they are rejected rather than ignored, so that an older client doesn't lose the change silently.
+func checkDeprecatedStatusFields(req *ppb.UpdateAppointmentRequest) error {
+	var violations []*errdetails.BadRequest_FieldViolation
+	//nolint:staticcheck // deprecated fields are checked for older clients
+	if req.GetApprovedByPatient() {
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{Field: "approved_by_patient",
+			Description: "approved_by_patient is no longer supported, use ConfirmAppointment instead"})
+	}
+	//nolint:staticcheck // deprecated fields are checked for older clients
+	if req.GetVisited() {
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{Field: "visited",
+			Description: "visited is no longer supported, use CompleteAppointment instead"})
+	}
+	if len(violations) > 0 {
+		return badRequestError(violations)
+	}
+	return nil
+}
+
+// applyAppointmentUpdate validates the fields of the request listed in its update mask and applies them
+// to the appointment. Without an update mask, all updatable fields are validated and replaced.
+// Returns the columns that have to be written.
+func applyAppointmentUpdate(appointment *Appointment, req *ppb.UpdateAppointmentRequest) ([]string, error) {
+	paths := updatableFields()
+	if mask := req.GetUpdateMask(); mask != nil {
+		if len(mask.GetPaths()) == 0 {
+			return nil, invalidArgumentError("update_mask", "update mask has to list at least one field")
+		}
+		for _, path := range mask.GetPaths() {
+			if !slices.Contains(updatableFields(), path) {
+				return nil, invalidArgumentError("update_mask", fmt.Sprintf("field %q can't be updated, "+
+					"expected one of %s", path, strings.Join(updatableFields(), ", ")))
+			}
+		}
+		paths = slices.DeleteFunc(paths, func(path string) bool { return !slices.Contains(mask.GetPaths(), path) })
+	}
+
+	for _, path := range paths {
+		switch path {
+		case "patient_id":
+			if req.GetPatientId() < 0 {
+				return nil, invalidArgumentError("patient_id", "PatientID has to be a non-negative value")
+			}
+			appointment.PatientID = req.GetPatientId()
+		case "doctor_id":
+			if req.GetDoctorId() == 0 {
+				return nil, invalidArgumentError("doctor_id",
+					errors.New("DoctorID is required in order to update an appointment").Error())
+			}
+			if req.GetDoctorId() < 0 {
+				return nil, invalidArgumentError("doctor_id", "DoctorID has to be a non-negative value")
+			}
+			appointment.DoctorID = req.GetDoctorId()
+		case "start_time":
+			startTime, err := time.Parse(time.RFC3339, req.GetStartTime())
+			if err != nil {
+				return nil, invalidArgumentError("start_time",
+					fmt.Errorf("failed to parse start time: %w", err).Error())
+			}
+			appointment.StartTime = startTime
+		case "end_time":
+			endTime, err := time.Parse(time.RFC3339, req.GetEndTime())
+			if err != nil {
+				return nil, invalidArgumentError("end_time", fmt.Errorf("failed to parse end time: %w", err).Error())
+			}
+			appointment.EndTime = endTime
+		case "resource_ids":
+			resourceIDs, err := parseResourceIDs(req.GetResourceIds())
+			if err != nil {
+				return nil, err
+			}
+			appointment.ResourceIDs = resourceIDs
+		case "location_id":
+			if req.GetLocationId() < 0 {
+				return nil, invalidArgumentError("location_id", "LocationID has to be a non-negative value")
+			}
+			appointment.LocationID = req.GetLocationId()
+		default:
+			return nil, fmt.Errorf("unexpected updatable field %q", path)
+		}
+	}
+	return paths, nil
 }
 
 // UpdateAppointment updates an existing appointment based on the provided details.
 // Requires authentication. If authentication is not valid, codes.Unauthenticated is returned.
-// Requires an admin role. If roles are not sufficient, codes.PermissionDenied is returned.
-// If one of the fields has an invalid value, an appropriate error is returned.
+// Requires the write_appointments permission, see accessPolicy. If roles are not sufficient
+// or the appointment is not accessible, codes.PermissionDenied is returned.
+// If ignore_schedule is set and the roles don't grant the override_schedule permission,
+// codes.PermissionDenied is returned.
+// If update_mask is set, only the listed fields are changed and validated, otherwise all fields are replaced.
+// If one of the fields or the update mask has an invalid value, codes.InvalidArgument is returned.
+// If the deprecated approved_by_patient or visited is set, codes.InvalidArgument is returned.
+// If the time is changed and the new time breaks the booking rules of the appointment type,
+// codes.InvalidArgument is returned with a field violation for every broken rule, see bookingRules.
+// If the doctor is changed and the new doctor can't take appointments of the type, codes.FailedPrecondition
+// is returned.
+// Requires the version of the appointment the change is based on, as expected_version or etag.
+// If neither is set, codes.InvalidArgument is returned. If the appointment has been changed since,
+// codes.Aborted is returned.
 // If the appointment with the given ID doesn't exist, codes.NotFound is returned.
+// If one of the added resources or the new location doesn't exist, codes.NotFound is returned.
+// If the appointment is outside of the doctor's working hours and ignore_schedule is not set,
+// codes.FailedPrecondition is returned.
+// If the appointment overlaps another appointment of the same doctor or another appointment that books
+// one of the same resources, codes.AlreadyExists is returned.
+// If the appointment overlaps another appointment of the same patient, the patient overlap policy is applied:
+// codes.AlreadyExists is returned, a warning is added to the response, or the overlap is ignored.
 // If there's an error in fetching or updating the appointment, an appropriate error is returned.
 func (server appointmentsServer) UpdateAppointment(ctx context.Context,
 	req *ppb.UpdateAppointmentRequest) (*ppb.UpdateAppointmentResponse, error) {
-	claims, err := server.VerifyToken(ctx, req.GetToken())
+	caller, err := server.authorize(ctx, req.GetToken(), permWriteAppointments)
 	if err != nil {
-		return nil, status.Error(codes.Unauthenticated, err.Error())
+		return nil, err
 	}
-	if !claims.HasRole("admin") {
-		return nil, status.Error(codes.PermissionDenied, permissionDeniedMessage)
+	if err = server.authorizeScheduleOverride(ctx, req.GetToken(), req.GetIgnoreSchedule()); err != nil {
+		return nil, err
 	}
 
 	appointmentID := req.GetId()
 	if appointmentID == 0 {
-		return nil, status.Error(codes.InvalidArgument, "AppointmentID is required")
-	}
-
-	appointment := new(Appointment)
-	err = server.db.NewSelect().Model(appointment).Where("? = ?", bun.Ident("id"), appointmentID).Scan(ctx)
-	if err != nil {
-		return nil, status.Error(codes.Internal, fmt.Errorf("failed to fetch an appointment by id: %w", err).Error())
+		return nil, invalidArgumentError("id", "AppointmentID is required")
 	}
-
-	patientID := req.GetPatientId()
-	doctorID := req.GetDoctorId()
-	if doctorID == 0 {
-		return nil, status.Error(codes.InvalidArgument,
-			errors.New("DoctorID is required in order to update an appointment").Error())
-	}
-	if patientID < 0 || doctorID < 0 {
-		return nil, status.Error(codes.InvalidArgument,
-			errors.New("PatientID, DoctorID have to be non-negative values").Error())
+	if err = checkDeprecatedStatusFields(req); err != nil {
+		return nil, err
 	}
-
-	startTimeStr := req.GetStartTime()
-	startTime, err := time.Parse(time.RFC3339, startTimeStr)
+	expected, err := expectedVersion(req)
 	if err != nil {
-		return nil, status.Error(codes.InvalidArgument, fmt.Errorf("failed to parse start time: %w", err).Error())
+		return nil, err
 	}
 
-	endTimeStr := req.GetEndTime()
-	endTime, err := time.Parse(time.RFC3339, endTimeStr)
-	if err != nil {
-		return nil, status.Error(codes.InvalidArgument, fmt.Errorf("failed to parse end time: %w", err).Error())
-	}
+	var appointment *Appointment
+	var warnings []*ppb.SchedulingWarning
+	err = runInTenantTx(ctx, server.db, caller.tenantID, func(ctx context.Context, tx bun.Tx) error {
+		var txErr error
+		if appointment, txErr = fetchAppointment(ctx, tx, caller, appointmentID, expected); txErr != nil {
+			return txErr
+		}
+		former := *appointment
 
-	appointment.PatientID = patientID
-	appointment.DoctorID = doctorID
-	appointment.StartTime = startTime
-	appointment.EndTime = endTime
-	appointment.ApprovedByPatient = req.GetApprovedByPatient()
-	appointment.Visited = req.GetVisited()
+		columns, txErr := applyAppointmentUpdate(appointment, req)
+		if txErr != nil {
+			return txErr
+		}
+		appointmentType, txErr := fetchTypeOf(ctx, tx, appointment)
+		if txErr != nil {
+			return txErr
+		}
+		if appointmentType != nil && appointment.DoctorID != former.DoctorID &&
+			!appointmentType.allows(appointment.DoctorID) {
+			return typeNotAllowedError(appointmentType)
+		}
+		// The times are validated together, since changing one of them may invalidate the other.
+		if !appointment.StartTime.Equal(former.StartTime) || !appointment.EndTime.Equal(former.EndTime) {
+			txErr = server.bookingRules.forType(appointmentType).validate(appointment.timeRange(),
+				!appointment.StartTime.Equal(former.StartTime))
+			if txErr != nil {
+				return txErr
+			}
+		}
+		// Resources and locations deleted after they were booked are kept, only the added ones have to exist.
+		added := slices.DeleteFunc(slices.Clone(appointment.ResourceIDs), func(id int32) bool {
+			return slices.Contains(former.ResourceIDs, id)
+		})
+		if _, txErr = fetchBookableResources(ctx, tx, caller.tenantID, added); txErr != nil {
+			return txErr
+		}
+		if appointment.LocationID != former.LocationID {
+			if txErr = fetchBookableLocation(ctx, tx, caller.tenantID, appointment.LocationID); txErr != nil {
+				return txErr
+			}
+		}
+		if txErr = caller.check(appointment); txErr != nil {
+			return txErr
+		}
 
-	_, err = server.db.NewUpdate().
-		Model(appointment).
-		WherePK().
-		ExcludeColumn("created_at", "deleted_at").
-		Exec(ctx)
+		warnings, txErr = server.checkAppointment(ctx, tx, appointment, req.GetIgnoreSchedule())
+		if txErr != nil {
+			return txErr
+		}
+		result, txErr := tx.NewUpdate().
+			Model(appointment).
+			WherePK().
+			Where("? = ?", bun.Ident("version"), expected).
+			Column(columns...).
+			Returning("version").
+			Exec(ctx)
+		if txErr != nil {
+			return txErr
+		}
+		if txErr = checkVersionedWrite(result, expected); txErr != nil {
+			return txErr
+		}
+		if txErr = recordEvent(ctx, tx, EventUpdated, appointment, &former, tokenActor(req.GetToken())); txErr != nil {
+			return txErr
+		}
+		return server.offerReleasedSlot(ctx, tx, &former, appointment)
+	})
 	if err != nil {
-		return nil, status.Error(codes.Internal, fmt.Errorf("failed to update appointment: %w", err).Error())
+		return nil, writeError(ctx, server.db, appointment, err, "failed to update appointment")
 	}
 
-	return &ppb.UpdateAppointmentResponse{Id: appointment.ID}, nil
+	return &ppb.UpdateAppointmentResponse{Id: appointment.ID, Warnings: warnings, Version: appointment.Version}, nil
 }
 
 func main() {
@@ -402,12 +769,19 @@ func main() {
 		zap.L().Fatal("Failed to create schema", zap.Error(err))
 	}
 
+	go service.events.run(context.Background(), service.db)
+	go runOutboxRelay(context.Background(), service.db, service.outboxSink, service.events)
+	go runIdempotencyPruner(context.Background(), service.db)
+	go runWaitlistOfferExpiry(context.Background(), service.db, service.waitlistOfferTTL)
+	go runHoldSweeper(context.Background(), service.db)
+
 	listen, err := net.Listen("tcp", ":"+service.GetPort())
 	if err != nil {
 		zap.L().Fatal("Failed to listen", zap.Error(err))
 	}
 
-	srv := grpc.NewServer(ms.GetGRPCServerOptions()...)
+	srv := grpc.NewServer(append(ms.GetGRPCServerOptions(),
+		grpc.ChainUnaryInterceptor(service.idempotencyInterceptor))...)
 	ppb.RegisterAppointmentsServiceServer(srv, service)
 
 	zap.L().Info("Server listening on :" + service.GetPort())
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+const (
+	// defaultTenant is the tenant of the records created before the service was multi-tenant.
+	defaultTenant = "default"
+	// tenantSetting is the PostgreSQL setting that binds a transaction to a tenant, see runInTenantTx.
+	tenantSetting = "appointments.tenant_id"
+	// systemSetting is the PostgreSQL setting that lets a transaction of the service itself see the rows
+	// of all tenants, see runInSystemTx.
+	systemSetting = "appointments.system"
+	// tenantPolicy is the name of the row-level security policy of the tables of tenants.
+	tenantPolicy = "tenant_isolation"
+)
+
+// tenantTables are the tables whose rows belong to a tenant. Each of them has a tenant_id column
+// and is protected by row-level security, see tenantMigrations.
+func tenantTables() []string {
+	return []string{
+		"appointments", "appointment_series", "schedules", "appointment_types", "resources", "locations",
+		"audit_events", "appointment_events", "waitlist_entries", "waitlist_offers",
+		"slot_holds", "status_changes", "outbox_messages", "idempotency_keys",
+	}
+}
+
+// tenantMigrations returns the migrations that assign the existing rows of the table to defaultTenant
+// and restrict the table to the tenant of the transaction. A transaction that is bound neither to a tenant
+// nor to the system sees no rows, so a query that misses runInTenantTx fails closed.
+// The policy created before, which let unbound transactions see all rows, is replaced.
+// Row-level security doesn't apply to superusers, so the service has to connect as an ordinary role.
+func tenantMigrations(table string) []string {
+	check := "tenant_id = current_setting('" + tenantSetting + "', true) " +
+		"OR current_setting('" + systemSetting + "', true) = 'on'"
+	return []string{
+		"ALTER TABLE " + table + " ADD COLUMN IF NOT EXISTS tenant_id varchar NOT NULL DEFAULT '" + defaultTenant + "';",
+		"ALTER TABLE " + table + " ENABLE ROW LEVEL SECURITY;",
+		"ALTER TABLE " + table + " FORCE ROW LEVEL SECURITY;",
+		"DO $$ BEGIN " +
+			"IF EXISTS (SELECT 1 FROM pg_policies " +
+			"WHERE tablename = '" + table + "' AND policyname = '" + tenantPolicy + "' " +
+			"AND qual NOT LIKE '%" + systemSetting + "%') THEN " +
+			"DROP POLICY " + tenantPolicy + " ON " + table + "; " +
+			"END IF; END $$;",
+		"DO $$ BEGIN " +
+			"IF NOT EXISTS (SELECT 1 FROM pg_policies " +
+			"WHERE tablename = '" + table + "' AND policyname = '" + tenantPolicy + "') THEN " +
+			"CREATE POLICY " + tenantPolicy + " ON " + table + " USING (" + check + ") WITH CHECK (" + check + "); " +
+			"END IF; END $$;",
+	}
+}
+
+// tenantIndexMigrations returns the migrations that replace a unique index created before the service was
+// multi-tenant with one that starts with the tenant, so that the values are unique within each tenant.
+func tenantIndexMigrations(index string, table string, columns string, where string) []string {
+	return []string{
+		"DO $$ BEGIN " +
+			"IF EXISTS (SELECT 1 FROM pg_indexes WHERE indexname = '" + index + "' " +
+			"AND indexdef NOT LIKE '%tenant_id%') THEN " +
+			"DROP INDEX " + index + "; " +
+			"END IF; END $$;",
+		"CREATE UNIQUE INDEX IF NOT EXISTS " + index + " ON " + table + " (tenant_id, " + columns + ") " +
+			"WHERE " + where + ";",
+	}
+}
+
+// whereTenant restricts the query to the records of the tenant.
+func whereTenant(query *bun.SelectQuery, tenantID string) *bun.SelectQuery {
+	return query.Where("? = ?", bun.Ident("tenant_id"), tenantID)
+}
+
+// runInTenantTx runs fn in a transaction bound to the tenant. The row-level security policies
+// let the transaction read and write only the rows of the tenant, in addition to the checks of the handlers.
+// Every query of a request runs in such a transaction, reads included.
+func runInTenantTx(ctx context.Context, db *bun.DB, tenantID string,
+	fn func(ctx context.Context, tx bun.Tx) error) error {
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewRaw("SELECT set_config(?, ?, true)", tenantSetting, tenantID).Exec(ctx); err != nil {
+			return err
+		}
+		return fn(ctx, tx)
+	})
+}
+
+// lockInTenant takes a transaction-level advisory lock of the key within the namespace and the tenant.
+// The first key of the lock is the hash of the namespace and the tenant, so that locks taken for different
+// purposes or in different tenants don't share keys. Namespaces don't contain a colon, which separates them
+// from the tenant.
+func lockInTenant(ctx context.Context, db bun.IDB, namespace string, tenantID string, key any) error {
+	_, err := db.NewRaw("SELECT pg_advisory_xact_lock(hashtext(?), ?)", namespace+":"+tenantID, key).Exec(ctx)
+	return err
+}
+
+// runInSystemTx runs fn in a transaction that sees the rows of all tenants. It is reserved
+// for the migrations and for the background jobs that work across tenants, never for requests.
+func runInSystemTx(ctx context.Context, db *bun.DB, fn func(ctx context.Context, tx bun.Tx) error) error {
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewRaw("SELECT set_config(?, 'on', true)", systemSetting).Exec(ctx); err != nil {
+			return err
+		}
+		return fn(ctx, tx)
+	})
+}
@@ -0,0 +1,429 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	ppb "github.com/TekClinic/Appointments-MicroService/appointments_protobuf"
+	"github.com/uptrace/bun"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestTokenTenant(t *testing.T) {
+	if _, err := (tokenIdentity{}).tenant(); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("tenant() of a token without tenant_id = %v, want Unauthenticated", err)
+	}
+	if tenantID, err := (tokenIdentity{TenantID: "clinic"}).tenant(); err != nil || tenantID != "clinic" {
+		t.Errorf("tenant() = %q, %v, want clinic", tenantID, err)
+	}
+}
+
+// tenantRecords are the IDs of the records created by createTenantRecords.
+type tenantRecords struct {
+	appointment     int32
+	resource        int32
+	location        int32
+	appointmentType int32
+	waitlistEntry   int32
+	hold            int32
+}
+
+// tenantAppointmentVersion is the version of the appointment created by createTenantRecords,
+// which is confirmed once after it is created.
+const tenantAppointmentVersion = 2
+
+// tenantSlot is the time of the records created by createTenantRecords, during the hours of testSchedule.
+func tenantSlot() time.Time {
+	day := time.Now().UTC().Truncate(24 * time.Hour).Add(7 * 24 * time.Hour)
+	return day.Add(10 * time.Hour)
+}
+
+// createTestSchedule lets the doctor work from 08:00 to 18:00 UTC every day.
+func createTestSchedule(t *testing.T, server appointmentsServer, token string, doctorID int32) {
+	t.Helper()
+	hours := make([]*ppb.WeeklyHours, 0, 7)
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		hours = append(hours, &ppb.WeeklyHours{Weekday: int32(weekday), StartTime: "08:00", EndTime: "18:00"})
+	}
+	_, err := server.CreateSchedule(context.Background(), &ppb.CreateScheduleRequest{
+		Token: token, DoctorId: doctorID, Timezone: "UTC", WeeklyHours: hours})
+	if err != nil {
+		t.Fatalf("failed to create a schedule: %v", err)
+	}
+}
+
+// createTenantRecords creates an appointment, a resource, a location, an appointment type, a waitlist entry
+// and a hold in the tenant of the token.
+func createTenantRecords(t *testing.T, server appointmentsServer, token string) tenantRecords {
+	t.Helper()
+	ctx := context.Background()
+	start := tenantSlot()
+	var records tenantRecords
+
+	createTestSchedule(t, server, token, 1)
+	records.appointment = createTestAppointment(t, server, token, 1, 1, start, start.Add(time.Hour))
+
+	// The appointment is confirmed with an idempotency key, which records a status change and the key.
+	_, err := server.idempotencyInterceptor(ctx,
+		&ppb.ConfirmAppointmentRequest{Token: token, Id: records.appointment, IdempotencyKey: "confirm"},
+		&grpc.UnaryServerInfo{FullMethod: "/appointments.AppointmentsService/ConfirmAppointment"},
+		func(ctx context.Context, req any) (any, error) {
+			return server.ConfirmAppointment(ctx, req.(*ppb.ConfirmAppointmentRequest))
+		})
+	if err != nil {
+		t.Fatalf("failed to confirm an appointment: %v", err)
+	}
+
+	resource, err := server.CreateResource(ctx, &ppb.CreateResourceRequest{
+		Token: token, Name: "Room", Kind: ppb.ResourceKind_RESOURCE_KIND_ROOM})
+	if err != nil {
+		t.Fatalf("failed to create a resource: %v", err)
+	}
+	records.resource = resource.GetId()
+
+	location, err := server.CreateLocation(ctx, &ppb.CreateLocationRequest{Token: token, Name: "Main", Timezone: "UTC"})
+	if err != nil {
+		t.Fatalf("failed to create a location: %v", err)
+	}
+	records.location = location.GetId()
+
+	appointmentType, err := server.CreateAppointmentType(ctx, &ppb.CreateAppointmentTypeRequest{
+		Token: token, Name: "Checkup", DurationMinutes: 30})
+	if err != nil {
+		t.Fatalf("failed to create an appointment type: %v", err)
+	}
+	records.appointmentType = appointmentType.GetId()
+
+	entry, err := server.CreateWaitlistEntry(ctx, &ppb.CreateWaitlistEntryRequest{
+		Token: token, PatientId: 2, DoctorId: 1, Windows: []*ppb.WaitlistWindow{{
+			StartTime: start.Format(time.RFC3339), EndTime: start.Add(8 * time.Hour).Format(time.RFC3339)}}})
+	if err != nil {
+		t.Fatalf("failed to create a waitlist entry: %v", err)
+	}
+	records.waitlistEntry = entry.GetId()
+
+	holdStart := start.Add(2 * time.Hour)
+	hold, err := server.HoldSlot(ctx, &ppb.HoldSlotRequest{Token: token, DoctorId: 1,
+		StartTime: holdStart.Format(time.RFC3339), EndTime: holdStart.Add(time.Hour).Format(time.RFC3339)})
+	if err != nil {
+		t.Fatalf("failed to hold a slot: %v", err)
+	}
+	records.hold = hold.GetId()
+	return records
+}
+
+func TestTenantIsolation(t *testing.T) {
+	server := newTestServer(t, testDB(t))
+	ctx := context.Background()
+	owner := adminToken(t, testTenant(t))
+	other := adminToken(t, testTenant(t))
+	records := createTenantRecords(t, server, owner)
+	start := tenantSlot()
+
+	// Every record of the owner is reported as missing to the other tenant, and is left unchanged.
+	notFound := []struct {
+		name string
+		call func(ctx context.Context) error
+	}{
+		{"GetAppointment", func(ctx context.Context) error {
+			_, err := server.GetAppointment(ctx, &ppb.GetAppointmentRequest{Token: other, Id: records.appointment})
+			return err
+		}},
+		{"UpdateAppointment", func(ctx context.Context) error {
+			_, err := server.UpdateAppointment(ctx, &ppb.UpdateAppointmentRequest{Token: other, Id: records.appointment,
+				PatientId: 3, DoctorId: 1, StartTime: start.Format(time.RFC3339),
+				EndTime: start.Add(time.Hour).Format(time.RFC3339), IgnoreSchedule: true,
+				ExpectedVersion: tenantAppointmentVersion})
+			return err
+		}},
+		{"AssignPatient", func(ctx context.Context) error {
+			_, err := server.AssignPatient(ctx, &ppb.AssignPatientRequest{
+				Token: other, Id: records.appointment, PatientId: 3, ExpectedVersion: tenantAppointmentVersion})
+			return err
+		}},
+		{"RemovePatient", func(ctx context.Context) error {
+			_, err := server.RemovePatient(ctx, &ppb.RemovePatientRequest{
+				Token: other, Id: records.appointment, ExpectedVersion: tenantAppointmentVersion})
+			return err
+		}},
+		{"DeleteAppointment", func(ctx context.Context) error {
+			_, err := server.DeleteAppointment(ctx, &ppb.DeleteAppointmentRequest{
+				Token: other, Id: records.appointment, ExpectedVersion: tenantAppointmentVersion})
+			return err
+		}},
+		{"GetAppointmentHistory", func(ctx context.Context) error {
+			_, err := server.GetAppointmentHistory(ctx, &ppb.GetAppointmentHistoryRequest{
+				Token: other, Id: records.appointment, Limit: 10})
+			return err
+		}},
+		{"GetResource", func(ctx context.Context) error {
+			_, err := server.GetResource(ctx, &ppb.GetResourceRequest{Token: other, Id: records.resource})
+			return err
+		}},
+		{"UpdateResource", func(ctx context.Context) error {
+			_, err := server.UpdateResource(ctx, &ppb.UpdateResourceRequest{Token: other, Id: records.resource,
+				Name: "Renamed", Kind: ppb.ResourceKind_RESOURCE_KIND_ROOM})
+			return err
+		}},
+		{"DeleteResource", func(ctx context.Context) error {
+			_, err := server.DeleteResource(ctx, &ppb.DeleteResourceRequest{Token: other, Id: records.resource})
+			return err
+		}},
+		{"GetLocation", func(ctx context.Context) error {
+			_, err := server.GetLocation(ctx, &ppb.GetLocationRequest{Token: other, Id: records.location})
+			return err
+		}},
+		{"UpdateLocation", func(ctx context.Context) error {
+			_, err := server.UpdateLocation(ctx, &ppb.UpdateLocationRequest{Token: other, Id: records.location,
+				Name: "Renamed", Timezone: "UTC"})
+			return err
+		}},
+		{"DeleteLocation", func(ctx context.Context) error {
+			_, err := server.DeleteLocation(ctx, &ppb.DeleteLocationRequest{Token: other, Id: records.location})
+			return err
+		}},
+		{"GetAppointmentType", func(ctx context.Context) error {
+			_, err := server.GetAppointmentType(ctx, &ppb.GetAppointmentTypeRequest{
+				Token: other, Id: records.appointmentType})
+			return err
+		}},
+		{"UpdateAppointmentType", func(ctx context.Context) error {
+			_, err := server.UpdateAppointmentType(ctx, &ppb.UpdateAppointmentTypeRequest{
+				Token: other, Id: records.appointmentType, Name: "Renamed", DurationMinutes: 30})
+			return err
+		}},
+		{"DeleteAppointmentType", func(ctx context.Context) error {
+			_, err := server.DeleteAppointmentType(ctx, &ppb.DeleteAppointmentTypeRequest{
+				Token: other, Id: records.appointmentType})
+			return err
+		}},
+		{"GetWaitlistEntry", func(ctx context.Context) error {
+			_, err := server.GetWaitlistEntry(ctx, &ppb.GetWaitlistEntryRequest{Token: other, Id: records.waitlistEntry})
+			return err
+		}},
+		{"DeleteWaitlistEntry", func(ctx context.Context) error {
+			_, err := server.DeleteWaitlistEntry(ctx, &ppb.DeleteWaitlistEntryRequest{
+				Token: other, Id: records.waitlistEntry})
+			return err
+		}},
+		{"ConfirmHold", func(ctx context.Context) error {
+			_, err := server.ConfirmHold(ctx, &ppb.ConfirmHoldRequest{Token: other, Id: records.hold, PatientId: 3})
+			return err
+		}},
+	}
+	for _, test := range notFound {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.call(ctx); status.Code(err) != codes.NotFound {
+				t.Errorf("code = %v, want NotFound (%v)", status.Code(err), err)
+			}
+		})
+	}
+
+	// The other tenant has no records of its own, so its lists are empty.
+	lists := []struct {
+		name string
+		call func(ctx context.Context) (int32, error)
+	}{
+		{"GetAppointments", func(ctx context.Context) (int32, error) {
+			resp, err := server.GetAppointments(ctx, &ppb.GetAppointmentsRequest{Token: other, Limit: 10})
+			return resp.GetCount(), err
+		}},
+		{"ListAppointments", func(ctx context.Context) (int32, error) {
+			resp, err := server.ListAppointments(ctx, &ppb.ListAppointmentsRequest{Token: other, Limit: 10})
+			return resp.GetCount(), err
+		}},
+		{"ListResources", func(ctx context.Context) (int32, error) {
+			resp, err := server.ListResources(ctx, &ppb.ListResourcesRequest{Token: other, Limit: 10})
+			return resp.GetCount(), err
+		}},
+		{"ListLocations", func(ctx context.Context) (int32, error) {
+			resp, err := server.ListLocations(ctx, &ppb.ListLocationsRequest{Token: other, Limit: 10})
+			return resp.GetCount(), err
+		}},
+		{"ListAppointmentTypes", func(ctx context.Context) (int32, error) {
+			resp, err := server.ListAppointmentTypes(ctx, &ppb.ListAppointmentTypesRequest{Token: other, Limit: 10})
+			return resp.GetCount(), err
+		}},
+		{"ListWaitlistEntries", func(ctx context.Context) (int32, error) {
+			resp, err := server.ListWaitlistEntries(ctx, &ppb.ListWaitlistEntriesRequest{Token: other, Limit: 10})
+			return resp.GetCount(), err
+		}},
+		{"ListAuditEvents", func(ctx context.Context) (int32, error) {
+			resp, err := server.ListAuditEvents(ctx, &ppb.ListAuditEventsRequest{Token: other, Limit: 10})
+			return resp.GetCount(), err
+		}},
+	}
+	for _, test := range lists {
+		t.Run(test.name, func(t *testing.T) {
+			count, err := test.call(ctx)
+			if err != nil {
+				t.Fatalf("failed to list: %v", err)
+			}
+			if count != 0 {
+				t.Errorf("count = %d, want 0", count)
+			}
+		})
+	}
+
+	// The hold of the owner doesn't block the same slot in the other tenant.
+	createTestSchedule(t, server, other, 1)
+	holdStart := start.Add(2 * time.Hour)
+	_, err := server.HoldSlot(ctx, &ppb.HoldSlotRequest{Token: other, DoctorId: 1,
+		StartTime: holdStart.Format(time.RFC3339), EndTime: holdStart.Add(time.Hour).Format(time.RFC3339)})
+	if err != nil {
+		t.Errorf("failed to hold the slot held by another tenant: %v", err)
+	}
+
+	appointment, err := server.GetAppointment(ctx, &ppb.GetAppointmentRequest{Token: owner, Id: records.appointment})
+	if err != nil {
+		t.Fatalf("failed to fetch the appointment of the owner: %v", err)
+	}
+	if appointment.GetPatientId() != 1 || appointment.GetDeleted() {
+		t.Errorf("appointment of the owner was changed by another tenant: %v", appointment)
+	}
+	if changes := appointment.GetStatusChanges(); len(changes) != 1 {
+		t.Errorf("status changes of the owner = %v, want the confirmation only", changes)
+	}
+	resource, err := server.GetResource(ctx, &ppb.GetResourceRequest{Token: owner, Id: records.resource})
+	if err != nil || resource.GetResource().GetName() != "Room" {
+		t.Errorf("resource of the owner = %v, %v, want unchanged", resource, err)
+	}
+	location, err := server.GetLocation(ctx, &ppb.GetLocationRequest{Token: owner, Id: records.location})
+	if err != nil || location.GetLocation().GetName() != "Main" {
+		t.Errorf("location of the owner = %v, %v, want unchanged", location, err)
+	}
+	appointmentType, err := server.GetAppointmentType(ctx, &ppb.GetAppointmentTypeRequest{
+		Token: owner, Id: records.appointmentType})
+	if err != nil || appointmentType.GetAppointmentType().GetName() != "Checkup" {
+		t.Errorf("appointment type of the owner = %v, %v, want unchanged", appointmentType, err)
+	}
+	entry, err := server.GetWaitlistEntry(ctx, &ppb.GetWaitlistEntryRequest{Token: owner, Id: records.waitlistEntry})
+	if err != nil || entry.GetEntry().GetDeleted() {
+		t.Errorf("waitlist entry of the owner = %v, %v, want unchanged", entry, err)
+	}
+}
+
+func TestTenantFilters(t *testing.T) {
+	server := newTestServer(t, testDB(t))
+	ctx := context.Background()
+	records := createTenantRecords(t, server, adminToken(t, testTenant(t)))
+	other := access{tenantID: testTenant(t), all: true}
+
+	// The system transaction bypasses row-level security, so only the filters of the queries hide the records.
+	lookups := []struct {
+		name  string
+		fetch func(ctx context.Context, tx bun.Tx) error
+	}{
+		{"appointment", func(ctx context.Context, tx bun.Tx) error {
+			_, err := fetchAppointment(ctx, tx, other, records.appointment, 0)
+			return err
+		}},
+		{"resource", func(ctx context.Context, tx bun.Tx) error {
+			_, err := fetchResource(ctx, tx, other.tenantID, records.resource)
+			return err
+		}},
+		{"location", func(ctx context.Context, tx bun.Tx) error {
+			_, err := fetchLocation(ctx, tx, other.tenantID, records.location)
+			return err
+		}},
+		{"appointment type", func(ctx context.Context, tx bun.Tx) error {
+			_, err := fetchAppointmentType(ctx, tx, other.tenantID, records.appointmentType)
+			return err
+		}},
+		{"waitlist entry", func(ctx context.Context, tx bun.Tx) error {
+			_, err := fetchWaitlistEntry(ctx, tx, other.tenantID, records.waitlistEntry)
+			return err
+		}},
+		{"hold", func(ctx context.Context, tx bun.Tx) error {
+			err := whereTenant(tx.NewSelect().Model(new(SlotHold)), other.tenantID).
+				Where("? = ?", bun.Ident("id"), records.hold).
+				Scan(ctx)
+			return dbError(err, "failed to fetch a hold by id")
+		}},
+	}
+	for _, test := range lookups {
+		t.Run(test.name, func(t *testing.T) {
+			err := runInSystemTx(ctx, server.db, test.fetch)
+			if status.Code(err) != codes.NotFound {
+				t.Errorf("code = %v, want NotFound (%v)", status.Code(err), err)
+			}
+		})
+	}
+}
+
+func TestRowLevelSecurity(t *testing.T) {
+	server := newTestServer(t, testDB(t))
+	ctx := context.Background()
+	owner := testTenant(t)
+	createTenantRecords(t, server, adminToken(t, owner))
+
+	// countRows counts the rows of the owner in the table, without filtering the rows of other tenants in Go.
+	countRows := func(ctx context.Context, db bun.IDB, table string) (int, error) {
+		var count int
+		err := db.NewRaw("SELECT count(*) FROM ? WHERE ? = ?", bun.Ident(table), bun.Ident("tenant_id"), owner).
+			Scan(ctx, &count)
+		return count, err
+	}
+	owned := []string{
+		"appointments", "schedules", "appointment_types", "resources", "locations",
+		"audit_events", "appointment_events", "waitlist_entries", "slot_holds", "status_changes", "outbox_messages",
+		"idempotency_keys",
+	}
+	for _, table := range owned {
+		err := runInTenantTx(ctx, server.db, owner, func(ctx context.Context, tx bun.Tx) error {
+			count, err := countRows(ctx, tx, table)
+			if err == nil && count == 0 {
+				t.Errorf("the owner sees no rows of %s", table)
+			}
+			return err
+		})
+		if err != nil {
+			t.Fatalf("failed to count the rows of %s: %v", table, err)
+		}
+	}
+
+	// A connection that is not bound to the owner's tenant sees none of its rows.
+	bindings := []struct {
+		name string
+		run  func(ctx context.Context, fn func(ctx context.Context, db bun.IDB) error) error
+	}{
+		{"unset", func(ctx context.Context, fn func(ctx context.Context, db bun.IDB) error) error {
+			return fn(ctx, server.db)
+		}},
+		{"empty", func(ctx context.Context, fn func(ctx context.Context, db bun.IDB) error) error {
+			return runInTenantTx(ctx, server.db, "", func(ctx context.Context, tx bun.Tx) error { return fn(ctx, tx) })
+		}},
+		{"other tenant", func(ctx context.Context, fn func(ctx context.Context, db bun.IDB) error) error {
+			return runInTenantTx(ctx, server.db, testTenant(t),
+				func(ctx context.Context, tx bun.Tx) error { return fn(ctx, tx) })
+		}},
+	}
+	for _, binding := range bindings {
+		t.Run(binding.name, func(t *testing.T) {
+			for _, table := range tenantTables() {
+				err := binding.run(ctx, func(ctx context.Context, db bun.IDB) error {
+					count, err := countRows(ctx, db, table)
+					if err == nil && count != 0 {
+						t.Errorf("%d rows of %s are visible", count, table)
+					}
+					return err
+				})
+				if err != nil {
+					t.Fatalf("failed to count the rows of %s: %v", table, err)
+				}
+			}
+		})
+	}
+
+	// A transaction of another tenant can't write rows of the owner.
+	err := runInTenantTx(ctx, server.db, testTenant(t), func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewInsert().Model(&Location{TenantID: owner, Name: "Foreign", Timezone: "UTC"}).Exec(ctx)
+		return err
+	})
+	if err == nil {
+		t.Error("a transaction of another tenant inserted a row of the owner")
+	}
+}
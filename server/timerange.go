@@ -0,0 +1,100 @@
+package main
+
+import (
+	"slices"
+	"time"
+
+	"github.com/uptrace/bun/schema"
+)
+
+// timeRange is a half-open [Start, End) interval of time.
+// All scheduling checks treat appointments as time ranges, so back-to-back appointments do not overlap.
+type timeRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+// overlaps reports whether two time ranges share at least one instant.
+func (r timeRange) overlaps(other timeRange) bool {
+	return r.Start.Before(other.End) && other.Start.Before(r.End)
+}
+
+// contains reports whether other lies entirely within r.
+func (r timeRange) contains(other timeRange) bool {
+	return !other.Start.Before(r.Start) && !other.End.After(r.End)
+}
+
+// clip returns the part of r that lies within bounds. The result is empty if r and bounds do not overlap.
+func (r timeRange) clip(bounds timeRange) timeRange {
+	if r.Start.Before(bounds.Start) {
+		r.Start = bounds.Start
+	}
+	if r.End.After(bounds.End) {
+		r.End = bounds.End
+	}
+	return r
+}
+
+// empty reports whether r contains no instants.
+func (r timeRange) empty() bool {
+	return !r.Start.Before(r.End)
+}
+
+// mergeRanges sorts the ranges by start and joins ranges that overlap or touch. Empty ranges are dropped.
+func mergeRanges(ranges []timeRange) []timeRange {
+	sorted := slices.Clone(ranges)
+	slices.SortFunc(sorted, func(a, b timeRange) int {
+		return a.Start.Compare(b.Start)
+	})
+
+	merged := make([]timeRange, 0, len(sorted))
+	for _, r := range sorted {
+		if r.empty() {
+			continue
+		}
+		if last := len(merged) - 1; last >= 0 && !r.Start.After(merged[last].End) {
+			if r.End.After(merged[last].End) {
+				merged[last].End = r.End
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// subtractRanges returns the parts of ranges that do not overlap any of the cuts, merged and sorted by start.
+func subtractRanges(ranges []timeRange, cuts []timeRange) []timeRange {
+	result := mergeRanges(ranges)
+	for _, cut := range mergeRanges(cuts) {
+		next := make([]timeRange, 0, len(result)+1)
+		for _, r := range result {
+			if !r.overlaps(cut) {
+				next = append(next, r)
+				continue
+			}
+			if before := (timeRange{Start: r.Start, End: cut.Start}); !before.empty() {
+				next = append(next, before)
+			}
+			if after := (timeRange{Start: cut.End, End: r.End}); !after.empty() {
+				next = append(next, after)
+			}
+		}
+		result = next
+	}
+	return result
+}
+
+// coveredBy reports whether r lies entirely within one of the ranges.
+// The ranges are merged first, so r may span several adjacent ranges.
+func (r timeRange) coveredBy(ranges []timeRange) bool {
+	return slices.ContainsFunc(mergeRanges(ranges), func(candidate timeRange) bool {
+		return candidate.contains(r)
+	})
+}
+
+// nowPlus returns an SQL expression of the database time d from now. Deadlines that are compared with now()
+// in queries are computed by the database as well, so that the clocks of the replicas don't shift them.
+func nowPlus(d time.Duration) schema.QueryWithArgs {
+	return schema.SafeQuery("now() + ? * interval '1 microsecond'", []any{d.Microseconds()})
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	ms "github.com/TekClinic/MicroService-Lib"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+)
+
+// Environment variables that configure the booking rules of the clinic. Each of them is a duration, e.g. 15m.
+const (
+	envBookingMinDuration = "BOOKING_MIN_DURATION"
+	envBookingMaxDuration = "BOOKING_MAX_DURATION"
+	envBookingGranularity = "BOOKING_GRANULARITY"
+	envBookingMinLeadTime = "BOOKING_MIN_LEAD_TIME"
+	envBookingMaxHorizon  = "BOOKING_MAX_HORIZON"
+
+	defaultBookingMinDuration = 5 * time.Minute
+	defaultBookingMaxDuration = 8 * time.Hour
+	defaultBookingGranularity = 5 * time.Minute
+	defaultBookingMaxHorizon  = 365 * 24 * time.Hour
+)
+
+// bookingRules are the constraints on the time of a booked appointment. A zero limit is not checked.
+//   - minDuration and maxDuration bound the length of the appointment;
+//   - granularity is the step the start time and the length are aligned to, e.g. 5 minutes,
+//     where start times are counted from midnight UTC;
+//   - minLeadTime is how long in advance an appointment has to be booked,
+//     so appointments in the past are never accepted;
+//   - maxHorizon is how far in the future an appointment can be booked.
+type bookingRules struct {
+	minDuration time.Duration
+	maxDuration time.Duration
+	granularity time.Duration
+	minLeadTime time.Duration
+	maxHorizon  time.Duration
+}
+
+// parseBookingRules reads the booking rules of the clinic from the environment.
+func parseBookingRules() (bookingRules, error) {
+	var rules bookingRules
+	for _, setting := range []struct {
+		env      string
+		fallback time.Duration
+		target   *time.Duration
+	}{
+		{envBookingMinDuration, defaultBookingMinDuration, &rules.minDuration},
+		{envBookingMaxDuration, defaultBookingMaxDuration, &rules.maxDuration},
+		{envBookingGranularity, defaultBookingGranularity, &rules.granularity},
+		{envBookingMinLeadTime, 0, &rules.minLeadTime},
+		{envBookingMaxHorizon, defaultBookingMaxHorizon, &rules.maxHorizon},
+	} {
+		value, err := time.ParseDuration(ms.GetOptionalEnv(setting.env, setting.fallback.String()))
+		if err != nil {
+			return bookingRules{}, fmt.Errorf("failed to parse %s: %w", setting.env, err)
+		}
+		if value < 0 {
+			return bookingRules{}, fmt.Errorf("%s can't be negative", setting.env)
+		}
+		*setting.target = value
+	}
+	if rules.maxDuration != 0 && rules.minDuration > rules.maxDuration {
+		return bookingRules{}, fmt.Errorf("%s can't be greater than %s", envBookingMinDuration, envBookingMaxDuration)
+	}
+	return rules, nil
+}
+
+// violations returns the rules broken by an appointment at r. The lead time and the horizon are only checked
+// if the start time is new, so that an appointment that already started can still be changed otherwise.
+func (rules bookingRules) violations(r timeRange, now time.Time,
+	startChanged bool) []*errdetails.BadRequest_FieldViolation {
+	var violations []*errdetails.BadRequest_FieldViolation
+	add := func(field string, format string, args ...any) {
+		violations = append(violations,
+			&errdetails.BadRequest_FieldViolation{Field: field, Description: fmt.Sprintf(format, args...)})
+	}
+
+	duration := r.End.Sub(r.Start)
+	switch {
+	case duration <= 0:
+		// The other rules are meaningless for an empty or inverted range.
+		add("end_time", "end time has to be after start time")
+		return violations
+	case rules.minDuration != 0 && duration < rules.minDuration:
+		add("end_time", "appointment has to be at least %s long", rules.minDuration)
+	case rules.maxDuration != 0 && duration > rules.maxDuration:
+		add("end_time", "appointment can be at most %s long", rules.maxDuration)
+	}
+
+	if rules.granularity != 0 {
+		if !alignUp(r.Start, rules.granularity).Equal(r.Start) {
+			add("start_time", "start time has to be aligned to %s", rules.granularity)
+		}
+		if duration%rules.granularity != 0 {
+			add("end_time", "appointment length has to be a multiple of %s", rules.granularity)
+		}
+	}
+
+	if startChanged {
+		switch {
+		case r.Start.Before(now.Add(rules.minLeadTime)) && rules.minLeadTime == 0:
+			add("start_time", "appointment can't be booked in the past")
+		case r.Start.Before(now.Add(rules.minLeadTime)):
+			add("start_time", "appointment has to be booked at least %s in advance", rules.minLeadTime)
+		case rules.maxHorizon != 0 && r.Start.After(now.Add(rules.maxHorizon)):
+			add("start_time", "appointment can be booked at most %s in advance", rules.maxHorizon)
+		}
+	}
+	return violations
+}
+
+// bookableRange returns the part of r in which slots of the given duration start neither before the lead time
+// nor after the horizon at now. The returned range is empty if no slot can be booked.
+func (rules bookingRules) bookableRange(r timeRange, duration time.Duration, now time.Time) timeRange {
+	if earliest := now.Add(rules.minLeadTime); r.Start.Before(earliest) {
+		r.Start = earliest
+	}
+	if rules.maxHorizon != 0 {
+		if latest := now.Add(rules.maxHorizon + duration); r.End.After(latest) {
+			r.End = latest
+		}
+	}
+	return r
+}
+
+// checkSlotSearch returns codes.InvalidArgument if slots of the given duration, starting at multiples
+// of granularity, break the rules, so that every slot found by FindAvailableSlots can be booked.
+func (rules bookingRules) checkSlotSearch(duration time.Duration, granularity time.Duration) error {
+	switch {
+	case rules.minDuration != 0 && duration < rules.minDuration:
+		return invalidArgumentError("duration_minutes", fmt.Sprintf("slots have to be at least %s long", rules.minDuration))
+	case rules.maxDuration != 0 && duration > rules.maxDuration:
+		return invalidArgumentError("duration_minutes", fmt.Sprintf("slots can be at most %s long", rules.maxDuration))
+	case rules.granularity != 0 && duration%rules.granularity != 0:
+		return invalidArgumentError("duration_minutes",
+			fmt.Sprintf("slot length has to be a multiple of %s", rules.granularity))
+	case rules.granularity != 0 && granularity%rules.granularity != 0:
+		return invalidArgumentError("granularity_minutes",
+			fmt.Sprintf("granularity has to be a multiple of %s", rules.granularity))
+	default:
+		return nil
+	}
+}
+
+// alignUp returns the first time at or after t that is a multiple of granularity counted from midnight UTC.
+// Start times are aligned in UTC both by the booking rules and by FindAvailableSlots, so that the found slots
+// are aligned whatever the timezone of the doctor's schedule.
+func alignUp(t time.Time, granularity time.Duration) time.Time {
+	utc := t.UTC()
+	midnight := time.Date(utc.Year(), utc.Month(), utc.Day(), 0, 0, 0, 0, time.UTC)
+	offset := utc.Sub(midnight)
+	if remainder := offset % granularity; remainder != 0 {
+		offset += granularity - remainder
+	}
+	return midnight.Add(offset).In(t.Location())
+}
+
+// validate returns codes.InvalidArgument with a BadRequest detail listing the broken rules, if any.
+// See violations for the meaning of startChanged.
+func (rules bookingRules) validate(r timeRange, startChanged bool) error {
+	if violations := rules.violations(r, time.Now(), startChanged); len(violations) > 0 {
+		return badRequestError(violations)
+	}
+	return nil
+}
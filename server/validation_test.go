@@ -0,0 +1,139 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAlignUp(t *testing.T) {
+	india := time.FixedZone("IST", 5*60*60+30*60)
+	tests := []struct {
+		name        string
+		t           time.Time
+		granularity time.Duration
+		want        time.Time
+	}{
+		{"aligned", time.Date(2030, 1, 7, 9, 15, 0, 0, time.UTC), 15 * time.Minute,
+			time.Date(2030, 1, 7, 9, 15, 0, 0, time.UTC)},
+		{"rounded up", time.Date(2030, 1, 7, 9, 16, 0, 0, time.UTC), 15 * time.Minute,
+			time.Date(2030, 1, 7, 9, 30, 0, 0, time.UTC)},
+		{"seconds", time.Date(2030, 1, 7, 9, 15, 1, 0, time.UTC), 5 * time.Minute,
+			time.Date(2030, 1, 7, 9, 20, 0, 0, time.UTC)},
+		{"next day", time.Date(2030, 1, 7, 23, 50, 0, 0, time.UTC), time.Hour,
+			time.Date(2030, 1, 8, 0, 0, 0, 0, time.UTC)},
+		{"counted from midnight", time.Date(2030, 1, 7, 0, 1, 0, 0, time.UTC), 7 * time.Minute,
+			time.Date(2030, 1, 7, 0, 7, 0, 0, time.UTC)},
+		{"other timezone", time.Date(2030, 1, 7, 15, 0, 0, 0, india), time.Hour,
+			time.Date(2030, 1, 7, 10, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := alignUp(test.t, test.granularity); !got.Equal(test.want) {
+				t.Errorf("alignUp(%v, %v) = %v, want %v", test.t, test.granularity, got, test.want)
+			}
+		})
+	}
+}
+
+func TestCheckSlotSearch(t *testing.T) {
+	rules := bookingRules{minDuration: 10 * time.Minute, maxDuration: 2 * time.Hour, granularity: 5 * time.Minute}
+	tests := []struct {
+		name        string
+		rules       bookingRules
+		duration    time.Duration
+		granularity time.Duration
+		code        codes.Code
+	}{
+		{"valid", rules, 30 * time.Minute, 15 * time.Minute, codes.OK},
+		{"too short", rules, 5 * time.Minute, 5 * time.Minute, codes.InvalidArgument},
+		{"too long", rules, 3 * time.Hour, 15 * time.Minute, codes.InvalidArgument},
+		{"unaligned duration", rules, 12 * time.Minute, 15 * time.Minute, codes.InvalidArgument},
+		{"unaligned granularity", rules, 30 * time.Minute, 7 * time.Minute, codes.InvalidArgument},
+		{"no limits", bookingRules{}, 7 * time.Minute, 3 * time.Minute, codes.OK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.rules.checkSlotSearch(test.duration, test.granularity)
+			if code := status.Code(err); code != test.code {
+				t.Errorf("checkSlotSearch() code = %v, want %v (%v)", code, test.code, err)
+			}
+		})
+	}
+}
+
+func TestBookingRulesViolations(t *testing.T) {
+	now := time.Date(2030, 1, 7, 8, 0, 0, 0, time.UTC)
+	at := func(hour int, minute int) time.Time {
+		return time.Date(2030, 1, 7, hour, minute, 0, 0, time.UTC)
+	}
+	rules := bookingRules{
+		minDuration: 10 * time.Minute,
+		maxDuration: 2 * time.Hour,
+		granularity: 5 * time.Minute,
+		minLeadTime: time.Hour,
+		maxHorizon:  30 * 24 * time.Hour,
+	}
+	tests := []struct {
+		name         string
+		rules        bookingRules
+		r            timeRange
+		startChanged bool
+		want         []string
+	}{
+		{"valid", rules, timeRange{Start: at(10, 0), End: at(10, 30)}, true, nil},
+		{"inverted", rules, timeRange{Start: at(10, 0), End: at(9, 0)}, true, []string{"end_time"}},
+		{"too short", rules, timeRange{Start: at(10, 0), End: at(10, 5)}, true, []string{"end_time"}},
+		{"too long", rules, timeRange{Start: at(10, 0), End: at(13, 0)}, true, []string{"end_time"}},
+		{"unaligned start", rules, timeRange{Start: at(10, 2), End: at(10, 32)}, true, []string{"start_time"}},
+		{"unaligned length", rules, timeRange{Start: at(10, 0), End: at(10, 32)}, true, []string{"end_time"}},
+		{"lead time", rules, timeRange{Start: at(8, 30), End: at(9, 0)}, true, []string{"start_time"}},
+		{"past", bookingRules{}, timeRange{Start: at(7, 0), End: at(7, 30)}, true, []string{"start_time"}},
+		{"horizon", rules, timeRange{Start: at(10, 0).AddDate(0, 1, 0), End: at(10, 30).AddDate(0, 1, 0)}, true,
+			[]string{"start_time"}},
+		{"start unchanged", rules, timeRange{Start: at(7, 0), End: at(7, 30)}, false, nil},
+		{"several", rules, timeRange{Start: at(8, 2), End: at(8, 5)}, true,
+			[]string{"end_time", "start_time", "end_time", "start_time"}},
+		{"no limits", bookingRules{}, timeRange{Start: at(10, 2), End: at(10, 3)}, true, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var fields []string
+			for _, violation := range test.rules.violations(test.r, now, test.startChanged) {
+				fields = append(fields, violation.GetField())
+			}
+			if !slices.Equal(fields, test.want) {
+				t.Errorf("violations() fields = %v, want %v", fields, test.want)
+			}
+		})
+	}
+}
+
+func TestBookableRange(t *testing.T) {
+	now := time.Date(2030, 1, 7, 8, 0, 0, 0, time.UTC)
+	day := timeRange{Start: now.Add(-time.Hour), End: now.Add(24 * time.Hour)}
+	tests := []struct {
+		name  string
+		rules bookingRules
+		r     timeRange
+		want  timeRange
+	}{
+		{"no limits", bookingRules{}, day, timeRange{Start: now, End: day.End}},
+		{"lead time", bookingRules{minLeadTime: time.Hour}, day, timeRange{Start: now.Add(time.Hour), End: day.End}},
+		{"horizon", bookingRules{maxHorizon: 2 * time.Hour}, day,
+			timeRange{Start: now, End: now.Add(2*time.Hour + 30*time.Minute)}},
+		{"future", bookingRules{minLeadTime: time.Hour}, timeRange{Start: now.Add(3 * time.Hour), End: day.End},
+			timeRange{Start: now.Add(3 * time.Hour), End: day.End}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.rules.bookableRange(test.r, 30*time.Minute, now)
+			if !got.Start.Equal(test.want.Start) || !got.End.Equal(test.want.End) {
+				t.Errorf("bookableRange() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+)
+
+// appointmentETag returns the entity tag of the given version of an appointment.
+func appointmentETag(version int32) string {
+	return strconv.Quote(strconv.Itoa(int(version)))
+}
+
+// versionedRequest is a common interface of the requests that change an existing appointment.
+type versionedRequest interface {
+	GetExpectedVersion() int32
+	GetEtag() string
+}
+
+// expectedVersion returns the version of the appointment the client expects to change,
+// taken from expected_version or from the etag. Returns codes.InvalidArgument if neither is set,
+// the etag is malformed or it doesn't match expected_version.
+func expectedVersion(req versionedRequest) (int32, error) {
+	if req.GetEtag() == "" {
+		if req.GetExpectedVersion() <= 0 {
+			return 0, invalidArgumentError("expected_version",
+				"expected_version or etag is required, use the version returned by GetAppointment")
+		}
+		return req.GetExpectedVersion(), nil
+	}
+
+	unquoted, err := strconv.Unquote(strings.TrimPrefix(req.GetEtag(), "W/"))
+	if err != nil {
+		return 0, invalidArgumentError("etag", "etag is malformed")
+	}
+	version, err := strconv.ParseInt(unquoted, 10, 32)
+	if err != nil || version <= 0 {
+		return 0, invalidArgumentError("etag", "etag is malformed")
+	}
+	if req.GetExpectedVersion() != 0 && req.GetExpectedVersion() != int32(version) {
+		return 0, invalidArgumentError("etag", "etag doesn't match expected_version")
+	}
+	return int32(version), nil
+}
+
+// versionMismatchError builds a codes.Aborted error for an appointment that was changed by someone else.
+func versionMismatchError(current int32, expected int32) error {
+	return withDetails(codes.Aborted,
+		fmt.Sprintf("the appointment was changed concurrently: expected version %d, current version is %d, "+
+			"fetch the appointment and retry", expected, current),
+		errorInfo(reasonVersionMismatch, map[string]string{
+			"expected_version": strconv.Itoa(int(expected)),
+			"current_version":  strconv.Itoa(int(current)),
+		}))
+}
+
+// checkVersion returns codes.Aborted if the appointment has another version than expected.
+func checkVersion(appointment *Appointment, expected int32) error {
+	if appointment.Version != expected {
+		return versionMismatchError(appointment.Version, expected)
+	}
+	return nil
+}
+
+// checkVersionedWrite returns codes.Aborted if a write conditioned on the expected version
+// didn't affect the appointment, because it was changed after it was fetched.
+func checkVersionedWrite(result sql.Result, expected int32) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return withDetails(codes.Aborted,
+			fmt.Sprintf("the appointment was changed concurrently: expected version %d, "+
+				"fetch the appointment and retry", expected),
+			errorInfo(reasonVersionMismatch, map[string]string{"expected_version": strconv.Itoa(int(expected))}))
+	}
+	return nil
+}
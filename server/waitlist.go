@@ -0,0 +1,821 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	ppb "github.com/TekClinic/Appointments-MicroService/appointments_protobuf"
+	"github.com/uptrace/bun"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// maxWaitlistWindows is the maximal number of time windows of a waitlist entry.
+	maxWaitlistWindows         = 10
+	maxWaitlistSpecialtyLength = 100
+	// defaultWaitlistOfferTTL is how long a patient can accept an offered slot if WAITLIST_OFFER_TTL is not set.
+	defaultWaitlistOfferTTL = 2 * time.Hour
+	// waitlistExpiryInterval is how often offers that were not answered in time are expired.
+	waitlistExpiryInterval = time.Minute
+	// waitlistExpiryBatchSize is the maximal number of offers expired in one transaction.
+	waitlistExpiryBatchSize = 100
+	// waitlistOffersLock is the namespace of the advisory locks that order the offers of the slots of a doctor,
+	// see offerSlot and lockInTenant.
+	waitlistOffersLock = "waitlist_offers"
+)
+
+// WaitlistOfferStatus is a state of a slot offered to a patient on the waitlist.
+type WaitlistOfferStatus string
+
+const (
+	WaitlistOfferPending  WaitlistOfferStatus = "pending"
+	WaitlistOfferAccepted WaitlistOfferStatus = "accepted"
+	WaitlistOfferDeclined WaitlistOfferStatus = "declined"
+	WaitlistOfferExpired  WaitlistOfferStatus = "expired"
+)
+
+// toGRPC returns a GRPC version of WaitlistOfferStatus.
+func (offerStatus WaitlistOfferStatus) toGRPC() ppb.WaitlistOfferStatus {
+	switch offerStatus {
+	case WaitlistOfferPending:
+		return ppb.WaitlistOfferStatus_WAITLIST_OFFER_STATUS_PENDING
+	case WaitlistOfferAccepted:
+		return ppb.WaitlistOfferStatus_WAITLIST_OFFER_STATUS_ACCEPTED
+	case WaitlistOfferDeclined:
+		return ppb.WaitlistOfferStatus_WAITLIST_OFFER_STATUS_DECLINED
+	case WaitlistOfferExpired:
+		return ppb.WaitlistOfferStatus_WAITLIST_OFFER_STATUS_EXPIRED
+	default:
+		return ppb.WaitlistOfferStatus_WAITLIST_OFFER_STATUS_UNSPECIFIED
+	}
+}
+
+// parseWaitlistOfferTTL parses how long offered slots can be accepted, e.g. 2h.
+func parseWaitlistOfferTTL(value string) (time.Duration, error) {
+	ttl, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %s: %w", envWaitlistOfferTTL, err)
+	}
+	if ttl <= 0 {
+		return 0, fmt.Errorf("%s has to be positive", envWaitlistOfferTTL)
+	}
+	return ttl, nil
+}
+
+// toGRPC returns a GRPC version of WaitlistOffer.
+func (offer WaitlistOffer) toGRPC() *ppb.WaitlistOffer {
+	return &ppb.WaitlistOffer{
+		Id:            offer.ID,
+		EntryId:       offer.EntryID,
+		DoctorId:      offer.DoctorID,
+		StartTime:     offer.StartTime.Format(time.RFC3339),
+		EndTime:       offer.EndTime.Format(time.RFC3339),
+		AppointmentId: offer.AppointmentID,
+		Status:        offer.Status.toGRPC(),
+		ExpiresAt:     offer.ExpiresAt.Format(time.RFC3339),
+	}
+}
+
+// slot returns the slot the offer was made for.
+func (offer WaitlistOffer) slot() waitlistSlot {
+	return waitlistSlot{
+		tenantID:      offer.TenantID,
+		doctorID:      offer.DoctorID,
+		r:             timeRange{Start: offer.StartTime, End: offer.EndTime},
+		appointmentID: offer.AppointmentID,
+	}
+}
+
+// toGRPC returns a GRPC version of WaitlistEntry with its pending offer, if any.
+func (entry WaitlistEntry) toGRPC(offer *WaitlistOffer) *ppb.WaitlistEntry {
+	result := &ppb.WaitlistEntry{
+		Id:            entry.ID,
+		PatientId:     entry.PatientID,
+		DoctorId:      entry.DoctorID,
+		Specialty:     entry.Specialty,
+		Windows:       make([]*ppb.WaitlistWindow, len(entry.Windows)),
+		Priority:      entry.Priority,
+		CreatedAt:     entry.CreatedAt.Format(time.RFC3339),
+		AppointmentId: entry.AppointmentID,
+		Deleted:       !entry.DeletedAt.IsZero(),
+	}
+	for i, window := range entry.Windows {
+		result.Windows[i] = &ppb.WaitlistWindow{
+			StartTime: window.StartTime.Format(time.RFC3339),
+			EndTime:   window.EndTime.Format(time.RFC3339),
+		}
+	}
+	if offer != nil {
+		result.Offer = offer.toGRPC()
+	}
+	return result
+}
+
+// covers reports whether one of the windows of the entry covers r.
+func (entry WaitlistEntry) covers(r timeRange) bool {
+	return slices.ContainsFunc(entry.Windows, func(window WaitlistWindow) bool {
+		return timeRange{Start: window.StartTime, End: window.EndTime}.contains(r)
+	})
+}
+
+// waitlistEntryFromGRPC validates the waitlist entry defined by the request and converts it to WaitlistEntry.
+// Returns codes.InvalidArgument if the definition is invalid.
+func waitlistEntryFromGRPC(req *ppb.CreateWaitlistEntryRequest) (*WaitlistEntry, error) {
+	if req.GetPatientId() <= 0 {
+		return nil, invalidArgumentError("patient_id", "PatientID has to be a positive value")
+	}
+	if req.GetDoctorId() < 0 {
+		return nil, invalidArgumentError("doctor_id", "DoctorID has to be a non-negative value")
+	}
+	specialty := strings.TrimSpace(req.GetSpecialty())
+	if len(specialty) > maxWaitlistSpecialtyLength {
+		return nil, invalidArgumentError("specialty",
+			fmt.Sprintf("specialty has to be at most %d characters long", maxWaitlistSpecialtyLength))
+	}
+	if req.GetDoctorId() == 0 && specialty == "" {
+		return nil, invalidArgumentError("doctor_id", "either DoctorID or specialty is required")
+	}
+	if len(req.GetWindows()) == 0 {
+		return nil, invalidArgumentError("windows", "at least one window is required")
+	}
+	if len(req.GetWindows()) > maxWaitlistWindows {
+		return nil, invalidArgumentError("windows",
+			fmt.Sprintf("at most %d windows are allowed", maxWaitlistWindows))
+	}
+
+	entry := &WaitlistEntry{
+		PatientID: req.GetPatientId(),
+		DoctorID:  req.GetDoctorId(),
+		Specialty: specialty,
+		Windows:   make([]WaitlistWindow, len(req.GetWindows())),
+		Priority:  req.GetPriority(),
+	}
+	now := time.Now()
+	for i, window := range req.GetWindows() {
+		start, err := time.Parse(time.RFC3339, window.GetStartTime())
+		if err != nil {
+			return nil, invalidArgumentError(fmt.Sprintf("windows[%d].start_time", i),
+				fmt.Errorf("failed to parse start time: %w", err).Error())
+		}
+		end, err := time.Parse(time.RFC3339, window.GetEndTime())
+		if err != nil {
+			return nil, invalidArgumentError(fmt.Sprintf("windows[%d].end_time", i),
+				fmt.Errorf("failed to parse end time: %w", err).Error())
+		}
+		if !end.After(start) {
+			return nil, invalidArgumentError(fmt.Sprintf("windows[%d].end_time", i), "end time has to be after start time")
+		}
+		if !end.After(now) {
+			return nil, invalidArgumentError(fmt.Sprintf("windows[%d].end_time", i), "end time has to be in the future")
+		}
+		entry.Windows[i] = WaitlistWindow{StartTime: start, EndTime: end}
+	}
+	return entry, nil
+}
+
+// waitlistSlot is a time of a doctor that can be offered to the waitlist.
+// appointmentID is set if the slot is an appointment without a patient.
+// formerPatientID is the patient who released the slot, who isn't offered it back.
+type waitlistSlot struct {
+	tenantID        string
+	doctorID        int32
+	r               timeRange
+	appointmentID   int32
+	formerPatientID int32
+}
+
+// releasedSlot returns the slot released by the change of the appointment from former to current, if any.
+// current is nil if the appointment was deleted. A slot is released when a booked appointment is deleted,
+// cancelled or moved to another doctor or time, and when its patient is removed.
+func releasedSlot(former *Appointment, current *Appointment) (waitlistSlot, bool) {
+	if former.PatientID == 0 || former.Status == StatusCancelled {
+		return waitlistSlot{}, false
+	}
+	slot := waitlistSlot{
+		tenantID:        former.TenantID,
+		doctorID:        former.DoctorID,
+		r:               former.timeRange(),
+		formerPatientID: former.PatientID,
+	}
+	switch {
+	case current == nil || current.Status == StatusCancelled:
+		return slot, true
+	case current.DoctorID != former.DoctorID || !current.StartTime.Equal(former.StartTime) ||
+		!current.EndTime.Equal(former.EndTime):
+		return slot, true
+	case current.PatientID == 0:
+		slot.appointmentID = current.ID
+		return slot, true
+	default:
+		return waitlistSlot{}, false
+	}
+}
+
+// slotAvailable reports whether the slot can still be booked: the appointment of the slot still has no patient,
+// or the doctor has no other appointment at the time of the slot.
+func slotAvailable(ctx context.Context, db bun.IDB, slot waitlistSlot) (bool, error) {
+	if slot.appointmentID != 0 {
+		query := whereTenant(db.NewSelect().Model((*Appointment)(nil)), slot.tenantID).
+			Where("? = ?", bun.Ident("id"), slot.appointmentID).
+			Where("? = 0", bun.Ident("patient_id"))
+		return whereActive(query).Exists(ctx)
+	}
+	ids, err := findConflicts(ctx, db, slot.tenantID, "doctor_id", slot.doctorID, slot.r, 0)
+	return len(ids) == 0, err
+}
+
+// doctorSpecialties returns the specialties of the appointment types the doctor can take.
+func doctorSpecialties(ctx context.Context, db bun.IDB, tenantID string, doctorID int32) ([]string, error) {
+	var appointmentTypes []AppointmentType
+	if err := whereTenant(db.NewSelect().Model(&appointmentTypes), tenantID).Scan(ctx); err != nil {
+		return nil, err
+	}
+	var specialties []string
+	for _, appointmentType := range appointmentTypes {
+		if appointmentType.allows(doctorID) {
+			specialties = append(specialties, appointmentType.Specialties...)
+		}
+	}
+	return specialties, nil
+}
+
+// offerSlot offers the slot to the first eligible patient on the waitlist, if the slot is still available
+// and hasn't started yet. Eligible entries are open, wait for the doctor or for one of the doctor's specialties,
+// have a window that covers the slot, have no other pending offer and weren't offered the slot before.
+// The entry with the highest priority is chosen, the earliest one among equal priorities.
+// Offers are made under a transaction-level advisory lock of the doctor in the tenant, so that concurrent offers
+// of the doctor's slots don't pick the same entry. An entry without a doctor can be picked concurrently for slots
+// of different doctors, and the entry whose pending offer is made first keeps it, so the other slot goes
+// to the next entry.
+// Offers are made by the service, so they are recorded in the audit trail with systemActor.
+func offerSlot(ctx context.Context, tx bun.Tx, slot waitlistSlot, ttl time.Duration) error {
+	if !slot.r.Start.After(time.Now()) {
+		return nil
+	}
+	if err := lockInTenant(ctx, tx, waitlistOffersLock, slot.tenantID, slot.doctorID); err != nil {
+		return err
+	}
+	available, err := slotAvailable(ctx, tx, slot)
+	if err != nil || !available {
+		return err
+	}
+
+	previous := tx.NewSelect().
+		Model((*WaitlistOffer)(nil)).
+		ColumnExpr("1").
+		Where("? = ?", bun.Ident("waitlist_offer.entry_id"), bun.Ident("waitlist_entry.id")).
+		WhereGroup(" AND ", func(query *bun.SelectQuery) *bun.SelectQuery {
+			return query.
+				Where("? = ?", bun.Ident("waitlist_offer.status"), WaitlistOfferPending).
+				WhereOr("? = ? AND ? = ? AND ? = ?",
+					bun.Ident("waitlist_offer.doctor_id"), slot.doctorID,
+					bun.Ident("waitlist_offer.start_time"), slot.r.Start,
+					bun.Ident("waitlist_offer.end_time"), slot.r.End)
+		})
+	var entries []WaitlistEntry
+	err = whereTenant(tx.NewSelect().Model(&entries), slot.tenantID).
+		Where("? IS NULL", bun.Ident("appointment_id")).
+		Where("? != ?", bun.Ident("patient_id"), slot.formerPatientID).
+		WhereGroup(" AND ", func(query *bun.SelectQuery) *bun.SelectQuery {
+			return query.
+				Where("? = ?", bun.Ident("doctor_id"), slot.doctorID).
+				WhereOr("? IS NULL", bun.Ident("doctor_id"))
+		}).
+		Where("NOT EXISTS (?)", previous).
+		OrderExpr("? DESC, ?, ?", bun.Ident("priority"), bun.Ident("created_at"), bun.Ident("id")).
+		Scan(ctx)
+	if err != nil {
+		return err
+	}
+	specialties, err := doctorSpecialties(ctx, tx, slot.tenantID, slot.doctorID)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.Specialty != "" && !slices.ContainsFunc(specialties, func(specialty string) bool {
+			return strings.EqualFold(specialty, entry.Specialty)
+		}) {
+			continue
+		}
+		if !entry.covers(slot.r) {
+			continue
+		}
+		offer := WaitlistOffer{
+			TenantID:      slot.tenantID,
+			EntryID:       entry.ID,
+			DoctorID:      slot.doctorID,
+			StartTime:     slot.r.Start,
+			EndTime:       slot.r.End,
+			AppointmentID: slot.appointmentID,
+			Status:        WaitlistOfferPending,
+		}
+		// The offer expires by the clock of the database, which is the clock that checks the expiry.
+		var result sql.Result
+		result, err = tx.NewInsert().
+			Model(&offer).
+			Value("expires_at", "?", nowPlus(ttl)).
+			On("CONFLICT DO NOTHING").
+			Returning("*").
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			// The entry got a pending offer of a slot of another doctor concurrently, see waitlistOffersLock.
+			continue
+		}
+		return recordAudit(ctx, tx, offer.TenantID, auditEntityWaitlistOffer, offer.ID, systemActor, nil, offer.toGRPC())
+	}
+	return nil
+}
+
+// offerReleasedSlot offers the slot released by the change of the appointment from former to current,
+// if any, to the waitlist. current is nil if the appointment was deleted, see releasedSlot.
+func (server appointmentsServer) offerReleasedSlot(ctx context.Context, tx bun.Tx,
+	former *Appointment, current *Appointment) error {
+	slot, ok := releasedSlot(former, current)
+	if !ok {
+		return nil
+	}
+	return offerSlot(ctx, tx, slot, server.waitlistOfferTTL)
+}
+
+// closeOffer moves the pending offer to the next status on behalf of the actor. The slot of an offer
+// that was not accepted is offered to the next patient on the waitlist.
+func closeOffer(ctx context.Context, tx bun.Tx, offer *WaitlistOffer, next WaitlistOfferStatus,
+	ttl time.Duration, actor string) error {
+	before := offer.toGRPC()
+	offer.Status = next
+	if _, err := tx.NewUpdate().Model(offer).Column("status").WherePK().Exec(ctx); err != nil {
+		return err
+	}
+	err := recordAudit(ctx, tx, offer.TenantID, auditEntityWaitlistOffer, offer.ID, actor, before, offer.toGRPC())
+	if err != nil {
+		return err
+	}
+	if next == WaitlistOfferAccepted {
+		return nil
+	}
+	return offerSlot(ctx, tx, offer.slot(), ttl)
+}
+
+// fetchPendingOffers returns the pending offers of the entries of the tenant by the ID of their entry.
+func fetchPendingOffers(ctx context.Context, db bun.IDB, tenantID string,
+	entries []WaitlistEntry) (map[int32]WaitlistOffer, error) {
+	offers := make(map[int32]WaitlistOffer)
+	if len(entries) == 0 {
+		return offers, nil
+	}
+	ids := make([]int32, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.ID
+	}
+
+	var fetched []WaitlistOffer
+	err := whereTenant(db.NewSelect().Model(&fetched), tenantID).
+		Where("? IN (?)", bun.Ident("entry_id"), bun.In(ids)).
+		Where("? = ?", bun.Ident("status"), WaitlistOfferPending).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, offer := range fetched {
+		offers[offer.EntryID] = offer
+	}
+	return offers, nil
+}
+
+// fetchWaitlistEntry returns the waitlist entry of the tenant with the given ID that is not deleted.
+// Returns codes.NotFound if there is no such entry.
+func fetchWaitlistEntry(ctx context.Context, db bun.IDB, tenantID string, id int32) (*WaitlistEntry, error) {
+	entry := new(WaitlistEntry)
+	query := db.NewSelect().Model(entry).Where("? = ?", bun.Ident("id"), id)
+	if err := whereTenant(query, tenantID).Scan(ctx); err != nil {
+		return nil, dbError(err, "failed to fetch a waitlist entry by id")
+	}
+	return entry, nil
+}
+
+// fetchPendingOffer locks the offer with the given ID until the end of the transaction and returns it
+// with its entry. Returns codes.NotFound if the offer doesn't exist in the caller's tenant,
+// codes.PermissionDenied if the patient of the offer is not accessible
+// and codes.FailedPrecondition if the offer is no longer pending.
+func fetchPendingOffer(ctx context.Context, tx bun.Tx,
+	caller access, id int32) (*WaitlistOffer, *WaitlistEntry, error) {
+	offer := new(WaitlistOffer)
+	err := whereTenant(tx.NewSelect().Model(offer), caller.tenantID).
+		Where("? = ?", bun.Ident("id"), id).
+		For("UPDATE").
+		Scan(ctx)
+	if err != nil {
+		return nil, nil, dbError(err, "failed to fetch a waitlist offer by id")
+	}
+	entry := new(WaitlistEntry)
+	err = whereTenant(tx.NewSelect().Model(entry), caller.tenantID).
+		Where("? = ?", bun.Ident("id"), offer.EntryID).
+		WhereAllWithDeleted().
+		Scan(ctx)
+	if err != nil {
+		return nil, nil, dbError(err, "failed to fetch the waitlist entry of the offer")
+	}
+	if err = caller.checkPatient(entry.PatientID); err != nil {
+		return nil, nil, err
+	}
+	if offer.Status != WaitlistOfferPending {
+		return nil, nil, status.Error(codes.FailedPrecondition, "the offer is already "+string(offer.Status))
+	}
+	if !offer.ExpiresAt.After(time.Now()) {
+		return nil, nil, status.Error(codes.FailedPrecondition, "the offer has expired")
+	}
+	return offer, entry, nil
+}
+
+// bookOffer books the patient of the entry for the slot of the offer: the patient is assigned to the appointment
+// of the slot, or a new appointment is created. The doctor's working hours are not checked, since the slot
+// was booked before. Returns the warnings to be passed to the client, or codes.FailedPrecondition
+// if the appointment of the slot is no longer open.
+func (server appointmentsServer) bookOffer(ctx context.Context, tx bun.Tx, offer *WaitlistOffer,
+	entry *WaitlistEntry, appointment *Appointment, actor string) ([]*ppb.SchedulingWarning, error) {
+	*appointment = Appointment{
+		TenantID:  offer.TenantID,
+		DoctorID:  offer.DoctorID,
+		StartTime: offer.StartTime,
+		EndTime:   offer.EndTime,
+		Status:    StatusScheduled,
+	}
+	if offer.AppointmentID == 0 {
+		appointment.PatientID = entry.PatientID
+		warnings, err := server.checkAppointment(ctx, tx, appointment, true)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = tx.NewInsert().Model(appointment).Returning("id, version").Exec(ctx); err != nil {
+			return nil, err
+		}
+		return warnings, recordEvent(ctx, tx, EventCreated, appointment, nil, actor)
+	}
+
+	err := whereTenant(tx.NewSelect().Model(appointment), offer.TenantID).
+		Where("? = ?", bun.Ident("id"), offer.AppointmentID).
+		For("UPDATE").
+		Scan(ctx)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if errors.Is(err, sql.ErrNoRows) || appointment.PatientID != 0 || appointment.Status == StatusCancelled ||
+		appointment.DoctorID != offer.DoctorID || !appointment.StartTime.Equal(offer.StartTime) ||
+		!appointment.EndTime.Equal(offer.EndTime) {
+		return nil, status.Error(codes.FailedPrecondition, "the offered slot is no longer available")
+	}
+	former := *appointment
+	appointment.PatientID = entry.PatientID
+	warnings, err := server.checkAppointment(ctx, tx, appointment, true)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.NewUpdate().Model(appointment).Column("patient_id").WherePK().Returning("version").Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return warnings, recordEvent(ctx, tx, EventPatientAssigned, appointment, &former, actor)
+}
+
+// runWaitlistOfferExpiry expires the offers that were not answered in time and offers their slots
+// to the next patients on the waitlist, until the context is done.
+func runWaitlistOfferExpiry(ctx context.Context, db *bun.DB, ttl time.Duration) {
+	ctx = withAuditAction(ctx, "ExpireWaitlistOffers")
+	expire := time.NewTicker(waitlistExpiryInterval)
+	defer expire.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-expire.C:
+			if err := expireWaitlistOffers(ctx, db, ttl); err != nil {
+				zap.L().Error("Failed to expire waitlist offers", zap.Error(err))
+			}
+		}
+	}
+}
+
+// expireWaitlistOffers expires a batch of pending offers whose time is up. Only the tenants that have
+// such offers are looked up across tenants; the offers of each tenant are expired in a transaction bound
+// to the tenant. Offers locked by a request that is answering them are skipped.
+func expireWaitlistOffers(ctx context.Context, db *bun.DB, ttl time.Duration) error {
+	var tenantIDs []string
+	err := runInSystemTx(ctx, db, func(ctx context.Context, tx bun.Tx) error {
+		return tx.NewSelect().
+			Model((*WaitlistOffer)(nil)).
+			ColumnExpr("DISTINCT ?", bun.Ident("tenant_id")).
+			Where("? = ?", bun.Ident("status"), WaitlistOfferPending).
+			Where("? <= now()", bun.Ident("expires_at")).
+			Scan(ctx, &tenantIDs)
+	})
+	if err != nil {
+		return err
+	}
+
+	// A failing tenant doesn't hold back the offers of the others.
+	var errs []error
+	for _, tenantID := range tenantIDs {
+		if err = expireTenantWaitlistOffers(ctx, db, tenantID, ttl); err != nil {
+			errs = append(errs, fmt.Errorf("tenant %q: %w", tenantID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// expireTenantWaitlistOffers expires a batch of pending offers of the tenant whose time is up.
+func expireTenantWaitlistOffers(ctx context.Context, db *bun.DB, tenantID string, ttl time.Duration) error {
+	return runInTenantTx(ctx, db, tenantID, func(ctx context.Context, tx bun.Tx) error {
+		var offers []WaitlistOffer
+		err := whereTenant(tx.NewSelect().Model(&offers), tenantID).
+			Where("? = ?", bun.Ident("status"), WaitlistOfferPending).
+			Where("? <= now()", bun.Ident("expires_at")).
+			Order("id").
+			Limit(waitlistExpiryBatchSize).
+			For("UPDATE SKIP LOCKED").
+			Scan(ctx)
+		if err != nil {
+			return err
+		}
+		for i := range offers {
+			if err = closeOffer(ctx, tx, &offers[i], WaitlistOfferExpired, ttl, systemActor); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetWaitlistEntry returns the waitlist entry with the given ID with its pending offer, if any.
+// Closed entries are returned too.
+// Requires authentication. If authentication is not valid, codes.Unauthenticated is returned.
+// Requires the read_waitlist permission, see accessPolicy. If roles are not sufficient
+// or the patient of the entry is not accessible, codes.PermissionDenied is returned.
+// If the waitlist entry with the given ID doesn't exist, codes.NotFound is returned.
+func (server appointmentsServer) GetWaitlistEntry(ctx context.Context,
+	req *ppb.GetWaitlistEntryRequest) (*ppb.GetWaitlistEntryResponse, error) {
+	caller, err := server.authorize(ctx, req.GetToken(), permReadWaitlist)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := new(WaitlistEntry)
+	var offers map[int32]WaitlistOffer
+	err = runInTenantTx(ctx, server.db, caller.tenantID, func(ctx context.Context, tx bun.Tx) error {
+		txErr := whereTenant(tx.NewSelect().Model(entry), caller.tenantID).
+			Where("? = ?", bun.Ident("id"), req.GetId()).
+			WhereAllWithDeleted().
+			Scan(ctx)
+		if txErr != nil {
+			return dbError(txErr, "failed to fetch a waitlist entry by id")
+		}
+		if txErr = caller.checkPatient(entry.PatientID); txErr != nil {
+			return txErr
+		}
+		offers, txErr = fetchPendingOffers(ctx, tx, caller.tenantID, []WaitlistEntry{*entry})
+		return txErr
+	})
+	if err != nil {
+		return nil, dbError(err, "failed to fetch waitlist offers")
+	}
+
+	var offer *WaitlistOffer
+	if pending, ok := offers[entry.ID]; ok {
+		offer = &pending
+	}
+	return &ppb.GetWaitlistEntryResponse{Entry: entry.toGRPC(offer)}, nil
+}
+
+// ListWaitlistEntries returns the waitlist entries in the order they are offered slots:
+// by priority, highest first, and then by the time they were added.
+// Requires authentication. If authentication is not valid, codes.Unauthenticated is returned.
+// Requires the read_waitlist permission, see accessPolicy. If roles are not sufficient,
+// codes.PermissionDenied is returned. Patients only get their own entries.
+// If one of the filters, skip or limit are invalid, codes.InvalidArgument is returned.
+func (server appointmentsServer) ListWaitlistEntries(ctx context.Context,
+	req *ppb.ListWaitlistEntriesRequest) (*ppb.ListWaitlistEntriesResponse, error) {
+	caller, err := server.authorize(ctx, req.GetToken(), permReadWaitlist)
+	if err != nil {
+		return nil, err
+	}
+	if err = validatePagination(req.GetSkip(), req.GetLimit()); err != nil {
+		return nil, err
+	}
+	if req.GetPatientId() < 0 {
+		return nil, invalidArgumentError("patient_id", "PatientID has to be a non-negative value")
+	}
+	if req.GetDoctorId() < 0 {
+		return nil, invalidArgumentError("doctor_id", "DoctorID has to be a non-negative value")
+	}
+
+	var entries []WaitlistEntry
+	query := whereTenant(server.db.NewSelect().Model(&entries), caller.tenantID)
+	if !caller.all {
+		query = query.Where("? = ?", bun.Ident("patient_id"), caller.patientID)
+	}
+	if req.GetPatientId() != 0 {
+		query = query.Where("? = ?", bun.Ident("patient_id"), req.GetPatientId())
+	}
+	if req.GetDoctorId() != 0 {
+		query = query.Where("? = ?", bun.Ident("doctor_id"), req.GetDoctorId())
+	}
+	if req.GetIncludeClosed() {
+		query = query.WhereAllWithDeleted()
+	} else {
+		query = query.Where("? IS NULL", bun.Ident("appointment_id"))
+	}
+	var count int
+	var offers map[int32]WaitlistOffer
+	err = runInTenantTx(ctx, server.db, caller.tenantID, func(ctx context.Context, tx bun.Tx) error {
+		var txErr error
+		count, txErr = query.Conn(tx).
+			OrderExpr("? DESC, ?, ?", bun.Ident("priority"), bun.Ident("created_at"), bun.Ident("id")).
+			Offset(int(req.GetSkip())).
+			Limit(int(req.GetLimit())).
+			ScanAndCount(ctx)
+		if txErr != nil {
+			return txErr
+		}
+		offers, txErr = fetchPendingOffers(ctx, tx, caller.tenantID, entries)
+		return txErr
+	})
+	if err != nil {
+		return nil, dbError(err, "failed to fetch waitlist entries")
+	}
+
+	results := make([]*ppb.WaitlistEntry, len(entries))
+	for i, entry := range entries {
+		var offer *WaitlistOffer
+		if pending, ok := offers[entry.ID]; ok {
+			offer = &pending
+		}
+		results[i] = entry.toGRPC(offer)
+	}
+	return &ppb.ListWaitlistEntriesResponse{Count: int32(count), Results: results}, nil
+}
+
+// CreateWaitlistEntry adds a patient to the waitlist. The patient is offered the slots that are released later
+// and match the entry, see offerSlot.
+// Requires authentication. If authentication is not valid, codes.Unauthenticated is returned.
+// Requires the write_waitlist permission, see accessPolicy. If roles are not sufficient
+// or the patient is not accessible, codes.PermissionDenied is returned.
+// If the entry definition is invalid, codes.InvalidArgument is returned.
+func (server appointmentsServer) CreateWaitlistEntry(ctx context.Context,
+	req *ppb.CreateWaitlistEntryRequest) (*ppb.CreateWaitlistEntryResponse, error) {
+	caller, err := server.authorize(ctx, req.GetToken(), permWriteWaitlist)
+	if err != nil {
+		return nil, err
+	}
+	entry, err := waitlistEntryFromGRPC(req)
+	if err != nil {
+		return nil, err
+	}
+	if err = caller.checkPatient(entry.PatientID); err != nil {
+		return nil, err
+	}
+	entry.TenantID = caller.tenantID
+
+	err = runInTenantTx(ctx, server.db, caller.tenantID, func(ctx context.Context, tx bun.Tx) error {
+		if _, txErr := tx.NewInsert().Model(entry).Exec(ctx); txErr != nil {
+			return txErr
+		}
+		return recordAudit(ctx, tx, caller.tenantID, auditEntityWaitlistEntry, entry.ID, tokenActor(req.GetToken()),
+			nil, entry.toGRPC(nil))
+	})
+	if err != nil {
+		return nil, dbError(err, "failed to create a waitlist entry")
+	}
+
+	return &ppb.CreateWaitlistEntryResponse{Id: entry.ID}, nil
+}
+
+// DeleteWaitlistEntry removes a patient from the waitlist. A pending offer of the entry is declined,
+// and its slot is offered to the next patient.
+// Requires authentication. If authentication is not valid, codes.Unauthenticated is returned.
+// Requires the write_waitlist permission, see accessPolicy. If roles are not sufficient
+// or the patient of the entry is not accessible, codes.PermissionDenied is returned.
+// If the waitlist entry with the given ID doesn't exist, codes.NotFound is returned.
+func (server appointmentsServer) DeleteWaitlistEntry(ctx context.Context,
+	req *ppb.DeleteWaitlistEntryRequest) (*ppb.DeleteWaitlistEntryResponse, error) {
+	caller, err := server.authorize(ctx, req.GetToken(), permWriteWaitlist)
+	if err != nil {
+		return nil, err
+	}
+	actor := tokenActor(req.GetToken())
+	err = runInTenantTx(ctx, server.db, caller.tenantID, func(ctx context.Context, tx bun.Tx) error {
+		entry, txErr := fetchWaitlistEntry(ctx, tx, caller.tenantID, req.GetId())
+		if txErr != nil {
+			return txErr
+		}
+		if txErr = caller.checkPatient(entry.PatientID); txErr != nil {
+			return txErr
+		}
+		if _, txErr = tx.NewDelete().Model(entry).WherePK().Exec(ctx); txErr != nil {
+			return txErr
+		}
+		var offers []WaitlistOffer
+		txErr = whereTenant(tx.NewSelect().Model(&offers), caller.tenantID).
+			Where("? = ?", bun.Ident("entry_id"), entry.ID).
+			Where("? = ?", bun.Ident("status"), WaitlistOfferPending).
+			For("UPDATE").
+			Scan(ctx)
+		if txErr != nil {
+			return txErr
+		}
+		for i := range offers {
+			if txErr = closeOffer(ctx, tx, &offers[i], WaitlistOfferDeclined, server.waitlistOfferTTL, actor); txErr != nil {
+				return txErr
+			}
+		}
+		return recordAudit(ctx, tx, caller.tenantID, auditEntityWaitlistEntry, entry.ID, actor, entry.toGRPC(nil), nil)
+	})
+	if err != nil {
+		return nil, dbError(err, "failed to delete a waitlist entry")
+	}
+
+	return &ppb.DeleteWaitlistEntryResponse{Message: "Waitlist entry deleted successfully"}, nil
+}
+
+// AcceptWaitlistOffer books the patient of a pending offer for the offered slot, which closes the waitlist entry.
+// Requires authentication. If authentication is not valid, codes.Unauthenticated is returned.
+// Requires the answer_waitlist_offers permission, see accessPolicy. If roles are not sufficient
+// or the patient of the offer is not accessible, codes.PermissionDenied is returned.
+// If the offer with the given ID doesn't exist, codes.NotFound is returned.
+// If the offer is not pending, has expired or the slot is no longer open, codes.FailedPrecondition is returned.
+// If the slot overlaps another appointment of the doctor or of the resources, codes.AlreadyExists is returned.
+// If the slot overlaps another appointment of the patient, the patient overlap policy is applied.
+func (server appointmentsServer) AcceptWaitlistOffer(ctx context.Context,
+	req *ppb.AcceptWaitlistOfferRequest) (*ppb.AcceptWaitlistOfferResponse, error) {
+	caller, err := server.authorize(ctx, req.GetToken(), permAnswerWaitlistOffers)
+	if err != nil {
+		return nil, err
+	}
+
+	actor := tokenActor(req.GetToken())
+	appointment := new(Appointment)
+	var warnings []*ppb.SchedulingWarning
+	err = runInTenantTx(ctx, server.db, caller.tenantID, func(ctx context.Context, tx bun.Tx) error {
+		offer, entry, txErr := fetchPendingOffer(ctx, tx, caller, req.GetId())
+		if txErr != nil {
+			return txErr
+		}
+		if warnings, txErr = server.bookOffer(ctx, tx, offer, entry, appointment, actor); txErr != nil {
+			return txErr
+		}
+		if txErr = closeOffer(ctx, tx, offer, WaitlistOfferAccepted, server.waitlistOfferTTL, actor); txErr != nil {
+			return txErr
+		}
+
+		before := entry.toGRPC(nil)
+		entry.AppointmentID = appointment.ID
+		if _, txErr = tx.NewUpdate().Model(entry).Column("appointment_id").WherePK().Exec(ctx); txErr != nil {
+			return txErr
+		}
+		return recordAudit(ctx, tx, caller.tenantID, auditEntityWaitlistEntry, entry.ID, actor,
+			before, entry.toGRPC(nil))
+	})
+	if err != nil {
+		return nil, writeError(ctx, server.db, appointment, err, "failed to accept a waitlist offer")
+	}
+
+	return &ppb.AcceptWaitlistOfferResponse{AppointmentId: appointment.ID, Warnings: warnings}, nil
+}
+
+// DeclineWaitlistOffer declines a pending offer, after which the slot is offered to the next patient.
+// The waitlist entry stays open.
+// Requires authentication. If authentication is not valid, codes.Unauthenticated is returned.
+// Requires the answer_waitlist_offers permission, see accessPolicy. If roles are not sufficient
+// or the patient of the offer is not accessible, codes.PermissionDenied is returned.
+// If the offer with the given ID doesn't exist, codes.NotFound is returned.
+// If the offer is not pending or has expired, codes.FailedPrecondition is returned.
+func (server appointmentsServer) DeclineWaitlistOffer(ctx context.Context,
+	req *ppb.DeclineWaitlistOfferRequest) (*ppb.DeclineWaitlistOfferResponse, error) {
+	caller, err := server.authorize(ctx, req.GetToken(), permAnswerWaitlistOffers)
+	if err != nil {
+		return nil, err
+	}
+
+	err = runInTenantTx(ctx, server.db, caller.tenantID, func(ctx context.Context, tx bun.Tx) error {
+		offer, _, txErr := fetchPendingOffer(ctx, tx, caller, req.GetId())
+		if txErr != nil {
+			return txErr
+		}
+		return closeOffer(ctx, tx, offer, WaitlistOfferDeclined, server.waitlistOfferTTL, tokenActor(req.GetToken()))
+	})
+	if err != nil {
+		return nil, dbError(err, "failed to decline a waitlist offer")
+	}
+
+	return &ppb.DeclineWaitlistOfferResponse{Message: "Offer declined successfully"}, nil
+}
@@ -0,0 +1,428 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	ppb "github.com/TekClinic/Appointments-MicroService/appointments_protobuf"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/driver/pgdriver"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// AppointmentEventType is a kind of change of an appointment.
+type AppointmentEventType string
+
+const (
+	EventCreated         AppointmentEventType = "created"
+	EventUpdated         AppointmentEventType = "updated"
+	EventPatientAssigned AppointmentEventType = "patient_assigned"
+	EventPatientRemoved  AppointmentEventType = "patient_removed"
+	EventStatusChanged   AppointmentEventType = "status_changed"
+	EventCancelled       AppointmentEventType = "cancelled"
+	EventDeleted         AppointmentEventType = "deleted"
+	EventRestored        AppointmentEventType = "restored"
+)
+
+const (
+	// appointmentEventsChannel is the PostgreSQL notification channel that wakes up the watchers after a change.
+	appointmentEventsChannel = "appointment_events"
+	// eventRetention is how long events are kept, and therefore how long a resume token stays valid.
+	eventRetention = 7 * 24 * time.Hour
+	// eventPruneInterval is how often events older than eventRetention are deleted.
+	eventPruneInterval = time.Hour
+	// watchPollInterval is how often a watcher looks for new events and verifies its token again
+	// when there are no notifications. It bounds the delay of an event if a notification is lost.
+	watchPollInterval = 5 * time.Second
+	// watchBatchSize is the maximal number of events read from the database at once.
+	watchBatchSize = 100
+	// resumeTokenHeader is the header that carries the position of the stream before the first event.
+	resumeTokenHeader = "x-resume-token"
+)
+
+// toGRPC returns a GRPC version of AppointmentEventType.
+func (eventType AppointmentEventType) toGRPC() ppb.AppointmentEventType {
+	switch eventType {
+	case EventCreated:
+		return ppb.AppointmentEventType_APPOINTMENT_EVENT_TYPE_CREATED
+	case EventUpdated:
+		return ppb.AppointmentEventType_APPOINTMENT_EVENT_TYPE_UPDATED
+	case EventPatientAssigned:
+		return ppb.AppointmentEventType_APPOINTMENT_EVENT_TYPE_PATIENT_ASSIGNED
+	case EventPatientRemoved:
+		return ppb.AppointmentEventType_APPOINTMENT_EVENT_TYPE_PATIENT_REMOVED
+	case EventStatusChanged:
+		return ppb.AppointmentEventType_APPOINTMENT_EVENT_TYPE_STATUS_CHANGED
+	case EventCancelled:
+		return ppb.AppointmentEventType_APPOINTMENT_EVENT_TYPE_CANCELLED
+	case EventDeleted:
+		return ppb.AppointmentEventType_APPOINTMENT_EVENT_TYPE_DELETED
+	case EventRestored:
+		return ppb.AppointmentEventType_APPOINTMENT_EVENT_TYPE_RESTORED
+	default:
+		return ppb.AppointmentEventType_APPOINTMENT_EVENT_TYPE_UNSPECIFIED
+	}
+}
+
+// toGRPC returns a GRPC version of AppointmentEvent.
+func (event AppointmentEvent) toGRPC() *ppb.AppointmentEvent {
+	return &ppb.AppointmentEvent{
+		ResumeToken: resumeToken{TransactionID: event.TransactionID, ID: event.ID, OccurredAt: event.OccurredAt}.encode(),
+		Type:        event.Type.toGRPC(),
+		OccurredAt:  event.OccurredAt.Format(time.RFC3339),
+		Appointment: event.Appointment.toGRPC(),
+	}
+}
+
+// recordEvent records a change of the appointment in the transaction that makes the change,
+// so that the event is visible exactly when the change is, and notifies the watchers on commit.
+// The event is also added to the outbox to be published to other services.
+// The change is recorded in the audit trail on behalf of the actor as well.
+// former is the appointment before the change, or nil if it didn't exist.
+// The event is stamped with the ID of the transaction, which orders it for the watchers, see sendEvents.
+func recordEvent(ctx context.Context, tx bun.Tx, eventType AppointmentEventType,
+	appointment *Appointment, former *Appointment, actor string) error {
+	var transactionID int64
+	if err := tx.NewRaw("SELECT pg_current_xact_id()::text::bigint").Scan(ctx, &transactionID); err != nil {
+		return err
+	}
+
+	snapshot := *appointment
+	snapshot.StatusChanges = nil
+	event := AppointmentEvent{
+		TransactionID: transactionID,
+		TenantID:      appointment.TenantID,
+		AppointmentID: appointment.ID,
+		Type:          eventType,
+		DoctorID:      appointment.DoctorID,
+		PatientID:     appointment.PatientID,
+		StartTime:     appointment.StartTime,
+		EndTime:       appointment.EndTime,
+		Appointment:   snapshot,
+	}
+	if former != nil {
+		event.FormerDoctorID = former.DoctorID
+		event.FormerPatientID = former.PatientID
+	}
+	if _, err := tx.NewInsert().Model(&event).Returning("id, occurred_at").Exec(ctx); err != nil {
+		return err
+	}
+	if err := addToOutbox(ctx, tx, &event); err != nil {
+		return err
+	}
+
+	var before proto.Message
+	if former != nil {
+		before = former.auditSnapshot()
+	}
+	if err := recordAudit(ctx, tx, appointment.TenantID, auditEntityAppointment, appointment.ID, actor,
+		before, appointment.auditSnapshot()); err != nil {
+		return err
+	}
+
+	_, err := tx.NewRaw("SELECT pg_notify(?, ?)", appointmentEventsChannel, strconv.FormatInt(event.ID, 10)).Exec(ctx)
+	return err
+}
+
+// resumeToken is the position of a watcher in the stream of events.
+// It is passed to clients as an opaque base64-encoded string.
+type resumeToken struct {
+	// TransactionID and ID are the transaction and the ID of the last event the watcher has received.
+	TransactionID int64
+	ID            int64
+	// OccurredAt is the time of the last event, used to tell whether the following events were pruned.
+	OccurredAt time.Time
+}
+
+// encode returns the opaque string form of the token.
+func (token resumeToken) encode() string {
+	return base64.RawURLEncoding.EncodeToString(fmt.Appendf(nil, "%d.%d.%d",
+		token.TransactionID, token.ID, token.OccurredAt.UnixNano()))
+}
+
+// decodeResumeToken parses the resume token of a watch request.
+// Returns codes.InvalidArgument if the token is malformed
+// and codes.OutOfRange if the events following it may have been pruned already.
+// Tokens issued before the events were ordered by transactions are expired too.
+func decodeResumeToken(value string) (resumeToken, error) {
+	var transactionID, id, occurredAt int64
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return resumeToken{}, invalidArgumentError("resume_token", "resume token is malformed")
+	}
+	if strings.Count(string(data), ".") == 1 {
+		return resumeToken{}, status.Error(codes.OutOfRange,
+			"resume token has expired, reload the appointments and watch again without it")
+	}
+	if _, err = fmt.Sscanf(string(data), "%d.%d.%d", &transactionID, &id, &occurredAt); err != nil {
+		return resumeToken{}, invalidArgumentError("resume_token", "resume token is malformed")
+	}
+
+	token := resumeToken{TransactionID: transactionID, ID: id, OccurredAt: time.Unix(0, occurredAt)}
+	if time.Since(token.OccurredAt) > eventRetention {
+		return resumeToken{}, status.Error(codes.OutOfRange,
+			"resume token has expired, reload the appointments and watch again without it")
+	}
+	return token, nil
+}
+
+// eventHub wakes up the watchers of the server when new events are recorded.
+type eventHub struct {
+	mu          sync.Mutex
+	subscribers map[chan struct{}]struct{}
+}
+
+// newEventHub returns an eventHub without subscribers.
+func newEventHub() *eventHub {
+	return &eventHub{subscribers: make(map[chan struct{}]struct{})}
+}
+
+// subscribe returns a channel that receives a signal after new events are recorded
+// and a function that cancels the subscription. Signals are coalesced, so a single signal may stand for several events.
+func (hub *eventHub) subscribe() (<-chan struct{}, func()) {
+	wake := make(chan struct{}, 1)
+	hub.mu.Lock()
+	hub.subscribers[wake] = struct{}{}
+	hub.mu.Unlock()
+
+	return wake, func() {
+		hub.mu.Lock()
+		delete(hub.subscribers, wake)
+		hub.mu.Unlock()
+	}
+}
+
+// broadcast wakes up all subscribers without blocking.
+func (hub *eventHub) broadcast() {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	for wake := range hub.subscribers {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// run listens for notifications about new events and wakes up the subscribers until the context is done.
+// It also deletes the events older than eventRetention. The listener reconnects by itself if the connection is lost,
+// and the watchers poll the database in the meantime.
+func (hub *eventHub) run(ctx context.Context, db *bun.DB) {
+	listener := pgdriver.NewListener(db)
+	defer listener.Close()
+	if err := listener.Listen(ctx, appointmentEventsChannel); err != nil {
+		zap.L().Error("Failed to listen for appointment events", zap.Error(err))
+	}
+	notifications := listener.Channel()
+
+	prune := time.NewTicker(eventPruneInterval)
+	defer prune.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-notifications:
+			hub.broadcast()
+		case <-prune.C:
+			// Events of all tenants are pruned at once.
+			err := runInSystemTx(ctx, db, func(ctx context.Context, tx bun.Tx) error {
+				_, txErr := tx.NewDelete().
+					Model((*AppointmentEvent)(nil)).
+					Where("? < ?", bun.Ident("occurred_at"), time.Now().Add(-eventRetention)).
+					Exec(ctx)
+				return txErr
+			})
+			if err != nil {
+				zap.L().Error("Failed to prune appointment events", zap.Error(err))
+			}
+		}
+	}
+}
+
+// eventFilter selects the events a watcher receives.
+type eventFilter struct {
+	caller     access
+	doctorIDs  []int32
+	patientIDs []int32
+	from       time.Time
+	to         time.Time
+}
+
+// parseEventFilter validates the filters of a watch request.
+// Returns codes.InvalidArgument if one of the filters is malformed.
+func parseEventFilter(caller access, req *ppb.WatchAppointmentsRequest) (eventFilter, error) {
+	filter := eventFilter{caller: caller}
+	var err error
+	if filter.doctorIDs, err = filterIDs("doctor_ids", 0, req.GetDoctorIds()); err != nil {
+		return eventFilter{}, err
+	}
+	if filter.patientIDs, err = filterIDs("patient_ids", 0, req.GetPatientIds()); err != nil {
+		return eventFilter{}, err
+	}
+	if req.GetFrom() != "" {
+		if filter.from, err = time.Parse(time.RFC3339, req.GetFrom()); err != nil {
+			return eventFilter{}, invalidArgumentError("from", fmt.Errorf("failed to parse from: %w", err).Error())
+		}
+	}
+	if req.GetTo() != "" {
+		if filter.to, err = time.Parse(time.RFC3339, req.GetTo()); err != nil {
+			return eventFilter{}, invalidArgumentError("to", fmt.Errorf("failed to parse to: %w", err).Error())
+		}
+		if !filter.from.IsZero() && !filter.to.After(filter.from) {
+			return eventFilter{}, invalidArgumentError("to", "to has to be after from")
+		}
+	}
+	return filter, nil
+}
+
+// whereIDs restricts the query to the events whose column or its former value is one of the IDs.
+func whereIDs(query *bun.SelectQuery, column string, ids []int32) *bun.SelectQuery {
+	return query.WhereGroup(" AND ", func(query *bun.SelectQuery) *bun.SelectQuery {
+		return query.
+			WhereOr("? IN (?)", bun.Ident(column), bun.In(ids)).
+			WhereOr("? IN (?)", bun.Ident("former_"+column), bun.In(ids))
+	})
+}
+
+// apply restricts the query of events to the ones that match the filter and are accessible to the caller.
+// Only the events of the caller's tenant are accessible. A doctor or a patient matches both the events
+// of their appointments and the events that moved an appointment away from them.
+func (filter eventFilter) apply(query *bun.SelectQuery) *bun.SelectQuery {
+	query = whereTenant(query, filter.caller.tenantID)
+	if !filter.caller.all {
+		query = query.WhereGroup(" AND ", func(query *bun.SelectQuery) *bun.SelectQuery {
+			if filter.caller.doctorID != 0 {
+				query = query.
+					WhereOr("? = ?", bun.Ident("doctor_id"), filter.caller.doctorID).
+					WhereOr("? = ?", bun.Ident("former_doctor_id"), filter.caller.doctorID)
+			}
+			if filter.caller.patientID != 0 {
+				query = query.
+					WhereOr("? = ?", bun.Ident("patient_id"), filter.caller.patientID).
+					WhereOr("? = ?", bun.Ident("former_patient_id"), filter.caller.patientID)
+			}
+			return query
+		})
+	}
+	if len(filter.doctorIDs) != 0 {
+		query = whereIDs(query, "doctor_id", filter.doctorIDs)
+	}
+	if len(filter.patientIDs) != 0 {
+		query = whereIDs(query, "patient_id", filter.patientIDs)
+	}
+	if !filter.from.IsZero() {
+		query = query.Where("? > ?", bun.Ident("end_time"), filter.from)
+	}
+	if !filter.to.IsZero() {
+		query = query.Where("? < ?", bun.Ident("start_time"), filter.to)
+	}
+	return query
+}
+
+// sendEvents sends the events recorded after the position that match the filter
+// and returns the position of the last sent event.
+// Events are sent in the order of their transactions and IDs, and only once all older transactions have ended,
+// so that no transaction can record an event before the position after it has been passed.
+// Concurrent transactions don't have to be serialized for that, but an event is delayed
+// while a transaction that started before it is still running.
+func (server appointmentsServer) sendEvents(ctx context.Context, stream ppb.AppointmentsService_WatchAppointmentsServer,
+	filter eventFilter, position resumeToken) (resumeToken, error) {
+	for {
+		var events []AppointmentEvent
+		err := runInTenantTx(ctx, server.db, filter.caller.tenantID, func(ctx context.Context, tx bun.Tx) error {
+			return filter.apply(tx.NewSelect().Model(&events)).
+				Where("(?, ?) > (?, ?)", bun.Ident("transaction_id"), bun.Ident("id"),
+					position.TransactionID, position.ID).
+				Where("? < pg_snapshot_xmin(pg_current_snapshot())::text::bigint", bun.Ident("transaction_id")).
+				Order("transaction_id", "id").
+				Limit(watchBatchSize).
+				Scan(ctx)
+		})
+		if err != nil {
+			return position, dbError(err, "failed to fetch appointment events")
+		}
+
+		for _, event := range events {
+			if err = stream.Send(event.toGRPC()); err != nil {
+				return position, err
+			}
+			position = resumeToken{TransactionID: event.TransactionID, ID: event.ID, OccurredAt: event.OccurredAt}
+		}
+		if len(events) < watchBatchSize {
+			return position, nil
+		}
+	}
+}
+
+// WatchAppointments streams changes of appointments that match the filters: creation, updates, patient assignment
+// and removal, status changes, cancellation, deletion and restoration. Every event carries a resume token.
+// A client that reconnects with the token of the last event it has received gets all the events it missed.
+// Without a resume token, the stream starts with the changes made after the call, and the position
+// of the stream is sent in the x-resume-token header, so that a client can resume before the first event.
+// Requires authentication. If authentication is not valid, codes.Unauthenticated is returned.
+// The token is verified again periodically, and the stream ends with codes.Unauthenticated once it expires.
+// Requires the read_appointments permission, see accessPolicy. If roles are not sufficient,
+// codes.PermissionDenied is returned. Doctors and patients only get events of their own appointments.
+// If one of the filters or the resume token is invalid, codes.InvalidArgument is returned.
+// If the resume token is older than the retention of the events, codes.OutOfRange is returned.
+func (server appointmentsServer) WatchAppointments(req *ppb.WatchAppointmentsRequest,
+	stream ppb.AppointmentsService_WatchAppointmentsServer) error {
+	ctx := stream.Context()
+	caller, err := server.authorize(ctx, req.GetToken(), permReadAppointments)
+	if err != nil {
+		return err
+	}
+	filter, err := parseEventFilter(caller, req)
+	if err != nil {
+		return err
+	}
+
+	// Subscribe before reading the position, so that no event recorded in between is missed.
+	wake, unsubscribe := server.events.subscribe()
+	defer unsubscribe()
+
+	var position resumeToken
+	if req.GetResumeToken() != "" {
+		if position, err = decodeResumeToken(req.GetResumeToken()); err != nil {
+			return err
+		}
+	} else {
+		// The events of the transactions that have ended are in the past, the others are yet to be sent.
+		err = server.db.NewRaw("SELECT pg_snapshot_xmin(pg_current_snapshot())::text::bigint").
+			Scan(ctx, &position.TransactionID)
+		if err != nil {
+			return dbError(err, "failed to fetch the position of the stream")
+		}
+		position.OccurredAt = time.Now()
+	}
+	if err = stream.SendHeader(metadata.Pairs(resumeTokenHeader, position.encode())); err != nil {
+		return err
+	}
+
+	poll := time.NewTicker(watchPollInterval)
+	defer poll.Stop()
+	for {
+		if position, err = server.sendEvents(ctx, stream, filter, position); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-wake:
+		case <-poll.C:
+			if _, err = server.authorize(ctx, req.GetToken(), permReadAppointments); err != nil {
+				return err
+			}
+		}
+	}
+}